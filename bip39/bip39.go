@@ -0,0 +1,497 @@
+// Package bip39 implements BIP-39 mnemonics in every wordlist shipped with
+// go-bip39, with language detection and NFKD normalization of the input.
+package bip39
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/tyler-smith/go-bip39/wordlists"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ideographicSpace separates the words of Japanese mnemonics.
+const ideographicSpace = "　"
+
+var (
+	// ErrInvalidEntropy is returned for entropy that is not 128 to 256 bits
+	// in steps of 32.
+	ErrInvalidEntropy = errors.New(
+		"entropy must be 128 to 256 bits, a multiple of 32")
+	// ErrInvalidWordCount is returned for mnemonics that are not 12, 15, 18,
+	// 21 or 24 words long.
+	ErrInvalidWordCount = errors.New("mnemonic must have 12 to 24 words")
+	// ErrUnknownWord is returned for words that are not in the wordlist.
+	ErrUnknownWord = errors.New("word is not in the wordlist")
+	// ErrInvalidChecksum is returned for mnemonics with a wrong checksum.
+	ErrInvalidChecksum = errors.New("invalid mnemonic checksum")
+	// ErrUnknownLanguage is returned when no wordlist contains all words of
+	// a mnemonic.
+	ErrUnknownLanguage = errors.New("unknown mnemonic language")
+)
+
+// Language is the language of a BIP-39 wordlist.
+type Language int
+
+const (
+	English Language = iota
+	ChineseSimplified
+	ChineseTraditional
+	Czech
+	French
+	Italian
+	Japanese
+	Korean
+	Spanish
+)
+
+// languages lists the supported languages in the order they are detected in.
+var languages = []Language{
+	English, Spanish, French, Italian, Czech,
+	Japanese, Korean, ChineseSimplified, ChineseTraditional,
+}
+
+// Languages returns the supported languages.
+func Languages() []Language {
+	return append([]Language{}, languages...)
+}
+
+// String returns the name of the language.
+func (l Language) String() string {
+	switch l {
+	case English:
+		return "english"
+	case ChineseSimplified:
+		return "chinese_simplified"
+	case ChineseTraditional:
+		return "chinese_traditional"
+	case Czech:
+		return "czech"
+	case French:
+		return "french"
+	case Italian:
+		return "italian"
+	case Japanese:
+		return "japanese"
+	case Korean:
+		return "korean"
+	case Spanish:
+		return "spanish"
+	}
+	return fmt.Sprintf("unknown(%d)", int(l))
+}
+
+// Wordlist returns the 2048 words of the language.
+func (l Language) Wordlist() []string {
+	switch l {
+	case English:
+		return wordlists.English
+	case ChineseSimplified:
+		return wordlists.ChineseSimplified
+	case ChineseTraditional:
+		return wordlists.ChineseTraditional
+	case Czech:
+		return wordlists.Czech
+	case French:
+		return wordlists.French
+	case Italian:
+		return wordlists.Italian
+	case Japanese:
+		return wordlists.Japanese
+	case Korean:
+		return wordlists.Korean
+	case Spanish:
+		return wordlists.Spanish
+	}
+	return nil
+}
+
+// Separator returns the separator of the words of mnemonics in the language,
+// the ideographic space for Japanese and a space otherwise.
+func (l Language) Separator() string {
+	if l == Japanese {
+		return ideographicSpace
+	}
+	return " "
+}
+
+// foldsAccents returns whether words of the language can be matched without
+// their diacritics. Kana and hangul decompose into combining marks that
+// distinguish words, so they are matched exactly.
+func (l Language) foldsAccents() bool {
+	switch l {
+	case Spanish, French, Italian, Czech:
+		return true
+	}
+	return false
+}
+
+// wordIndex looks up the index of normalized words of a wordlist.
+type wordIndex struct {
+	words []string
+	exact map[string]int
+	// folded maps words without diacritics to their index, leaving out
+	// words that would be ambiguous.
+	folded map[string]int
+}
+
+var (
+	indexLock sync.Mutex
+	indexes   = map[Language]*wordIndex{}
+)
+
+// index returns the word index of the language, building it on first use.
+func (l Language) index() (*wordIndex, error) {
+	indexLock.Lock()
+	defer indexLock.Unlock()
+
+	if index, ok := indexes[l]; ok {
+		return index, nil
+	}
+
+	words := l.Wordlist()
+	if words == nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownLanguage, l)
+	}
+
+	index := &wordIndex{
+		words:  words,
+		exact:  make(map[string]int, len(words)),
+		folded: map[string]int{},
+	}
+	ambiguous := map[string]bool{}
+	for i, word := range words {
+		index.exact[normalize(word)] = i
+		if !l.foldsAccents() {
+			continue
+		}
+
+		folded := foldAccents(normalize(word))
+		if _, ok := index.folded[folded]; ok {
+			ambiguous[folded] = true
+		}
+		index.folded[folded] = i
+	}
+	for folded := range ambiguous {
+		delete(index.folded, folded)
+	}
+
+	indexes[l] = index
+	return index, nil
+}
+
+// lookup returns the index of the normalized word.
+func (w *wordIndex) lookup(word string) (int, bool) {
+	if i, ok := w.exact[word]; ok {
+		return i, true
+	}
+	i, ok := w.folded[foldAccents(word)]
+	return i, ok
+}
+
+// NewEntropy returns random entropy of the number of bits.
+func NewEntropy(bits int) ([]byte, error) {
+	if err := validateEntropyBits(bits); err != nil {
+		return nil, err
+	}
+
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, err
+	}
+	return entropy, nil
+}
+
+// NewMnemonic returns the mnemonic of the entropy in the language. Words of
+// Japanese mnemonics are separated by ideographic spaces.
+func NewMnemonic(entropy []byte, lang Language) (string, error) {
+	if err := validateEntropyBits(len(entropy) * 8); err != nil {
+		return "", err
+	}
+	words := lang.Wordlist()
+	if words == nil {
+		return "", fmt.Errorf("%w: %v", ErrUnknownLanguage, lang)
+	}
+
+	// the entropy is followed by the first bits of its hash, one per 32
+	// bits of entropy, and split into 11-bit word indexes
+	checksumBits := uint(len(entropy) * 8 / 32)
+	hash := sha256.Sum256(entropy)
+	value := new(big.Int).SetBytes(entropy)
+	value.Lsh(value, checksumBits)
+	value.Or(value, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	count := (len(entropy)*8 + int(checksumBits)) / 11
+	mnemonic := make([]string, count)
+	mask := big.NewInt(2047)
+	for i := count - 1; i >= 0; i-- {
+		mnemonic[i] = words[new(big.Int).And(value, mask).Int64()]
+		value.Rsh(value, 11)
+	}
+	return strings.Join(mnemonic, lang.Separator()), nil
+}
+
+// EntropyFromMnemonic returns the entropy of the mnemonic after checking its
+// checksum. The language is detected unless it is given.
+func EntropyFromMnemonic(
+	mnemonic string,
+	langOpt ...Language,
+) ([]byte, error) {
+	words := SplitMnemonic(mnemonic)
+
+	var lang Language
+	if len(langOpt) > 0 {
+		lang = langOpt[0]
+	} else {
+		detected, err := detectLanguage(words)
+		if err != nil {
+			return nil, err
+		}
+		lang = detected
+	}
+
+	indices, err := wordIndices(words, lang)
+	if err != nil {
+		return nil, err
+	}
+	return entropyFromIndices(indices)
+}
+
+// CanonicalMnemonic returns the mnemonic as its wordlist spells it: the words
+// of the wordlist, with their accents, joined by the separator of the
+// language, after checking its checksum. The language is detected unless it
+// is given. Mnemonics typed in upper case, with extra spaces or without
+// accents have the canonical mnemonic's seed.
+func CanonicalMnemonic(mnemonic string, langOpt ...Language) (string, error) {
+	words := SplitMnemonic(mnemonic)
+
+	var lang Language
+	if len(langOpt) > 0 {
+		lang = langOpt[0]
+	} else {
+		detected, err := detectLanguage(words)
+		if err != nil {
+			return "", err
+		}
+		lang = detected
+	}
+
+	indices, err := wordIndices(words, lang)
+	if err != nil {
+		return "", err
+	}
+	if _, err := entropyFromIndices(indices); err != nil {
+		return "", err
+	}
+	return joinIndices(indices, lang), nil
+}
+
+// ValidateMnemonic checks the words and the checksum of the mnemonic. The
+// language is detected unless it is given.
+func ValidateMnemonic(mnemonic string, langOpt ...Language) error {
+	_, err := EntropyFromMnemonic(mnemonic, langOpt...)
+	return err
+}
+
+// IsMnemonicValid returns whether the mnemonic is valid in the language, or
+// in any language if none is given.
+func IsMnemonicValid(mnemonic string, langOpt ...Language) bool {
+	return ValidateMnemonic(mnemonic, langOpt...) == nil
+}
+
+// DetectLanguage returns the language of the mnemonic, the first whose
+// wordlist contains all its words and validates its checksum. Some words are
+// in several wordlists, like "abandon" in English and French.
+func DetectLanguage(mnemonic string) (Language, error) {
+	return detectLanguage(SplitMnemonic(mnemonic))
+}
+
+// NewSeed returns the seed of the mnemonic and passphrase without validating
+// the checksum of the mnemonic. A mnemonic whose words are all in a wordlist
+// is seeded as the wordlist spells it, see CanonicalMnemonic, other mnemonics
+// as they are. Both are NFKD normalized as BIP-39 requires, which also turns
+// the ideographic spaces of Japanese mnemonics into spaces.
+func NewSeed(mnemonic string, passphrase string) []byte {
+	words := SplitMnemonic(mnemonic)
+	if lang, err := detectLanguage(words); err == nil {
+		if indices, err := wordIndices(words, lang); err == nil {
+			mnemonic = joinIndices(indices, lang)
+		}
+	}
+
+	return pbkdf2.Key(
+		[]byte(norm.NFKD.String(mnemonic)),
+		[]byte("mnemonic"+norm.NFKD.String(passphrase)),
+		2048, 64, sha512.New)
+}
+
+// NewSeedWithErrorChecking returns the seed of the canonical mnemonic and
+// passphrase after validating the mnemonic in any language.
+func NewSeedWithErrorChecking(
+	mnemonic string,
+	passphrase string,
+) ([]byte, error) {
+	canonical, err := CanonicalMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	return NewSeed(canonical, passphrase), nil
+}
+
+// SuggestWords returns the words of the language starting with the prefix,
+// English if none is given. The prefix is NFKD normalized, so precomposed
+// accented letters match, and may omit the accents of Latin wordlists.
+func SuggestWords(prefix string, langOpt ...Language) []string {
+	lang := English
+	if len(langOpt) > 0 {
+		lang = langOpt[0]
+	}
+	index, err := lang.index()
+	if err != nil {
+		return nil
+	}
+
+	prefix = normalize(prefix)
+	folded := foldAccents(prefix)
+
+	var suggestions []string
+	for _, word := range index.words {
+		normalized := normalize(word)
+		if strings.HasPrefix(normalized, prefix) ||
+			lang.foldsAccents() &&
+				strings.HasPrefix(foldAccents(normalized), folded) {
+			suggestions = append(suggestions, word)
+		}
+	}
+	return suggestions
+}
+
+// SplitMnemonic returns the NFKD normalized, lower case words of the mnemonic,
+// separated by any white space including ideographic spaces.
+func SplitMnemonic(mnemonic string) []string {
+	return strings.Fields(normalize(mnemonic))
+}
+
+// detectLanguage returns the first language whose wordlist contains all words
+// and validates their checksum, or whose wordlist contains the most words.
+func detectLanguage(words []string) (Language, error) {
+	if len(words) == 0 {
+		return 0, ErrInvalidWordCount
+	}
+
+	var (
+		best      Language
+		bestCount = 0
+	)
+	for _, lang := range languages {
+		index, err := lang.index()
+		if err != nil {
+			return 0, err
+		}
+
+		count := 0
+		indices := make([]int, 0, len(words))
+		for _, word := range words {
+			if i, ok := index.lookup(word); ok {
+				count++
+				indices = append(indices, i)
+			}
+		}
+
+		if count == len(words) {
+			if _, err := entropyFromIndices(indices); err == nil {
+				return lang, nil
+			}
+		}
+		if count > bestCount {
+			best, bestCount = lang, count
+		}
+	}
+
+	if bestCount == 0 {
+		return 0, ErrUnknownLanguage
+	}
+	// fall back to the closest wordlist, so the caller gets the error of
+	// the invalid word or checksum
+	return best, nil
+}
+
+// wordIndices returns the indexes of the words in the wordlist.
+func wordIndices(words []string, lang Language) ([]int, error) {
+	index, err := lang.index()
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]int, len(words))
+	for i, word := range words {
+		wordIndex, ok := index.lookup(word)
+		if !ok {
+			return nil, &WordError{
+				Index:       i,
+				Word:        word,
+				Language:    lang,
+				Suggestions: NearestWords(word, maxSuggestions, lang),
+			}
+		}
+		indices[i] = wordIndex
+	}
+	return indices, nil
+}
+
+// entropyFromIndices returns the entropy of the word indexes after checking
+// the checksum.
+func entropyFromIndices(indices []int) ([]byte, error) {
+	if len(indices) < 12 || len(indices) > 24 || len(indices)%3 != 0 {
+		return nil, ErrInvalidWordCount
+	}
+
+	value := new(big.Int)
+	for _, index := range indices {
+		value.Lsh(value, 11)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+
+	checksumBits := uint(len(indices) * 11 / 33)
+	checksum := new(big.Int).And(
+		value, big.NewInt(1<<checksumBits-1)).Int64()
+	value.Rsh(value, checksumBits)
+
+	entropy := value.FillBytes(make([]byte, len(indices)*4/3))
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum {
+		return nil, ErrInvalidChecksum
+	}
+	return entropy, nil
+}
+
+// validateEntropyBits checks the size of entropy.
+func validateEntropyBits(bits int) error {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return ErrInvalidEntropy
+	}
+	return nil
+}
+
+// normalize returns the NFKD normalized, lower case string.
+func normalize(s string) string {
+	return norm.NFKD.String(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// foldAccents removes the combining marks of the NFKD normalized string.
+func foldAccents(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
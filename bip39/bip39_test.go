@@ -0,0 +1,264 @@
+package bip39
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon " +
+	"abandon abandon abandon abandon abandon about"
+
+func TestNewSeed(t *testing.T) {
+	// the first vector of the reference implementation
+	const want = "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e534955" +
+		"31f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	seed, err := NewSeedWithErrorChecking(testMnemonic, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(seed); got != want {
+		t.Fatalf("seed %s, want %s", got, want)
+	}
+}
+
+// testMnemonicWithAccents returns a canonical mnemonic of the language with
+// a word spelt with an accent.
+func testMnemonicWithAccents(t *testing.T, lang Language) string {
+	entropy := make([]byte, 16)
+	for i := 0; i < 256; i++ {
+		for j := range entropy {
+			entropy[j] = byte(i + j*7)
+		}
+		mnemonic, err := NewMnemonic(entropy, lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if foldAccents(normalize(mnemonic)) != normalize(mnemonic) {
+			return mnemonic
+		}
+	}
+	t.Fatalf("no %v mnemonic with accents", lang)
+	return ""
+}
+
+func TestNewSeedCanonicalMnemonic(t *testing.T) {
+	spanish := testMnemonicWithAccents(t, Spanish)
+	japanese, err := NewMnemonic(make([]byte, 16), Japanese)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		canonical string
+		typed     string
+	}{
+		{"upper case", testMnemonic, strings.ToUpper(testMnemonic)},
+		{
+			"extra spaces",
+			testMnemonic,
+			"  " + strings.ReplaceAll(testMnemonic, " ", " \t ") + "\n",
+		},
+		{
+			"spanish without accents",
+			spanish,
+			foldAccents(normalize(spanish)),
+		},
+		{
+			"japanese with ascii spaces",
+			japanese,
+			strings.ReplaceAll(japanese, "　", " "),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			canonical, err := CanonicalMnemonic(test.typed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if canonical != test.canonical {
+				t.Fatalf("canonical mnemonic %q, want %q",
+					canonical, test.canonical)
+			}
+
+			want := NewSeed(test.canonical, "passphrase")
+			if seed := NewSeed(test.typed, "passphrase"); !bytes.Equal(
+				seed, want) {
+				t.Fatal("NewSeed does not seed the canonical mnemonic")
+			}
+			seed, err := NewSeedWithErrorChecking(test.typed, "passphrase")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(seed, want) {
+				t.Fatal("NewSeedWithErrorChecking does not seed the " +
+					"canonical mnemonic")
+			}
+		})
+	}
+}
+
+func TestCanonicalMnemonicInvalid(t *testing.T) {
+	tests := []struct {
+		mnemonic string
+		err      error
+	}{
+		{strings.Replace(testMnemonic, "about", "abandon", 1), ErrInvalidChecksum},
+		{strings.Replace(testMnemonic, "about", "abuot", 1), ErrUnknownWord},
+		{"abandon about", ErrInvalidWordCount},
+	}
+	for _, test := range tests {
+		if _, err := CanonicalMnemonic(test.mnemonic); !errors.Is(err, test.err) {
+			t.Fatalf("CanonicalMnemonic(%q) returned %v, want %v",
+				test.mnemonic, err, test.err)
+		}
+	}
+}
+
+func TestNearestWords(t *testing.T) {
+	tests := []struct {
+		word  string
+		count int
+		want  []string
+	}{
+		// words the input is a prefix of come first
+		{"ab", 2, []string{"abandon", "ability"}},
+		{"abando", 2, []string{"abandon", "bind"}},
+		// a transposition is a single edit
+		{"abuot", 2, []string{"about", "abuse"}},
+		// equally near words keep the wordlist order
+		{"acount", 3, []string{"account", "amount", "about"}},
+		{"zoo", 1, []string{"zoo"}},
+		{"zoo", 0, nil},
+	}
+	for _, test := range tests {
+		got := NearestWords(test.word, test.count)
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Fatalf("NearestWords(%q, %d) returned %v, want %v",
+				test.word, test.count, got, test.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"about", "about", 0},
+		{"kitten", "sitting", 3},
+		{"abuot", "about", 1},
+		{"abcd", "bacd", 1},
+		{"abcd", "badc", 2},
+		// a transposed pair is not edited again
+		{"ca", "abc", 3},
+	}
+	for _, test := range tests {
+		for _, pair := range [][2]string{{test.a, test.b}, {test.b, test.a}} {
+			got := editDistance([]rune(pair[0]), []rune(pair[1]))
+			if got != test.distance {
+				t.Fatalf("editDistance(%q, %q) returned %d, want %d",
+					pair[0], pair[1], got, test.distance)
+			}
+		}
+	}
+}
+
+// findCorrection returns the correction to the mnemonic, failing if there is
+// none.
+func findCorrection(
+	t *testing.T,
+	corrections []Correction,
+	mnemonic string,
+) Correction {
+	t.Helper()
+
+	for _, correction := range corrections {
+		if correction.Mnemonic == mnemonic {
+			return correction
+		}
+	}
+	t.Fatalf("no correction to %q", mnemonic)
+	return Correction{}
+}
+
+func TestChecksumCandidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		mnemonic string
+		want     Correction
+	}{
+		{
+			"unknown word",
+			strings.Replace(testMnemonic, "about", "abuot", 1),
+			Correction{testMnemonic, 11, "abuot", "about", 1},
+		},
+		{
+			"replaced word",
+			strings.Replace(testMnemonic, "about", "abandon", 1),
+			Correction{testMnemonic, 11, "abandon", "about", 5},
+		},
+		{
+			"missing word",
+			strings.Replace(testMnemonic, " about", "", 1),
+			Correction{testMnemonic, 11, "", "about", 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			corrections, err := ChecksumCandidates(test.mnemonic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := findCorrection(t, corrections, testMnemonic); got != test.want {
+				t.Fatalf("correction %+v, want %+v", got, test.want)
+			}
+
+			for i, correction := range corrections {
+				if _, err := CanonicalMnemonic(correction.Mnemonic); err != nil {
+					t.Fatalf("correction %q: %v", correction.Mnemonic, err)
+				}
+				if correction.Word == correction.Original {
+					t.Fatalf("correction %q keeps the word %q",
+						correction.Mnemonic, correction.Word)
+				}
+				if i > 0 && correction.Distance < corrections[i-1].Distance {
+					t.Fatal("corrections are not sorted by distance")
+				}
+			}
+		})
+	}
+
+	// an unknown word is only replaced at its position
+	corrections, err := ChecksumCandidates(tests[0].mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, correction := range corrections {
+		if correction.Index != 11 {
+			t.Fatalf("unknown word corrected at %d", correction.Index)
+		}
+	}
+	if corrections[0].Word != "about" {
+		t.Fatalf("nearest correction %q, want about", corrections[0].Word)
+	}
+
+	for _, test := range []struct {
+		mnemonic string
+		err      error
+	}{
+		{strings.Replace(testMnemonic, "abandon", "abandn", 2), ErrUncorrectable},
+		{strings.Replace(tests[2].mnemonic, "abandon", "abandn", 1), ErrUncorrectable},
+		{"abandon abandon abandon", ErrInvalidWordCount},
+	} {
+		if _, err := ChecksumCandidates(test.mnemonic); !errors.Is(err, test.err) {
+			t.Fatalf("ChecksumCandidates(%q) returned %v, want %v",
+				test.mnemonic, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package bip39
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the number of nearest words suggested for unknown words.
+const maxSuggestions = 3
+
+// ErrUncorrectable is returned when a mnemonic has more than one wrong or
+// missing word.
+var ErrUncorrectable = errors.New(
+	"mnemonic has more than one wrong or missing word")
+
+// WordError reports a word of a mnemonic that is not in the wordlist, with
+// the nearest words of the wordlist.
+type WordError struct {
+	// Index is the position of the word in the mnemonic, starting at 0.
+	Index       int
+	Word        string
+	Language    Language
+	Suggestions []string
+}
+
+// Error implements error.
+func (e *WordError) Error() string {
+	msg := fmt.Sprintf("%v: %q (word %d, %v)",
+		ErrUnknownWord, e.Word, e.Index+1, e.Language)
+	if len(e.Suggestions) > 0 {
+		msg += ", did you mean " + strings.Join(e.Suggestions, ", ")
+	}
+	return msg
+}
+
+// Unwrap returns ErrUnknownWord.
+func (e *WordError) Unwrap() error {
+	return ErrUnknownWord
+}
+
+// Correction is a mnemonic with a valid checksum differing from the input by
+// one replaced or inserted word.
+type Correction struct {
+	Mnemonic string
+	// Index is the position of the replaced or inserted word.
+	Index int
+	// Original is the replaced word, empty if the word was inserted.
+	Original string
+	Word     string
+	// Distance is the edit distance between the original and the new word.
+	Distance int
+}
+
+// NearestWords returns up to count words of the language nearest to the word,
+// English if none is given. Words the input is a prefix of come first, as
+// returned by SuggestWords, followed by the others by edit distance.
+func NearestWords(word string, count int, langOpt ...Language) []string {
+	lang := English
+	if len(langOpt) > 0 {
+		lang = langOpt[0]
+	}
+	index, err := lang.index()
+	if err != nil || count <= 0 {
+		return nil
+	}
+
+	word = normalize(word)
+	nearest := SuggestWords(word, lang)
+	if word == "" || len(nearest) >= count {
+		if len(nearest) > count {
+			nearest = nearest[:count]
+		}
+		return nearest
+	}
+
+	suggested := make(map[string]bool, len(nearest))
+	for _, suggestion := range nearest {
+		suggested[suggestion] = true
+	}
+
+	type candidate struct {
+		word     string
+		distance int
+	}
+	candidates := make([]candidate, 0, len(index.words))
+	for _, w := range index.words {
+		if !suggested[w] {
+			candidates = append(candidates,
+				candidate{w, lang.distance(word, normalize(w))})
+		}
+	}
+	// the sort is stable to keep the wordlist order of equally near words
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	if rest := count - len(nearest); rest < len(candidates) {
+		candidates = candidates[:rest]
+	}
+	for _, c := range candidates {
+		nearest = append(nearest, c.word)
+	}
+	return nearest
+}
+
+// ChecksumCandidates returns the corrections of a mnemonic with one wrong or
+// missing word that pass the checksum, nearest to the input first. A word
+// that is not in the wordlist is replaced by every word, otherwise every word
+// is tried at every position: replacing words if the mnemonic has a valid
+// word count, or inserting one if it is a word short. The language is
+// detected unless it is given.
+//
+// With a 4-bit checksum about one in 16 words passes for each position, so
+// the result must be narrowed down further, e.g. with a known address.
+func ChecksumCandidates(
+	mnemonic string,
+	langOpt ...Language,
+) ([]Correction, error) {
+	words := SplitMnemonic(mnemonic)
+
+	var lang Language
+	if len(langOpt) > 0 {
+		lang = langOpt[0]
+	} else {
+		detected, err := detectLanguage(words)
+		if err != nil {
+			return nil, err
+		}
+		lang = detected
+	}
+	index, err := lang.index()
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]int, len(words))
+	unknown := -1
+	for i, word := range words {
+		wordIndex, ok := index.lookup(word)
+		if !ok {
+			if unknown >= 0 {
+				return nil, ErrUncorrectable
+			}
+			unknown = i
+		}
+		indices[i] = wordIndex
+	}
+
+	var corrections []Correction
+	switch n := len(words); {
+	case n >= 12 && n <= 24 && n%3 == 0:
+		positions := []int{unknown}
+		if unknown < 0 {
+			positions = make([]int, n)
+			for i := range positions {
+				positions[i] = i
+			}
+		}
+		for _, position := range positions {
+			corrections = append(corrections, replacements(
+				words, indices, position, position != unknown, lang)...)
+		}
+
+	case n >= 11 && n <= 23 && n%3 == 2:
+		if unknown >= 0 {
+			return nil, ErrUncorrectable
+		}
+		for position := 0; position <= n; position++ {
+			corrections = append(corrections,
+				insertions(words, indices, position, lang)...)
+		}
+
+	default:
+		return nil, ErrInvalidWordCount
+	}
+
+	sort.SliceStable(corrections, func(i, j int) bool {
+		return corrections[i].Distance < corrections[j].Distance
+	})
+	return corrections, nil
+}
+
+// replacements returns the corrections replacing the word at the position,
+// leaving out the word itself if it is known.
+func replacements(
+	words []string,
+	indices []int,
+	position int,
+	known bool,
+	lang Language,
+) []Correction {
+	candidate := append([]int{}, indices...)
+	original := words[position]
+
+	var corrections []Correction
+	for i, word := range lang.Wordlist() {
+		if known && i == indices[position] {
+			continue
+		}
+		candidate[position] = i
+		if _, err := entropyFromIndices(candidate); err != nil {
+			continue
+		}
+		corrections = append(corrections, Correction{
+			Mnemonic: joinIndices(candidate, lang),
+			Index:    position,
+			Original: original,
+			Word:     word,
+			Distance: lang.distance(original, normalize(word)),
+		})
+	}
+	return corrections
+}
+
+// insertions returns the corrections inserting a word at the position.
+func insertions(
+	words []string,
+	indices []int,
+	position int,
+	lang Language,
+) []Correction {
+	candidate := make([]int, len(indices)+1)
+	copy(candidate, indices[:position])
+	copy(candidate[position+1:], indices[position:])
+
+	var corrections []Correction
+	for i, word := range lang.Wordlist() {
+		candidate[position] = i
+		if _, err := entropyFromIndices(candidate); err != nil {
+			continue
+		}
+		corrections = append(corrections, Correction{
+			Mnemonic: joinIndices(candidate, lang),
+			Index:    position,
+			Word:     word,
+		})
+	}
+	return corrections
+}
+
+// joinIndices returns the mnemonic of the word indexes.
+func joinIndices(indices []int, lang Language) string {
+	wordlist := lang.Wordlist()
+	words := make([]string, len(indices))
+	for i, index := range indices {
+		words[i] = wordlist[index]
+	}
+	return strings.Join(words, lang.Separator())
+}
+
+// distance returns the edit distance between two normalized words, counting
+// insertions, deletions, substitutions and transpositions of adjacent
+// characters. Accents are ignored for the languages that fold them.
+func (l Language) distance(a string, b string) int {
+	if l.foldsAccents() {
+		a, b = foldAccents(a), foldAccents(b)
+	}
+	return editDistance([]rune(a), []rune(b))
+}
+
+// editDistance returns the optimal string alignment distance between a and b.
+func editDistance(a []rune, b []rune) int {
+	// rows of the distances between prefixes of a and b, two rows back for
+	// transpositions
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,99 @@
+package bitcoin
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// AddressType is the kind of output script an address pays to.
+type AddressType int
+
+const (
+	// P2PKH is a legacy pay-to-pubkey-hash address, derived following
+	// BIP-44.
+	P2PKH AddressType = iota
+	// P2SHP2WPKH is a pay-to-witness-pubkey-hash address nested in a
+	// pay-to-script-hash address, derived following BIP-49.
+	P2SHP2WPKH
+	// P2WPKH is a native segwit pay-to-witness-pubkey-hash address, derived
+	// following BIP-84.
+	P2WPKH
+	// P2TR is a taproot address spendable by the key path only, derived
+	// following BIP-86.
+	P2TR
+)
+
+// addressTypes lists the supported address types.
+var addressTypes = []AddressType{P2PKH, P2SHP2WPKH, P2WPKH, P2TR}
+
+// String returns the name of the address type.
+func (t AddressType) String() string {
+	switch t {
+	case P2PKH:
+		return "p2pkh"
+	case P2SHP2WPKH:
+		return "p2sh-p2wpkh"
+	case P2WPKH:
+		return "p2wpkh"
+	case P2TR:
+		return "p2tr"
+	}
+	return fmt.Sprintf("unknown(%d)", int(t))
+}
+
+// Purpose returns the BIP-43 purpose of the derivation scheme of the address
+// type.
+func (t AddressType) Purpose() uint32 {
+	switch t {
+	case P2PKH:
+		return 44
+	case P2SHP2WPKH:
+		return 49
+	case P2WPKH:
+		return 84
+	case P2TR:
+		return 86
+	}
+	return 0
+}
+
+// NewAddress returns the address of the type paying to the public key on the
+// network.
+func NewAddress(
+	addrType AddressType,
+	publicKey *btcec.PublicKey,
+	params *chaincfg.Params,
+) (btcutil.Address, error) {
+	pubKeyHash := btcutil.Hash160(publicKey.SerializeCompressed())
+
+	switch addrType {
+	case P2PKH:
+		return btcutil.NewAddressPubKeyHash(pubKeyHash, params)
+
+	case P2SHP2WPKH:
+		witnessAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+			pubKeyHash, params)
+		if err != nil {
+			return nil, err
+		}
+		redeemScript, err := txscript.PayToAddrScript(witnessAddr)
+		if err != nil {
+			return nil, err
+		}
+		return btcutil.NewAddressScriptHash(redeemScript, params)
+
+	case P2WPKH:
+		return btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, params)
+
+	case P2TR:
+		outputKey := txscript.ComputeTaprootKeyNoScript(publicKey)
+		return btcutil.NewAddressTaproot(
+			schnorr.SerializePubKey(outputKey), params)
+	}
+	return nil, fmt.Errorf("unsupported address type %v", addrType)
+}
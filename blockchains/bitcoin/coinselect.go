@@ -0,0 +1,652 @@
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// txOverheadWeight is the weight of the version, lock time, input and
+	// output counts and the segwit marker and flag of a transaction.
+	txOverheadWeight = (4+4+1+1)*4 + 2
+
+	// dustRelayFeeRate is the fee rate in sat/vB below which spending an
+	// output costs more than it is worth, as used by Bitcoin Core's policy.
+	dustRelayFeeRate = 3
+
+	// bnbMaxTries bounds the branches explored by branch and bound.
+	bnbMaxTries = 100000
+	// knapsackIterations is the number of random subsets tried by knapsack.
+	knapsackIterations = 1000
+)
+
+var (
+	// ErrInsufficientFunds is returned when the spendable outputs can't pay
+	// for the outputs and the fee.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrNoChangelessSelection is returned by branch and bound when no subset
+	// of the outputs pays the target without change.
+	ErrNoChangelessSelection = errors.New("no changeless selection")
+	// ErrDustOutput is returned for outputs below the dust threshold.
+	ErrDustOutput = errors.New("output is dust")
+	// ErrUTXOLocked is returned when locking an output already locked.
+	ErrUTXOLocked = errors.New("utxo is locked")
+)
+
+// FeeRate is a fee rate in satoshis per virtual byte.
+type FeeRate float64
+
+// Fee returns the fee of the weight at the fee rate. The virtual size and the
+// fee are rounded up, so the fees of the parts of a transaction add up to at
+// least the fee of the whole.
+func (r FeeRate) Fee(weight int64) btcutil.Amount {
+	vsize := (weight + 3) / 4
+	return btcutil.Amount(math.Ceil(float64(r) * float64(vsize)))
+}
+
+// InputWeight returns the weight of an input spending the output script,
+// assuming a 72-byte signature. Nested P2SH outputs are assumed to be
+// P2SH-P2WPKH.
+func InputWeight(pkScript []byte) (int64, error) {
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.PubKeyHashTy:
+		// outpoint, script length, signature and public key, sequence
+		return (32 + 4 + 1 + 107 + 4) * 4, nil
+	case txscript.ScriptHashTy:
+		// the redeem script in the script sig, signature and public key
+		// in the witness
+		return (32+4+1+23+4)*4 + 108, nil
+	case txscript.WitnessV0PubKeyHashTy:
+		return (32+4+1+4)*4 + 108, nil
+	case txscript.WitnessV1TaprootTy:
+		// schnorr signature with the default sighash type
+		return (32+4+1+4)*4 + 66, nil
+	}
+	return 0, fmt.Errorf("unsupported output script %x", pkScript)
+}
+
+// OutputWeight returns the weight of an output paying to the script.
+func OutputWeight(pkScript []byte) int64 {
+	return int64(8+wire.VarIntSerializeSize(uint64(len(pkScript)))+
+		len(pkScript)) * 4
+}
+
+// DustThreshold returns the smallest amount an output paying to the script
+// must carry to be relayed, following Bitcoin Core's dust policy.
+func DustThreshold(pkScript []byte) btcutil.Amount {
+	// size of the output and of an input spending it
+	size := OutputWeight(pkScript) / 4
+	if txscript.IsWitnessProgram(pkScript) {
+		size += 32 + 4 + 1 + 4 + 107/4
+	} else {
+		size += 32 + 4 + 1 + 107 + 4
+	}
+	return btcutil.Amount(dustRelayFeeRate * size)
+}
+
+// Strategy is a coin selection algorithm.
+type Strategy int
+
+const (
+	// DefaultStrategy tries a changeless selection with branch and bound and
+	// falls back to knapsack.
+	DefaultStrategy Strategy = iota
+	// BranchAndBound searches for a subset of the outputs paying the target
+	// without change, wasting as little as possible to the fee.
+	BranchAndBound
+	// Knapsack approximates the subset of the outputs paying the target and
+	// the change best, preferring smaller outputs.
+	Knapsack
+	// LargestFirst selects the largest outputs until the target is paid,
+	// spending as few outputs as possible.
+	LargestFirst
+)
+
+// String returns the name of the strategy.
+func (s Strategy) String() string {
+	switch s {
+	case DefaultStrategy:
+		return "default"
+	case BranchAndBound:
+		return "branch-and-bound"
+	case Knapsack:
+		return "knapsack"
+	case LargestFirst:
+		return "largest-first"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// SelectionRequest describes the payment to select coins for.
+type SelectionRequest struct {
+	Outputs []Output
+	FeeRate FeeRate
+	// ChangeAddress receives the change. It is required unless the strategy
+	// is BranchAndBound.
+	ChangeAddress btcutil.Address
+}
+
+// Selection is the result of a coin selection.
+type Selection struct {
+	Inputs []UTXO
+	// Outputs are the outputs of the request followed by the change output,
+	// if any.
+	Outputs []Output
+	Fee     btcutil.Amount
+	Change  btcutil.Amount
+}
+
+// OutPoints returns the outpoints of the selected inputs.
+func (s *Selection) OutPoints() []wire.OutPoint {
+	outpoints := make([]wire.OutPoint, len(s.Inputs))
+	for i, utxo := range s.Inputs {
+		outpoints[i] = utxo.OutPoint
+	}
+	return outpoints
+}
+
+// coin is an output with the fee of spending it deducted.
+type coin struct {
+	utxo      UTXO
+	effective btcutil.Amount
+}
+
+// SelectCoins selects the outputs among the UTXOs paying for the request with
+// the strategy. Outputs of unsupported scripts and outputs worth less than the
+// fee to spend them are ignored. Knapsack draws its random subsets from the
+// source of randomness if one is given, e.g. a seeded one for reproducible
+// selections, and from the default source of math/rand otherwise.
+func SelectCoins(
+	strategy Strategy,
+	utxos []UTXO,
+	req SelectionRequest,
+	randOpt ...*rand.Rand,
+) (*Selection, error) {
+	var rng *rand.Rand
+	if len(randOpt) > 0 {
+		rng = randOpt[0]
+	}
+
+	if len(req.Outputs) == 0 {
+		return nil, errors.New("no outputs to pay")
+	}
+	if req.FeeRate <= 0 {
+		return nil, errors.New("fee rate must be positive")
+	}
+	if req.ChangeAddress == nil && strategy != BranchAndBound {
+		return nil, errors.New("change address is required")
+	}
+
+	// target is the amount the effective values of the inputs must pay
+	weight := int64(txOverheadWeight)
+	var target btcutil.Amount
+	for _, output := range req.Outputs {
+		pkScript, err := txscript.PayToAddrScript(output.Address)
+		if err != nil {
+			return nil, err
+		}
+		if output.Amount < DustThreshold(pkScript) {
+			return nil, fmt.Errorf("%w: %v to %v",
+				ErrDustOutput, output.Amount, output.Address)
+		}
+		weight += OutputWeight(pkScript)
+		target += output.Amount
+	}
+	target += req.FeeRate.Fee(weight)
+
+	// changeCost is the fee of the change output and changeMin the least
+	// amount worth creating a change output for. Without a change address,
+	// they bound the excess branch and bound may waste, as for P2WPKH change.
+	changeScript := append(
+		[]byte{txscript.OP_0, txscript.OP_DATA_20}, make([]byte, 20)...)
+	if req.ChangeAddress != nil {
+		var err error
+		if changeScript, err = txscript.PayToAddrScript(
+			req.ChangeAddress); err != nil {
+			return nil, err
+		}
+	}
+	changeCost := req.FeeRate.Fee(OutputWeight(changeScript))
+	changeMin := DustThreshold(changeScript)
+
+	var coins []coin
+	var available btcutil.Amount
+	for _, utxo := range utxos {
+		weight, err := InputWeight(utxo.PkScript)
+		if err != nil {
+			continue
+		}
+		effective := utxo.Amount - req.FeeRate.Fee(weight)
+		if effective <= 0 {
+			continue
+		}
+		coins = append(coins, coin{utxo: utxo, effective: effective})
+		available += effective
+	}
+	if available < target {
+		return nil, fmt.Errorf("%w: %v available, %v needed",
+			ErrInsufficientFunds, available, target)
+	}
+
+	var selected []coin
+	switch strategy {
+	case DefaultStrategy:
+		selected = branchAndBound(coins, target, changeCost+changeMin)
+		if selected == nil {
+			selected = knapsack(coins, target, changeCost+changeMin, rng)
+		}
+	case BranchAndBound:
+		selected = branchAndBound(coins, target, changeCost+changeMin)
+		if selected == nil {
+			return nil, ErrNoChangelessSelection
+		}
+	case Knapsack:
+		selected = knapsack(coins, target, changeCost+changeMin, rng)
+	case LargestFirst:
+		selected = largestFirst(coins, target)
+	default:
+		return nil, fmt.Errorf("unknown coin selection strategy %v", strategy)
+	}
+
+	selection := &Selection{
+		Outputs: append([]Output{}, req.Outputs...),
+	}
+	var inputSum, effectiveSum, outputSum btcutil.Amount
+	for _, c := range selected {
+		selection.Inputs = append(selection.Inputs, c.utxo)
+		inputSum += c.utxo.Amount
+		effectiveSum += c.effective
+	}
+	for _, output := range req.Outputs {
+		outputSum += output.Amount
+	}
+
+	if excess := effectiveSum - target; req.ChangeAddress != nil &&
+		excess >= changeCost+changeMin {
+		selection.Change = excess - changeCost
+		selection.Outputs = append(selection.Outputs, Output{
+			Address: req.ChangeAddress,
+			Amount:  selection.Change,
+		})
+	}
+	selection.Fee = inputSum - outputSum - selection.Change
+	return selection, nil
+}
+
+// CoinSelector selects coins from a UTXO source. Outputs can be frozen to
+// keep them from being spent, and selected outputs are locked until they are
+// unlocked, so concurrent spends do not select the same outputs.
+type CoinSelector struct {
+	source   UTXOSource
+	strategy Strategy
+	rand     *rand.Rand
+
+	lock   sync.Mutex
+	locked map[wire.OutPoint]struct{}
+	frozen map[wire.OutPoint]struct{}
+}
+
+// NewCoinSelector creates a coin selector for the source, using the default
+// strategy.
+func NewCoinSelector(source UTXOSource) *CoinSelector {
+	return &CoinSelector{
+		source: source,
+		locked: map[wire.OutPoint]struct{}{},
+		frozen: map[wire.OutPoint]struct{}{},
+	}
+}
+
+// SetStrategy sets the coin selection algorithm.
+func (s *CoinSelector) SetStrategy(strategy Strategy) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.strategy = strategy
+}
+
+// SetRand sets the source of randomness of the knapsack strategy, e.g. a
+// seeded one for reproducible selections. A nil source restores the default
+// source of math/rand.
+func (s *CoinSelector) SetRand(rng *rand.Rand) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rand = rng
+}
+
+// Select selects the outputs paying for the request among the unlocked and
+// unfrozen outputs of the source and locks them. The outputs should be
+// unlocked if the transaction is abandoned.
+func (s *CoinSelector) Select(
+	ctx context.Context,
+	req SelectionRequest,
+) (*Selection, error) {
+	utxos, err := s.source.UTXOs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	spendable := make([]UTXO, 0, len(utxos))
+	for _, utxo := range utxos {
+		if s.isSpendable(utxo.OutPoint) {
+			spendable = append(spendable, utxo)
+		}
+	}
+
+	selection, err := SelectCoins(s.strategy, spendable, req, s.rand)
+	if err != nil {
+		return nil, err
+	}
+	for _, utxo := range selection.Inputs {
+		s.locked[utxo.OutPoint] = struct{}{}
+	}
+	return selection, nil
+}
+
+// Spendable returns the unlocked and unfrozen outputs of the source.
+func (s *CoinSelector) Spendable(ctx context.Context) ([]UTXO, error) {
+	utxos, err := s.source.UTXOs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	spendable := make([]UTXO, 0, len(utxos))
+	for _, utxo := range utxos {
+		if s.isSpendable(utxo.OutPoint) {
+			spendable = append(spendable, utxo)
+		}
+	}
+	return spendable, nil
+}
+
+// Lock locks the outputs, e.g. while a transaction spending them is built
+// outside the selector. Either all outputs are locked or, if one is already
+// locked, none is.
+func (s *CoinSelector) Lock(outpoints ...wire.OutPoint) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, outpoint := range outpoints {
+		if _, ok := s.locked[outpoint]; ok {
+			return fmt.Errorf("%w: %v", ErrUTXOLocked, outpoint)
+		}
+	}
+	for _, outpoint := range outpoints {
+		s.locked[outpoint] = struct{}{}
+	}
+	return nil
+}
+
+// Unlock unlocks the outputs.
+func (s *CoinSelector) Unlock(outpoints ...wire.OutPoint) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, outpoint := range outpoints {
+		delete(s.locked, outpoint)
+	}
+}
+
+// Freeze excludes the outputs from coin selection until they are unfrozen.
+func (s *CoinSelector) Freeze(outpoints ...wire.OutPoint) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, outpoint := range outpoints {
+		s.frozen[outpoint] = struct{}{}
+	}
+}
+
+// Unfreeze makes the outputs available to coin selection again.
+func (s *CoinSelector) Unfreeze(outpoints ...wire.OutPoint) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, outpoint := range outpoints {
+		delete(s.frozen, outpoint)
+	}
+}
+
+// IsLocked returns whether the output is locked.
+func (s *CoinSelector) IsLocked(outpoint wire.OutPoint) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, ok := s.locked[outpoint]
+	return ok
+}
+
+// IsFrozen returns whether the output is frozen.
+func (s *CoinSelector) IsFrozen(outpoint wire.OutPoint) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, ok := s.frozen[outpoint]
+	return ok
+}
+
+// isSpendable returns whether the output is neither locked nor frozen. The
+// caller must hold the lock.
+func (s *CoinSelector) isSpendable(outpoint wire.OutPoint) bool {
+	if _, ok := s.locked[outpoint]; ok {
+		return false
+	}
+	_, ok := s.frozen[outpoint]
+	return !ok
+}
+
+// branchAndBound searches depth first for the subset of the coins whose
+// effective value exceeds the target by less than the cost of change, and by
+// the least amount, as Bitcoin Core does. It returns nil if there is none.
+func branchAndBound(
+	coins []coin,
+	target btcutil.Amount,
+	costOfChange btcutil.Amount,
+) []coin {
+	sorted := append([]coin{}, coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].effective > sorted[j].effective
+	})
+
+	var available btcutil.Amount
+	for _, c := range sorted {
+		available += c.effective
+	}
+
+	var (
+		current    btcutil.Amount
+		selected   []int
+		best       []int
+		bestExcess btcutil.Amount
+		upperBound = target + costOfChange
+		index      = 0
+	)
+	for try := 0; try < bnbMaxTries; try, index = try+1, index+1 {
+		backtrack := false
+		if current+available < target || current > upperBound {
+			backtrack = true
+		} else if current >= target {
+			if excess := current - target; best == nil || excess < bestExcess {
+				best = append([]int{}, selected...)
+				bestExcess = excess
+				if excess == 0 {
+					break
+				}
+			}
+			backtrack = true
+		}
+
+		if backtrack {
+			if len(selected) == 0 {
+				break
+			}
+			// return the omitted coins to the available value and exclude
+			// the last included coin
+			last := selected[len(selected)-1]
+			for index--; index > last; index-- {
+				available += sorted[index].effective
+			}
+			current -= sorted[last].effective
+			selected = selected[:len(selected)-1]
+			continue
+		}
+
+		available -= sorted[index].effective
+		// skip including a coin equal to a previous excluded one, as that
+		// branch was already explored
+		if len(selected) == 0 || index-1 == selected[len(selected)-1] ||
+			sorted[index].effective != sorted[index-1].effective {
+			selected = append(selected, index)
+			current += sorted[index].effective
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+	result := make([]coin, len(best))
+	for i, index := range best {
+		result[i] = sorted[index]
+	}
+	return result
+}
+
+// knapsack selects the coins as Bitcoin Core's knapsack solver does: an exact
+// match, all coins smaller than the target with change if they pay exactly,
+// else the best of random subsets of the smaller coins or the smallest larger
+// coin. The coins must pay the target. Random subsets are drawn from rng, or
+// from the default source of math/rand if it is nil.
+func knapsack(
+	coins []coin,
+	target btcutil.Amount,
+	changeTarget btcutil.Amount,
+	rng *rand.Rand,
+) []coin {
+	total := target + changeTarget
+
+	var lower []coin
+	var lowerSum btcutil.Amount
+	var larger *coin
+	for i, c := range coins {
+		switch {
+		case c.effective == target:
+			return []coin{c}
+		case c.effective < total:
+			lower = append(lower, c)
+			lowerSum += c.effective
+		case larger == nil || c.effective < larger.effective:
+			larger = &coins[i]
+		}
+	}
+
+	if lowerSum == target || lowerSum == total {
+		return lower
+	}
+	if lowerSum < total {
+		if larger == nil {
+			// the smaller coins pay the target, without change
+			return lower
+		}
+		return []coin{*larger}
+	}
+
+	sort.SliceStable(lower, func(i, j int) bool {
+		return lower[i].effective > lower[j].effective
+	})
+	best, bestSum := approximateBestSubset(lower, total, rng)
+	if larger != nil && larger.effective <= bestSum {
+		return []coin{*larger}
+	}
+	return best
+}
+
+// approximateBestSubset returns the subset of the coins paying the target by
+// the least amount among random subsets.
+func approximateBestSubset(
+	coins []coin,
+	target btcutil.Amount,
+	rng *rand.Rand,
+) ([]coin, btcutil.Amount) {
+	intn := rand.Intn
+	if rng != nil {
+		intn = rng.Intn
+	}
+
+	best := make([]bool, len(coins))
+	var bestSum btcutil.Amount
+	for i, c := range coins {
+		best[i] = true
+		bestSum += c.effective
+	}
+
+	included := make([]bool, len(coins))
+	for rep := 0; rep < knapsackIterations && bestSum != target; rep++ {
+		for i := range included {
+			included[i] = false
+		}
+		var sum btcutil.Amount
+		reached := false
+
+		for pass := 0; pass < 2 && !reached; pass++ {
+			for i, c := range coins {
+				// the first pass includes coins at random, the second
+				// includes the left out ones in turn
+				if pass == 0 && intn(2) == 0 || pass == 1 && included[i] {
+					continue
+				}
+
+				sum += c.effective
+				included[i] = true
+				if sum >= target {
+					reached = true
+					if sum < bestSum {
+						bestSum = sum
+						copy(best, included)
+					}
+					sum -= c.effective
+					included[i] = false
+				}
+			}
+		}
+	}
+
+	var subset []coin
+	for i, c := range coins {
+		if best[i] {
+			subset = append(subset, c)
+		}
+	}
+	return subset, bestSum
+}
+
+// largestFirst selects the largest coins until they pay the target.
+func largestFirst(coins []coin, target btcutil.Amount) []coin {
+	sorted := append([]coin{}, coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].effective > sorted[j].effective
+	})
+
+	var sum btcutil.Amount
+	for i, c := range sorted {
+		sum += c.effective
+		if sum >= target {
+			return sorted[:i+1]
+		}
+	}
+	return sorted
+}
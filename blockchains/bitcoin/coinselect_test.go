@@ -0,0 +1,271 @@
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// At 1 sat/vB, a P2WPKH input costs 68 sats and a payment to a P2WPKH output
+// needs its amount and 42 sats of fee. P2WPKH change costs 31 sats and must
+// carry at least 294.
+const (
+	testInputFee   = 68
+	testPaymentFee = 42
+	testChangeCost = 31
+	testChangeMin  = 294
+)
+
+// testAddress returns a P2WPKH address of the hash byte.
+func testAddress(t *testing.T, b byte) btcutil.Address {
+	hash := make([]byte, 20)
+	hash[0] = b
+	address, err := btcutil.NewAddressWitnessPubKeyHash(
+		hash, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address
+}
+
+// testUTXOs returns P2WPKH outputs of the effective values at 1 sat/vB.
+func testUTXOs(t *testing.T, effective ...btcutil.Amount) []UTXO {
+	pkScript, err := txscript.PayToAddrScript(testAddress(t, 0xff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	utxos := make([]UTXO, len(effective))
+	for i, value := range effective {
+		utxos[i] = testUTXO(uint32(i), value+testInputFee, pkScript)
+	}
+	return utxos
+}
+
+// testRequest returns a request paying the amount at 1 sat/vB.
+func testRequest(t *testing.T, amount btcutil.Amount) SelectionRequest {
+	return SelectionRequest{
+		Outputs:       []Output{{Address: testAddress(t, 1), Amount: amount}},
+		FeeRate:       1,
+		ChangeAddress: testAddress(t, 2),
+	}
+}
+
+// effectiveSum returns the sum of the effective values of the inputs.
+func effectiveSum(selection *Selection) btcutil.Amount {
+	var sum btcutil.Amount
+	for _, utxo := range selection.Inputs {
+		sum += utxo.Amount - testInputFee
+	}
+	return sum
+}
+
+func TestBranchAndBound(t *testing.T) {
+	target := btcutil.Amount(50000 + testPaymentFee)
+	utxos := testUTXOs(t, 30000, 60000, 20000+testPaymentFee, 100000)
+
+	for _, strategy := range []Strategy{BranchAndBound, DefaultStrategy} {
+		t.Run(strategy.String(), func(t *testing.T) {
+			selection, err := SelectCoins(strategy, utxos, testRequest(t, 50000))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(selection.Inputs) != 2 || effectiveSum(selection) != target {
+				t.Fatalf("selected %v, want the exact match", selection.Inputs)
+			}
+			if selection.Change != 0 || len(selection.Outputs) != 1 {
+				t.Fatalf("changeless selection has change %v",
+					selection.Change)
+			}
+			if selection.Fee != 2*testInputFee+testPaymentFee {
+				t.Fatalf("fee %v, want %v",
+					selection.Fee, 2*testInputFee+testPaymentFee)
+			}
+		})
+	}
+
+	// no subset comes within the cost of change of the target
+	_, err := SelectCoins(BranchAndBound, testUTXOs(t, 30000, 100000),
+		testRequest(t, 50000))
+	if !errors.Is(err, ErrNoChangelessSelection) {
+		t.Fatalf("SelectCoins returned %v, want %v",
+			err, ErrNoChangelessSelection)
+	}
+}
+
+func TestKnapsack(t *testing.T) {
+	// the best subsets of the smaller outputs, {30000, 25000} and
+	// {25000, 20000, 10000}, are worth less than the larger output
+	utxos := testUTXOs(t, 30000, 10000, 100000, 25000, 20000)
+	req := testRequest(t, 50000)
+
+	var first *Selection
+	for i := 0; i < 2; i++ {
+		selection, err := SelectCoins(
+			Knapsack, utxos, req, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum := effectiveSum(selection); sum != 55000 {
+			t.Fatalf("selected %v worth %v, want 55000",
+				selection.Inputs, sum)
+		}
+		want := 55000 - 50000 - testPaymentFee - testChangeCost
+		if selection.Change != btcutil.Amount(want) {
+			t.Fatalf("change %v, want %v", selection.Change, want)
+		}
+
+		if first == nil {
+			first = selection
+		} else if !reflect.DeepEqual(first.OutPoints(), selection.OutPoints()) {
+			t.Fatalf("seeded selections differ: %v and %v",
+				first.OutPoints(), selection.OutPoints())
+		}
+	}
+
+	// the smaller outputs falling short, the smallest larger one is spent
+	selection, err := SelectCoins(Knapsack,
+		testUTXOs(t, 10000, 200000, 100000, 20000), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if effectiveSum(selection) != 100000 {
+		t.Fatalf("selected %v, want the 100000 output", selection.Inputs)
+	}
+}
+
+func TestLargestFirst(t *testing.T) {
+	utxos := testUTXOs(t, 10000, 40000, 20000, 30000)
+
+	selection, err := SelectCoins(LargestFirst, utxos, testRequest(t, 50000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []wire.OutPoint{utxos[1].OutPoint, utxos[3].OutPoint}
+	if !reflect.DeepEqual(selection.OutPoints(), want) {
+		t.Fatalf("selected %v, want %v", selection.OutPoints(), want)
+	}
+	if change := btcutil.Amount(
+		70000 - 50000 - testPaymentFee - testChangeCost); selection.Change != change {
+		t.Fatalf("change %v, want %v", selection.Change, change)
+	}
+}
+
+func TestChangeThreshold(t *testing.T) {
+	target := btcutil.Amount(50000 + testPaymentFee)
+	tests := []struct {
+		name   string
+		excess btcutil.Amount
+		change btcutil.Amount
+	}{
+		{"below", testChangeCost + testChangeMin - 1, 0},
+		{"at", testChangeCost + testChangeMin, testChangeMin},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selection, err := SelectCoins(LargestFirst,
+				testUTXOs(t, target+test.excess), testRequest(t, 50000))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if selection.Change != test.change {
+				t.Fatalf("change %v, want %v", selection.Change, test.change)
+			}
+			if test.change == 0 && len(selection.Outputs) != 1 {
+				t.Fatal("change output below the threshold")
+			}
+		})
+	}
+}
+
+func TestDustAndInsufficientFunds(t *testing.T) {
+	utxos := testUTXOs(t, 100000)
+
+	_, err := SelectCoins(LargestFirst, utxos, testRequest(t, testChangeMin-1))
+	if !errors.Is(err, ErrDustOutput) {
+		t.Fatalf("SelectCoins returned %v, want %v", err, ErrDustOutput)
+	}
+	if _, err := SelectCoins(
+		LargestFirst, utxos, testRequest(t, testChangeMin)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = SelectCoins(LargestFirst, utxos, testRequest(t, 100000))
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("SelectCoins returned %v, want %v", err, ErrInsufficientFunds)
+	}
+}
+
+func TestCoinSelectorConcurrentSelect(t *testing.T) {
+	const spends = 16
+	effective := make([]btcutil.Amount, spends)
+	for i := range effective {
+		effective[i] = 100000
+	}
+	utxos := testUTXOs(t, effective...)
+	s := NewCoinSelector(NewMemorySource(utxos...))
+	s.SetStrategy(LargestFirst)
+
+	// frozen outputs are never selected
+	s.Freeze(utxos[0].OutPoint)
+
+	req := testRequest(t, 50000)
+	var wg sync.WaitGroup
+	selections := make([]*Selection, spends)
+	errs := make([]error, spends)
+	for i := 0; i < spends; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			selections[i], errs[i] = s.Select(context.Background(), req)
+		}(i)
+	}
+	wg.Wait()
+
+	selected := map[wire.OutPoint]bool{}
+	var failed int
+	for i, err := range errs {
+		if errors.Is(err, ErrInsufficientFunds) {
+			failed++
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		for _, outpoint := range selections[i].OutPoints() {
+			if selected[outpoint] {
+				t.Fatalf("%v selected twice", outpoint)
+			}
+			selected[outpoint] = true
+			if !s.IsLocked(outpoint) {
+				t.Fatalf("%v selected but not locked", outpoint)
+			}
+		}
+	}
+	if selected[utxos[0].OutPoint] {
+		t.Fatal("frozen output selected")
+	}
+	if failed != 1 {
+		t.Fatalf("%d selections failed, want 1", failed)
+	}
+
+	// unlocked outputs can be selected again
+	s.Unlock(utxos[1].OutPoint)
+	selection, err := s.Select(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []wire.OutPoint{utxos[1].OutPoint}; !reflect.DeepEqual(
+		selection.OutPoints(), want) {
+		t.Fatalf("selected %v, want %v", selection.OutPoints(), want)
+	}
+	if err := s.Lock(utxos[1].OutPoint); !errors.Is(err, ErrUTXOLocked) {
+		t.Fatalf("Lock returned %v, want %v", err, ErrUTXOLocked)
+	}
+}
@@ -0,0 +1,108 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// DerivationPath is a BIP-32 derivation path from the master key, with
+// hardened indexes offset by hdkeychain.HardenedKeyStart.
+type DerivationPath []uint32
+
+// ParseDerivationPath parses a derivation path like m/84'/0'/0'/0/0. Hardened
+// indexes are marked with ' or h.
+func ParseDerivationPath(path string) (DerivationPath, error) {
+	elems := strings.Split(strings.TrimSpace(path), "/")
+	if len(elems) == 0 || elems[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", path)
+	}
+	if len(elems) == 1 {
+		return nil, errors.New("empty derivation path")
+	}
+
+	parsed := make(DerivationPath, 0, len(elems)-1)
+	for _, elem := range elems[1:] {
+		hardened := strings.HasSuffix(elem, "'") || strings.HasSuffix(elem, "h")
+		if hardened {
+			elem = elem[:len(elem)-1]
+		}
+
+		index, err := strconv.ParseUint(elem, 10, 32)
+		if err != nil || index >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("invalid derivation path element %q", elem)
+		}
+		if hardened {
+			index += hdkeychain.HardenedKeyStart
+		}
+		parsed = append(parsed, uint32(index))
+	}
+	return parsed, nil
+}
+
+// MustParseDerivationPath parses the derivation path but will panic if it
+// can't parse it.
+func MustParseDerivationPath(path string) DerivationPath {
+	parsed, err := ParseDerivationPath(path)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// DefaultPath returns the path of the address at the index of the account,
+// following the BIP-44 derivation scheme of the address type:
+// m/purpose'/coin_type'/account'/change/index.
+func DefaultPath(
+	addrType AddressType,
+	params *chaincfg.Params,
+	account uint32,
+	change bool,
+	index uint32,
+) DerivationPath {
+	var chain uint32
+	if change {
+		chain = 1
+	}
+	return DerivationPath{
+		addrType.Purpose() + hdkeychain.HardenedKeyStart,
+		params.HDCoinType + hdkeychain.HardenedKeyStart,
+		account + hdkeychain.HardenedKeyStart,
+		chain,
+		index,
+	}
+}
+
+// String returns the path in the m/84'/0'/0'/0/0 format.
+func (p DerivationPath) String() string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, index := range p {
+		b.WriteString("/")
+		if index >= hdkeychain.HardenedKeyStart {
+			b.WriteString(strconv.FormatUint(
+				uint64(index-hdkeychain.HardenedKeyStart), 10) + "'")
+		} else {
+			b.WriteString(strconv.FormatUint(uint64(index), 10))
+		}
+	}
+	return b.String()
+}
+
+// addressType returns the address type of the path, inferred from its BIP-44
+// purpose.
+func (p DerivationPath) addressType() (AddressType, error) {
+	if len(p) == 0 {
+		return 0, errors.New("empty derivation path")
+	}
+	for _, addrType := range addressTypes {
+		if p[0] == addrType.Purpose()+hdkeychain.HardenedKeyStart {
+			return addrType, nil
+		}
+	}
+	return 0, fmt.Errorf("no address type for the purpose of %s", p)
+}
@@ -0,0 +1,537 @@
+package bitcoin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Output is a payment of a transaction.
+type Output struct {
+	Address btcutil.Address
+	Amount  btcutil.Amount
+}
+
+// CreatePSBT builds an unsigned BIP-174 PSBT spending the UTXOs to the outputs.
+// Inputs and outputs belonging to accounts pinned to the wallet get their
+// BIP-32 derivation info, so the wallet, or a hardware signer sharing the
+// seed, can sign them and recognize change. Any amount not paid to the outputs
+// goes to the fee.
+func (w *Wallet) CreatePSBT(utxos []UTXO, outputs []Output) (*psbt.Packet, error) {
+	if len(utxos) == 0 {
+		return nil, errors.New("no inputs to spend")
+	}
+	if len(outputs) == 0 {
+		return nil, errors.New("no outputs to pay")
+	}
+
+	var inputs []*wire.OutPoint
+	var inputSum btcutil.Amount
+	for i := range utxos {
+		inputs = append(inputs, &utxos[i].OutPoint)
+		inputSum += utxos[i].Amount
+	}
+
+	var txOuts []*wire.TxOut
+	var outputSum btcutil.Amount
+	for _, output := range outputs {
+		pkScript, err := txscript.PayToAddrScript(output.Address)
+		if err != nil {
+			return nil, err
+		}
+		txOuts = append(txOuts, wire.NewTxOut(int64(output.Amount), pkScript))
+		outputSum += output.Amount
+	}
+	if outputSum > inputSum {
+		return nil, fmt.Errorf(
+			"outputs of %v exceed inputs of %v", outputSum, inputSum)
+	}
+
+	sequences := make([]uint32, len(inputs))
+	for i := range sequences {
+		sequences[i] = wire.MaxTxInSequenceNum - 2 // signal RBF
+	}
+
+	packet, err := psbt.New(inputs, txOuts, 2, 0, sequences)
+	if err != nil {
+		return nil, err
+	}
+	updater, err := psbt.NewUpdater(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, utxo := range utxos {
+		if err := w.updateInput(updater, i, utxo); err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+	for i, txOut := range txOuts {
+		if err := w.updateOutput(updater, i, txOut.PkScript); err != nil {
+			return nil, fmt.Errorf("output %d: %w", i, err)
+		}
+	}
+	return packet, nil
+}
+
+// SignPSBT signs every input of the PSBT the wallet owns and returns the
+// number of inputs it signed. An input is owned when its BIP-32 derivation
+// info carries the master fingerprint of the wallet and derives to the listed
+// public key, or when it spends the address of a pinned account. This makes
+// PSBTs created by other wallets or coordinators signable as well. Owned
+// inputs not spending a single-key script of the key, like the multisig
+// inputs of multi-party PSBTs, are left for other signers.
+func (w *Wallet) SignPSBT(packet *psbt.Packet) (int, error) {
+	fetcher, err := prevOutFetcher(packet)
+	if err != nil {
+		return 0, err
+	}
+	sigHashes := txscript.NewTxSigHashes(packet.UnsignedTx, fetcher)
+
+	updater, err := psbt.NewUpdater(packet)
+	if err != nil {
+		return 0, err
+	}
+
+	signed := 0
+	for i := range packet.Inputs {
+		if isFinalizedInput(&packet.Inputs[i]) {
+			continue
+		}
+
+		path, err := w.inputPath(packet, i)
+		if err != nil {
+			return signed, fmt.Errorf("input %d: %w", i, err)
+		}
+		if path == nil {
+			continue
+		}
+
+		ok, err := w.signInput(updater, sigHashes, i, path)
+		if err != nil {
+			return signed, fmt.Errorf("input %d: %w", i, err)
+		}
+		if ok {
+			signed++
+		}
+	}
+	return signed, nil
+}
+
+// FinalizePSBT finalizes every input of the fully signed PSBT and extracts the
+// network serializable transaction.
+func FinalizePSBT(packet *psbt.Packet) (*wire.MsgTx, error) {
+	if err := psbt.MaybeFinalizeAll(packet); err != nil {
+		return nil, err
+	}
+	return psbt.Extract(packet)
+}
+
+// ParsePSBT parses a PSBT in base64 or binary encoding.
+func ParsePSBT(data []byte) (*psbt.Packet, error) {
+	if bytes.HasPrefix(data, []byte("psbt\xff")) {
+		return psbt.NewFromRawBytes(bytes.NewReader(data), false)
+	}
+	return psbt.NewFromRawBytes(
+		strings.NewReader(strings.TrimSpace(string(data))), true)
+}
+
+// updateInput adds the UTXO and, for inputs of pinned accounts, the derivation
+// info and scripts needed to sign the input.
+func (w *Wallet) updateInput(
+	updater *psbt.Updater,
+	index int,
+	utxo UTXO,
+) error {
+	if utxo.PrevTx != nil {
+		if utxo.PrevTx.TxHash() != utxo.OutPoint.Hash {
+			return errors.New("previous transaction does not match outpoint")
+		}
+		if err := updater.AddInNonWitnessUtxo(utxo.PrevTx, index); err != nil {
+			return err
+		}
+	}
+
+	txOut := wire.NewTxOut(int64(utxo.Amount), utxo.PkScript)
+	if isWitnessOutput(utxo.PkScript) {
+		if err := updater.AddInWitnessUtxo(txOut, index); err != nil {
+			return err
+		}
+	} else if utxo.PrevTx == nil {
+		return errors.New("previous transaction is required for legacy inputs")
+	}
+
+	account, path, ok := w.accountByScript(utxo.PkScript)
+	if !ok {
+		return nil
+	}
+	return w.addInputDerivation(updater, index, account, path)
+}
+
+// updateOutput adds the derivation info to outputs paying to pinned accounts,
+// so signers can recognize them as change.
+func (w *Wallet) updateOutput(
+	updater *psbt.Updater,
+	index int,
+	pkScript []byte,
+) error {
+	account, path, ok := w.accountByScript(pkScript)
+	if !ok {
+		return nil
+	}
+
+	publicKey, fingerprint, err := w.derivationInfo(path)
+	if err != nil {
+		return err
+	}
+
+	pOutput := &updater.Upsbt.Outputs[index]
+	if account.Type == P2TR {
+		pOutput.TaprootInternalKey = schnorr.SerializePubKey(publicKey)
+		pOutput.TaprootBip32Derivation = append(
+			pOutput.TaprootBip32Derivation,
+			&psbt.TaprootBip32Derivation{
+				XOnlyPubKey:          pOutput.TaprootInternalKey,
+				MasterKeyFingerprint: fingerprint,
+				Bip32Path:            path,
+			})
+		return nil
+	}
+
+	if account.Type == P2SHP2WPKH {
+		redeemScript, err := witnessProgram(publicKey)
+		if err != nil {
+			return err
+		}
+		if err := updater.AddOutRedeemScript(redeemScript, index); err != nil {
+			return err
+		}
+	}
+	return updater.AddOutBip32Derivation(
+		fingerprint, path, publicKey.SerializeCompressed(), index)
+}
+
+// addInputDerivation adds the derivation info of the account to the input.
+func (w *Wallet) addInputDerivation(
+	updater *psbt.Updater,
+	index int,
+	account Account,
+	path DerivationPath,
+) error {
+	publicKey, fingerprint, err := w.derivationInfo(path)
+	if err != nil {
+		return err
+	}
+
+	pInput := &updater.Upsbt.Inputs[index]
+	if account.Type == P2TR {
+		pInput.TaprootInternalKey = schnorr.SerializePubKey(publicKey)
+		pInput.TaprootBip32Derivation = append(
+			pInput.TaprootBip32Derivation,
+			&psbt.TaprootBip32Derivation{
+				XOnlyPubKey:          pInput.TaprootInternalKey,
+				MasterKeyFingerprint: fingerprint,
+				Bip32Path:            path,
+			})
+		return nil
+	}
+
+	if account.Type == P2SHP2WPKH {
+		redeemScript, err := witnessProgram(publicKey)
+		if err != nil {
+			return err
+		}
+		if err := updater.AddInRedeemScript(redeemScript, index); err != nil {
+			return err
+		}
+	}
+	return updater.AddInBip32Derivation(
+		fingerprint, path, publicKey.SerializeCompressed(), index)
+}
+
+// inputPath returns the derivation path of the key of the wallet signing the
+// input, or nil if the wallet does not own the input.
+func (w *Wallet) inputPath(
+	packet *psbt.Packet,
+	index int,
+) (DerivationPath, error) {
+	fingerprint, err := w.MasterFingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	pInput := &packet.Inputs[index]
+	for _, derivation := range pInput.Bip32Derivation {
+		if derivation.MasterKeyFingerprint != fingerprint {
+			continue
+		}
+		ok, err := w.matchesKey(derivation.Bip32Path, func(
+			publicKey *btcec.PublicKey,
+		) bool {
+			return bytes.Equal(
+				publicKey.SerializeCompressed(), derivation.PubKey)
+		})
+		if err != nil || ok {
+			return derivation.Bip32Path, err
+		}
+	}
+	for _, derivation := range pInput.TaprootBip32Derivation {
+		if derivation.MasterKeyFingerprint != fingerprint ||
+			len(derivation.LeafHashes) > 0 {
+			continue
+		}
+		ok, err := w.matchesKey(derivation.Bip32Path, func(
+			publicKey *btcec.PublicKey,
+		) bool {
+			return bytes.Equal(
+				schnorr.SerializePubKey(publicKey), derivation.XOnlyPubKey)
+		})
+		if err != nil || ok {
+			return derivation.Bip32Path, err
+		}
+	}
+
+	txOut, err := spentOutput(packet, index)
+	if err != nil {
+		return nil, err
+	}
+	if _, path, ok := w.accountByScript(txOut.PkScript); ok {
+		return path, nil
+	}
+	return nil, nil
+}
+
+// signInput signs the input with the key at the path and returns whether it
+// did. Inputs spending a script other than a P2PKH, P2SH-P2WPKH, P2WPKH or
+// BIP-86 P2TR script of the key are not signed.
+func (w *Wallet) signInput(
+	updater *psbt.Updater,
+	sigHashes *txscript.TxSigHashes,
+	index int,
+	path DerivationPath,
+) (bool, error) {
+	packet := updater.Upsbt
+	pInput := &packet.Inputs[index]
+
+	txOut, err := spentOutput(packet, index)
+	if err != nil {
+		return false, err
+	}
+
+	w.stateLock.RLock()
+	privateKey, err := w.derivePrivateKey(path)
+	w.stateLock.RUnlock()
+	if err != nil {
+		return false, err
+	}
+	defer privateKey.Zero()
+	publicKey := privateKey.PubKey()
+
+	addrType, ok := w.keyScriptType(txOut.PkScript, publicKey)
+	if !ok {
+		return false, nil
+	}
+
+	switch addrType {
+	case P2TR:
+		hashType := pInput.SighashType
+		if hashType == 0 {
+			hashType = txscript.SigHashDefault
+		}
+		sig, err := txscript.RawTxInTaprootSignature(
+			packet.UnsignedTx, sigHashes, index, txOut.Value,
+			txOut.PkScript, []byte{}, hashType, privateKey)
+		if err != nil {
+			return false, err
+		}
+		pInput.TaprootKeySpendSig = sig
+		return true, nil
+
+	case P2WPKH:
+		sig, err := txscript.RawTxInWitnessSignature(
+			packet.UnsignedTx, sigHashes, index, txOut.Value,
+			txOut.PkScript, sigHashType(pInput), privateKey)
+		if err != nil {
+			return false, err
+		}
+		return true, addSignature(updater, index, sig, publicKey, nil)
+
+	case P2SHP2WPKH:
+		redeemScript, err := witnessProgram(publicKey)
+		if err != nil {
+			return false, err
+		}
+		sig, err := txscript.RawTxInWitnessSignature(
+			packet.UnsignedTx, sigHashes, index, txOut.Value,
+			redeemScript, sigHashType(pInput), privateKey)
+		if err != nil {
+			return false, err
+		}
+		return true, addSignature(updater, index, sig, publicKey, redeemScript)
+
+	default:
+		sig, err := txscript.RawTxInSignature(
+			packet.UnsignedTx, index, txOut.PkScript, sigHashType(pInput),
+			privateKey)
+		if err != nil {
+			return false, err
+		}
+		return true, addSignature(updater, index, sig, publicKey, nil)
+	}
+}
+
+// keyScriptType returns the type of the address of the public key the output
+// script pays to, or false if it does not pay to an address of the key.
+func (w *Wallet) keyScriptType(
+	pkScript []byte,
+	publicKey *btcec.PublicKey,
+) (AddressType, bool) {
+	for _, addrType := range []AddressType{P2PKH, P2SHP2WPKH, P2WPKH, P2TR} {
+		address, err := NewAddress(addrType, publicKey, w.params)
+		if err != nil {
+			continue
+		}
+		script, err := txscript.PayToAddrScript(address)
+		if err == nil && bytes.Equal(script, pkScript) {
+			return addrType, true
+		}
+	}
+	return 0, false
+}
+
+// addSignature adds the ECDSA signature of the public key to the input.
+func addSignature(
+	updater *psbt.Updater,
+	index int,
+	sig []byte,
+	publicKey *btcec.PublicKey,
+	redeemScript []byte,
+) error {
+	outcome, err := updater.Sign(
+		index, sig, publicKey.SerializeCompressed(), redeemScript, nil)
+	if err != nil {
+		return err
+	}
+	if outcome == psbt.SignInvalid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// matchesKey returns whether the public key at the path matches.
+func (w *Wallet) matchesKey(
+	path DerivationPath,
+	match func(*btcec.PublicKey) bool,
+) (bool, error) {
+	w.stateLock.RLock()
+	publicKey, err := w.derivePublicKey(path)
+	w.stateLock.RUnlock()
+	if err != nil {
+		return false, err
+	}
+	return match(publicKey), nil
+}
+
+// derivationInfo returns the public key at the path and the master
+// fingerprint of the wallet.
+func (w *Wallet) derivationInfo(
+	path DerivationPath,
+) (*btcec.PublicKey, uint32, error) {
+	fingerprint, err := w.MasterFingerprint()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	w.stateLock.RLock()
+	publicKey, err := w.derivePublicKey(path)
+	w.stateLock.RUnlock()
+	if err != nil {
+		return nil, 0, err
+	}
+	return publicKey, fingerprint, nil
+}
+
+// accountByScript returns the pinned account paying to the output script.
+func (w *Wallet) accountByScript(
+	pkScript []byte,
+) (Account, DerivationPath, bool) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	for _, account := range w.accounts {
+		script, err := txscript.PayToAddrScript(account.Address)
+		if err != nil || !bytes.Equal(script, pkScript) {
+			continue
+		}
+		return account, w.paths[account.Address.EncodeAddress()], true
+	}
+	return Account{}, nil, false
+}
+
+// prevOutFetcher returns the outputs spent by the inputs of the PSBT.
+func prevOutFetcher(packet *psbt.Packet) (txscript.PrevOutputFetcher, error) {
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, txIn := range packet.UnsignedTx.TxIn {
+		txOut, err := spentOutput(packet, i)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		fetcher.AddPrevOut(txIn.PreviousOutPoint, txOut)
+	}
+	return fetcher, nil
+}
+
+// spentOutput returns the output spent by the input of the PSBT.
+func spentOutput(packet *psbt.Packet, index int) (*wire.TxOut, error) {
+	pInput := &packet.Inputs[index]
+	if pInput.WitnessUtxo != nil {
+		return pInput.WitnessUtxo, nil
+	}
+
+	if pInput.NonWitnessUtxo != nil {
+		outIndex := packet.UnsignedTx.TxIn[index].PreviousOutPoint.Index
+		if int(outIndex) < len(pInput.NonWitnessUtxo.TxOut) {
+			return pInput.NonWitnessUtxo.TxOut[outIndex], nil
+		}
+	}
+	return nil, errors.New("spent output is missing")
+}
+
+// sigHashType returns the sighash type requested for an ECDSA signature of the
+// input, defaulting to SigHashAll.
+func sigHashType(pInput *psbt.PInput) txscript.SigHashType {
+	if pInput.SighashType == 0 {
+		return txscript.SigHashAll
+	}
+	return pInput.SighashType
+}
+
+// witnessProgram returns the P2WPKH witness program of the public key, which
+// is the redeem script of a P2SH-P2WPKH output.
+func witnessProgram(publicKey *btcec.PublicKey) ([]byte, error) {
+	return txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).
+		AddData(btcutil.Hash160(publicKey.SerializeCompressed())).
+		Script()
+}
+
+// isWitnessOutput returns whether spending the output script requires a
+// witness, including P2SH outputs, which may nest one. Their amount is
+// committed to by segwit signatures, so they carry a witness UTXO.
+func isWitnessOutput(pkScript []byte) bool {
+	return txscript.IsWitnessProgram(pkScript) ||
+		txscript.IsPayToScriptHash(pkScript)
+}
+
+// isFinalizedInput returns whether the input already has its final scripts.
+func isFinalizedInput(pInput *psbt.PInput) bool {
+	return pInput.FinalScriptSig != nil || pInput.FinalScriptWitness != nil
+}
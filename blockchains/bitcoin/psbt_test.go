@@ -0,0 +1,251 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon " +
+	"abandon abandon abandon abandon abandon about"
+
+func newTestWallet(t *testing.T) *Wallet {
+	w, err := NewFromMnemonic(testMnemonic, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// testUTXO returns a UTXO of the amount paying to the script.
+func testUTXO(index uint32, amount btcutil.Amount, pkScript []byte) UTXO {
+	return UTXO{
+		OutPoint: wire.OutPoint{Hash: chainhash.Hash{1}, Index: index},
+		Amount:   amount,
+		PkScript: pkScript,
+	}
+}
+
+func TestSignPSBTSkipsMultisigInputs(t *testing.T) {
+	w := newTestWallet(t)
+	params := w.Params()
+
+	account, err := w.Derive(MustParseDerivationPath("m/84'/1'/0'/0/0"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountScript, err := txscript.PayToAddrScript(account.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a 1-of-2 multisig of a key of the wallet and a key of a cosigner
+	multisigPath := MustParseDerivationPath("m/48'/1'/0'/2'/0/0")
+	w.stateLock.RLock()
+	multisigKey, err := w.derivePublicKey(multisigPath)
+	w.stateLock.RUnlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosigner, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	multisig, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_1).
+		AddData(multisigKey.SerializeCompressed()).
+		AddData(cosigner.PubKey().SerializeCompressed()).
+		AddOp(txscript.OP_2).
+		AddOp(txscript.OP_CHECKMULTISIG).
+		Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scriptHash := sha256.Sum256(multisig)
+	p2wsh, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2wshScript, err := txscript.PayToAddrScript(p2wsh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2sh, err := btcutil.NewAddressScriptHash(multisig, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2shScript, err := txscript.PayToAddrScript(p2sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet, err := w.CreatePSBT(
+		[]UTXO{
+			testUTXO(0, 100000, accountScript),
+			testUTXO(1, 100000, p2wshScript),
+			testUTXO(2, 100000, p2shScript),
+		},
+		[]Output{{Address: account.Address, Amount: 290000}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the coordinator lists the multisig key of the wallet on its inputs
+	fingerprint, err := w.MasterFingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	updater, err := psbt.NewUpdater(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, index := range []int{1, 2} {
+		err := updater.AddInBip32Derivation(fingerprint, multisigPath,
+			multisigKey.SerializeCompressed(), index)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	signed, err := w.SignPSBT(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed != 1 {
+		t.Fatalf("signed %d inputs, want 1", signed)
+	}
+	if len(packet.Inputs[0].PartialSigs) != 1 {
+		t.Fatal("single-key input was not signed")
+	}
+	for _, index := range []int{1, 2} {
+		if len(packet.Inputs[index].PartialSigs) != 0 {
+			t.Fatalf("multisig input %d was signed", index)
+		}
+	}
+}
+
+// prevTx returns a transaction paying the amount to the script.
+func prevTx(amount btcutil.Amount, pkScript []byte) *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{2}}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(int64(amount), pkScript))
+	return tx
+}
+
+func TestSignPSBT(t *testing.T) {
+	for _, addrType := range []AddressType{P2PKH, P2SHP2WPKH, P2WPKH, P2TR} {
+		t.Run(addrType.String(), func(t *testing.T) {
+			w := newTestWallet(t)
+			account, err := w.Derive(
+				DefaultPath(addrType, w.Params(), 0, false, 0), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			change, err := w.Derive(
+				DefaultPath(addrType, w.Params(), 0, true, 0), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkScript, err := txscript.PayToAddrScript(account.Address)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			utxo := testUTXO(0, 100000, pkScript)
+			if addrType == P2PKH {
+				utxo.PrevTx = prevTx(utxo.Amount, pkScript)
+				utxo.OutPoint.Hash = utxo.PrevTx.TxHash()
+			}
+			recipient, err := btcutil.DecodeAddress(
+				"bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080", w.Params())
+			if err != nil {
+				t.Fatal(err)
+			}
+			packet, err := w.CreatePSBT([]UTXO{utxo}, []Output{
+				{Address: recipient, Amount: 60000},
+				{Address: change.Address, Amount: 39000},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signed, err := w.SignPSBT(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signed != 1 {
+				t.Fatalf("signed %d inputs, want 1", signed)
+			}
+			tx, err := FinalizePSBT(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fetcher := txscript.NewCannedPrevOutputFetcher(
+				pkScript, int64(utxo.Amount))
+			engine, err := txscript.NewEngine(pkScript, tx, 0,
+				txscript.StandardVerifyFlags, nil,
+				txscript.NewTxSigHashes(tx, fetcher), int64(utxo.Amount),
+				fetcher)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := engine.Execute(); err != nil {
+				t.Fatalf("invalid input script: %v", err)
+			}
+		})
+	}
+}
+
+func TestParsePSBT(t *testing.T) {
+	w := newTestWallet(t)
+	account, err := w.Derive(MustParseDerivationPath("m/84'/1'/0'/0/0"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.PayToAddrScript(account.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet, err := w.CreatePSBT([]UTXO{testUTXO(0, 100000, pkScript)},
+		[]Output{{Address: account.Address, Amount: 90000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw bytes.Buffer
+	if err := packet.Serialize(&raw); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := packet.B64Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{
+		"base64": []byte(" " + encoded + "\n"),
+		"binary": raw.Bytes(),
+	} {
+		parsed, err := ParsePSBT(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var reencoded bytes.Buffer
+		if err := parsed.Serialize(&reencoded); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(reencoded.Bytes(), raw.Bytes()) {
+			t.Fatalf("%s PSBT does not round-trip", name)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package bitcoin
+
+import (
+	"context"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// UTXO is an unspent transaction output.
+type UTXO struct {
+	OutPoint wire.OutPoint
+	Amount   btcutil.Amount
+	PkScript []byte
+	// PrevTx is the transaction that created the output. It is required to
+	// spend legacy P2PKH outputs, whose signatures do not commit to the
+	// amount.
+	PrevTx *wire.MsgTx
+}
+
+// UTXOSource provides the unspent outputs coins are selected from, e.g. an
+// Electrum server, a block explorer or a full node.
+type UTXOSource interface {
+	// UTXOs returns the unspent outputs of the wallet.
+	UTXOs(ctx context.Context) ([]UTXO, error)
+}
+
+// MemorySource is a UTXOSource holding the unspent outputs in memory.
+type MemorySource struct {
+	lock  sync.RWMutex
+	utxos []UTXO
+}
+
+// NewMemorySource creates a source holding the unspent outputs.
+func NewMemorySource(utxos ...UTXO) *MemorySource {
+	s := &MemorySource{}
+	s.Add(utxos...)
+	return s
+}
+
+// Add adds the unspent outputs, replacing those with the same outpoint.
+func (s *MemorySource) Add(utxos ...UTXO) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, utxo := range utxos {
+		if i := s.indexOf(utxo.OutPoint); i >= 0 {
+			s.utxos[i] = utxo
+		} else {
+			s.utxos = append(s.utxos, utxo)
+		}
+	}
+}
+
+// Remove removes the outputs, e.g. once a transaction spending them is
+// broadcast.
+func (s *MemorySource) Remove(outpoints ...wire.OutPoint) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, outpoint := range outpoints {
+		if i := s.indexOf(outpoint); i >= 0 {
+			s.utxos = append(s.utxos[:i], s.utxos[i+1:]...)
+		}
+	}
+}
+
+// UTXOs implements UTXOSource.
+func (s *MemorySource) UTXOs(ctx context.Context) ([]UTXO, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	cpy := make([]UTXO, len(s.utxos))
+	copy(cpy, s.utxos)
+	return cpy, nil
+}
+
+// indexOf returns the index of the output, or -1. The caller must hold the
+// lock.
+func (s *MemorySource) indexOf(outpoint wire.OutPoint) int {
+	for i, utxo := range s.utxos {
+		if utxo.OutPoint == outpoint {
+			return i
+		}
+	}
+	return -1
+}
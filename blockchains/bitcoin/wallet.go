@@ -0,0 +1,353 @@
+package bitcoin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/solsticewallet/solstice-core/bip39"
+)
+
+var (
+	// ErrUnknownAccount is returned for accounts that are not pinned to the
+	// wallet.
+	ErrUnknownAccount = errors.New("unknown account")
+	// ErrWalletLocked is returned when deriving or signing with a wallet
+	// whose secrets were wiped by Lock.
+	ErrWalletLocked = errors.New("wallet is locked")
+)
+
+// Account is an address pinned to the wallet.
+type Account struct {
+	Address btcutil.Address
+	Type    AddressType
+}
+
+// Wallet is a Bitcoin HD wallet deriving P2PKH, P2SH-P2WPKH, P2WPKH and P2TR
+// addresses from a BIP-39 seed for a single network.
+type Wallet struct {
+	mnemonic  string
+	masterKey *hdkeychain.ExtendedKey
+	seed      []byte
+	params    *chaincfg.Params
+	paths     map[string]DerivationPath
+	accounts  []Account
+	stateLock sync.RWMutex
+}
+
+// newWallet creates a new Wallet for the network using the provided seed.
+func newWallet(seed []byte, params *chaincfg.Params) (*Wallet, error) {
+	if params == nil {
+		return nil, errors.New("network params are required")
+	}
+
+	masterKey, err := hdkeychain.NewMaster(seed, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Wallet{
+		masterKey: masterKey,
+		seed:      seed,
+		params:    params,
+		paths:     map[string]DerivationPath{},
+		accounts:  []Account{},
+	}, nil
+}
+
+// NewFromMnemonic returns a new wallet for the network from a BIP-39 mnemonic.
+// The network is one of chaincfg.MainNetParams, TestNet3Params, SigNetParams
+// or RegressionNetParams.
+func NewFromMnemonic(
+	mnemonic string,
+	params *chaincfg.Params,
+	passOpt ...string,
+) (*Wallet, error) {
+	if mnemonic == "" {
+		return nil, errors.New("mnemonic is required")
+	}
+
+	var password string
+	if len(passOpt) > 0 {
+		password = passOpt[0]
+	}
+
+	canonical, err := bip39.CanonicalMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	seed := bip39.NewSeed(canonical, password)
+
+	wallet, err := newWallet(seed, params)
+	if err != nil {
+		return nil, err
+	}
+	wallet.mnemonic = canonical
+
+	return wallet, nil
+}
+
+// NewFromSeed returns a new wallet for the network from a BIP-39 seed.
+func NewFromSeed(seed []byte, params *chaincfg.Params) (*Wallet, error) {
+	if len(seed) == 0 {
+		return nil, errors.New("seed is required")
+	}
+	return newWallet(seed, params)
+}
+
+// Params returns the parameters of the network of the wallet.
+func (w *Wallet) Params() *chaincfg.Params {
+	return w.params
+}
+
+// MasterFingerprint returns the BIP-32 fingerprint of the master key, with its
+// bytes read in little-endian order as btcutil/psbt stores it.
+func (w *Wallet) MasterFingerprint() (uint32, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	if w.masterKey == nil {
+		return 0, ErrWalletLocked
+	}
+	publicKey, err := w.masterKey.ECPubKey()
+	if err != nil {
+		return 0, err
+	}
+	hash := btcutil.Hash160(publicKey.SerializeCompressed())
+	return binary.LittleEndian.Uint32(hash[:4]), nil
+}
+
+// Lock wipes the mnemonic, the seed and the master key from memory. The
+// pinned accounts can still be listed, but no longer derived or signed for.
+func (w *Wallet) Lock() {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	zeroBytes(w.seed)
+	if w.masterKey != nil {
+		w.masterKey.Zero()
+	}
+	w.mnemonic = ""
+	w.seed = nil
+	w.masterKey = nil
+}
+
+// Accounts returns the accounts pinned to the wallet.
+func (w *Wallet) Accounts() []Account {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	cpy := make([]Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains returns whether the account is pinned to the wallet.
+func (w *Wallet) Contains(account Account) bool {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	_, ok := w.paths[account.Address.EncodeAddress()]
+	return ok
+}
+
+// Derive derives the account at the path and, if pin is set, pins it to the
+// wallet. The address type is inferred from the BIP-43 purpose of the path,
+// e.g. m/84'/0'/0'/0/0 is a P2WPKH address, unless it is given.
+func (w *Wallet) Derive(
+	path DerivationPath,
+	pin bool,
+	typeOpt ...AddressType,
+) (Account, error) {
+	var (
+		addrType AddressType
+		err      error
+	)
+	if len(typeOpt) > 0 {
+		addrType = typeOpt[0]
+	} else if addrType, err = path.addressType(); err != nil {
+		return Account{}, err
+	}
+
+	w.stateLock.RLock()
+	publicKey, err := w.derivePublicKey(path)
+	w.stateLock.RUnlock()
+	if err != nil {
+		return Account{}, err
+	}
+
+	address, err := NewAddress(addrType, publicKey, w.params)
+	if err != nil {
+		return Account{}, err
+	}
+	account := Account{Address: address, Type: addrType}
+
+	if !pin {
+		return account, nil
+	}
+
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	id := address.EncodeAddress()
+	if _, ok := w.paths[id]; !ok {
+		w.accounts = append(w.accounts, account)
+		w.paths[id] = append(DerivationPath{}, path...)
+	}
+	return account, nil
+}
+
+// Unpin removes the account from the pinned accounts of the wallet.
+func (w *Wallet) Unpin(account Account) error {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	id := account.Address.EncodeAddress()
+	if _, ok := w.paths[id]; !ok {
+		return ErrUnknownAccount
+	}
+	delete(w.paths, id)
+
+	for i, acct := range w.accounts {
+		if acct.Address.EncodeAddress() == id {
+			w.accounts = append(w.accounts[:i], w.accounts[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Path returns the derivation path of the account.
+func (w *Wallet) Path(account Account) (string, error) {
+	path, err := w.DerivationPath(account)
+	if err != nil {
+		return "", err
+	}
+	return path.String(), nil
+}
+
+// DerivationPath returns the parsed derivation path of the account.
+func (w *Wallet) DerivationPath(account Account) (DerivationPath, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	path, ok := w.paths[account.Address.EncodeAddress()]
+	if !ok {
+		return nil, ErrUnknownAccount
+	}
+	return append(DerivationPath{}, path...), nil
+}
+
+// PrivateKey returns the private key of the account.
+func (w *Wallet) PrivateKey(account Account) (*btcec.PrivateKey, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	path, ok := w.paths[account.Address.EncodeAddress()]
+	if !ok {
+		return nil, ErrUnknownAccount
+	}
+	return w.derivePrivateKey(path)
+}
+
+// PrivateKeyWIF returns the private key of the account in wallet import
+// format.
+func (w *Wallet) PrivateKeyWIF(account Account) (string, error) {
+	privateKey, err := w.PrivateKey(account)
+	if err != nil {
+		return "", err
+	}
+
+	wif, err := btcutil.NewWIF(privateKey, w.params, true)
+	if err != nil {
+		return "", err
+	}
+	return wif.String(), nil
+}
+
+// PublicKey returns the public key of the account.
+func (w *Wallet) PublicKey(account Account) (*btcec.PublicKey, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	path, ok := w.paths[account.Address.EncodeAddress()]
+	if !ok {
+		return nil, ErrUnknownAccount
+	}
+	return w.derivePublicKey(path)
+}
+
+// PublicKeyHex returns the compressed public key of the account in hex.
+func (w *Wallet) PublicKeyHex(account Account) (string, error) {
+	publicKey, err := w.PublicKey(account)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(publicKey.SerializeCompressed()), nil
+}
+
+// derivePrivateKey derives the private key of the derivation path. The caller
+// must hold the state lock.
+func (w *Wallet) derivePrivateKey(
+	path DerivationPath,
+) (*btcec.PrivateKey, error) {
+	key, err := w.deriveExtendedKey(path)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zero()
+
+	return key.ECPrivKey()
+}
+
+// derivePublicKey derives the public key of the derivation path. The caller
+// must hold the state lock.
+func (w *Wallet) derivePublicKey(
+	path DerivationPath,
+) (*btcec.PublicKey, error) {
+	key, err := w.deriveExtendedKey(path)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zero()
+
+	return key.ECPubKey()
+}
+
+// deriveExtendedKey derives the extended private key of the derivation path.
+// Intermediate keys are zeroed and the caller must zero the returned key.
+func (w *Wallet) deriveExtendedKey(
+	path DerivationPath,
+) (*hdkeychain.ExtendedKey, error) {
+	if w.masterKey == nil {
+		return nil, ErrWalletLocked
+	}
+
+	key := w.masterKey
+	for _, n := range path {
+		child, err := key.Derive(n)
+		if key != w.masterKey {
+			key.Zero()
+		}
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	if key == w.masterKey {
+		return nil, errors.New("empty derivation path")
+	}
+	return key, nil
+}
+
+// zeroBytes overwrites the byte slice with zeros.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,122 @@
+package bitcoin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestDeriveVectors(t *testing.T) {
+	// the first receive address of each scheme for the mnemonic, from the
+	// BIP-44, BIP-49, BIP-84 and BIP-86 test vectors
+	tests := []struct {
+		path    string
+		params  *chaincfg.Params
+		address string
+	}{
+		{"m/44'/0'/0'/0/0", &chaincfg.MainNetParams,
+			"1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+		{"m/49'/0'/0'/0/0", &chaincfg.MainNetParams,
+			"37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf"},
+		{"m/49'/1'/0'/0/0", &chaincfg.TestNet3Params,
+			"2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2"},
+		{"m/84'/0'/0'/0/0", &chaincfg.MainNetParams,
+			"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{"m/84'/0'/0'/0/1", &chaincfg.MainNetParams,
+			"bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"},
+		{"m/84'/0'/0'/1/0", &chaincfg.MainNetParams,
+			"bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"},
+		{"m/86'/0'/0'/0/0", &chaincfg.MainNetParams,
+			"bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			w, err := NewFromMnemonic(testMnemonic, test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			account, err := w.Derive(MustParseDerivationPath(test.path), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := account.Address.EncodeAddress(); got != test.address {
+				t.Fatalf("address %s, want %s", got, test.address)
+			}
+			if path, err := w.Path(account); err != nil || path != test.path {
+				t.Fatalf("path %s, %v, want %s", path, err, test.path)
+			}
+		})
+	}
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"m/84'/0'/0'/0/0", "m/84'/0'/0'/0/0"},
+		{"m/86h/1h/2h/1/5", "m/86'/1'/2'/1/5"},
+		{" m/0 ", "m/0"},
+		{"m/2147483647'", "m/2147483647'"},
+	}
+	for _, test := range tests {
+		path, err := ParseDerivationPath(test.path)
+		if err != nil {
+			t.Fatalf("ParseDerivationPath(%q): %v", test.path, err)
+		}
+		if got := path.String(); got != test.want {
+			t.Fatalf("ParseDerivationPath(%q) is %s, want %s",
+				test.path, got, test.want)
+		}
+		reparsed, err := ParseDerivationPath(path.String())
+		if err != nil || len(reparsed) != len(path) {
+			t.Fatalf("%s does not round-trip", path)
+		}
+		for i := range path {
+			if reparsed[i] != path[i] {
+				t.Fatalf("%s does not round-trip", path)
+			}
+		}
+	}
+
+	for _, invalid := range []string{
+		"", "m", "84'/0'", "m/", "m/x", "m/-1", "m/2147483648", "m/0''",
+	} {
+		if _, err := ParseDerivationPath(invalid); err == nil {
+			t.Fatalf("ParseDerivationPath(%q) succeeded", invalid)
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	w := newTestWallet(t)
+	account, err := w.Derive(MustParseDerivationPath("m/84'/1'/0'/0/0"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := w.seed
+
+	w.Lock()
+	if !bytes.Equal(seed, make([]byte, len(seed))) {
+		t.Fatal("seed was not zeroed")
+	}
+	if _, err := w.PrivateKey(account); !errors.Is(err, ErrWalletLocked) {
+		t.Fatalf("PrivateKey returned %v, want %v", err, ErrWalletLocked)
+	}
+	if _, err := w.Derive(
+		MustParseDerivationPath("m/84'/1'/0'/0/1"), false); !errors.Is(
+		err, ErrWalletLocked) {
+		t.Fatalf("Derive returned %v, want %v", err, ErrWalletLocked)
+	}
+	if _, err := w.MasterFingerprint(); !errors.Is(err, ErrWalletLocked) {
+		t.Fatalf("MasterFingerprint returned %v, want %v", err, ErrWalletLocked)
+	}
+
+	// pinned accounts are still listed
+	if accounts := w.Accounts(); len(accounts) != 1 ||
+		accounts[0].Address.EncodeAddress() != account.Address.EncodeAddress() {
+		t.Fatalf("accounts %v, want %v", accounts, account)
+	}
+}
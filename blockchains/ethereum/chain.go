@@ -0,0 +1,390 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownChain is returned when a chain is not in the registry.
+var ErrUnknownChain = errors.New("unknown chain")
+
+// Chain describes an Ethereum network.
+type Chain struct {
+	// ID is the EIP-155 chain ID.
+	ID uint64 `json:"chainId" yaml:"chainId"`
+	// Key is the short name the chain is looked up by, like "sepolia".
+	Key  string `json:"key" yaml:"key"`
+	Name string `json:"name" yaml:"name"`
+
+	Currency Currency `json:"currency" yaml:"currency"`
+	RPC      []string `json:"rpc" yaml:"rpc"`
+	Explorer Explorer `json:"explorer" yaml:"explorer"`
+
+	// EIP1559 is set when the blocks of the chain carry a base fee.
+	EIP1559 bool `json:"eip1559" yaml:"eip1559"`
+	// Confirmations is the number of blocks after which a transaction is
+	// considered final.
+	Confirmations uint64 `json:"confirmations" yaml:"confirmations"`
+	Testnet       bool   `json:"testnet" yaml:"testnet"`
+}
+
+// Currency is the native currency of a chain.
+type Currency struct {
+	Symbol   string `json:"symbol" yaml:"symbol"`
+	Decimals uint8  `json:"decimals" yaml:"decimals"`
+}
+
+// Explorer holds the URL templates of a block explorer. The {hash}, {address}
+// and {number} placeholders are replaced with the transaction hash, the
+// address and the block number.
+type Explorer struct {
+	Tx      string `json:"tx" yaml:"tx"`
+	Address string `json:"address" yaml:"address"`
+	Block   string `json:"block" yaml:"block"`
+}
+
+// ChainClient is a connection to the RPC endpoints of a chain. It implements
+// Backend, so it can be passed to every wallet operation, and returns the
+// chain ID of the registry without querying the endpoints.
+type ChainClient struct {
+	*provider.Provider
+
+	Chain *Chain
+}
+
+// BigID returns the chain ID as a big integer.
+func (c *Chain) BigID() *big.Int {
+	return new(big.Int).SetUint64(c.ID)
+}
+
+// TxType returns the transaction type the wallet should build on the chain.
+func (c *Chain) TxType() uint8 {
+	if c.EIP1559 {
+		return types.DynamicFeeTxType
+	}
+	return types.LegacyTxType
+}
+
+// TxURL returns the explorer URL of the transaction, or an empty string when
+// the chain has no explorer.
+func (c *Chain) TxURL(hash common.Hash) string {
+	return expandTemplate(c.Explorer.Tx, "{hash}", hash.Hex())
+}
+
+// AddressURL returns the explorer URL of the address, or an empty string when
+// the chain has no explorer.
+func (c *Chain) AddressURL(address common.Address) string {
+	return expandTemplate(c.Explorer.Address, "{address}", address.Hex())
+}
+
+// BlockURL returns the explorer URL of the block, or an empty string when the
+// chain has no explorer.
+func (c *Chain) BlockURL(number uint64) string {
+	return expandTemplate(
+		c.Explorer.Block, "{number}", strconv.FormatUint(number, 10))
+}
+
+// Dial connects to the RPC endpoints of the chain and checks that they serve
+// the chain.
+func (c *Chain) Dial(ctx context.Context) (*ChainClient, error) {
+	if len(c.RPC) == 0 {
+		return nil, fmt.Errorf("chain %s has no rpc endpoints", c.Key)
+	}
+
+	p, err := provider.NewProvider(ctx, c.RPC...)
+	if err != nil {
+		return nil, err
+	}
+
+	chainID, err := p.ChainID(ctx)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	if chainID.Cmp(c.BigID()) != 0 {
+		p.Close()
+		return nil, fmt.Errorf(
+			"rpc endpoints of %s serve chain %v, expected %d",
+			c.Key, chainID, c.ID)
+	}
+
+	return &ChainClient{Provider: p, Chain: c}, nil
+}
+
+// ChainID implements ethereum.ChainIDReader.
+func (c *ChainClient) ChainID(ctx context.Context) (*big.Int, error) {
+	return c.Chain.BigID(), nil
+}
+
+// validate checks that the chain can be added to a registry.
+func (c *Chain) validate() error {
+	switch {
+	case c.ID == 0:
+		return errors.New("chain id is missing")
+	case c.Key == "":
+		return fmt.Errorf("chain %d has no key", c.ID)
+	case c.Name == "":
+		return fmt.Errorf("chain %s has no name", c.Key)
+	case c.Currency.Symbol == "":
+		return fmt.Errorf("chain %s has no currency symbol", c.Key)
+	}
+	return nil
+}
+
+// Registry holds the known chains by chain ID and key.
+type Registry struct {
+	lock  sync.RWMutex
+	byID  map[uint64]*Chain
+	byKey map[string]*Chain
+}
+
+// NewRegistry creates a registry holding the built-in chains.
+func NewRegistry() *Registry {
+	r := &Registry{
+		byID:  map[uint64]*Chain{},
+		byKey: map[string]*Chain{},
+	}
+	for _, chain := range BuiltinChains() {
+		r.add(chain)
+	}
+	return r
+}
+
+// Add adds the chain to the registry, replacing a chain with the same chain ID
+// or key.
+func (r *Registry) Add(chain *Chain) error {
+	if err := chain.validate(); err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.add(chain)
+	return nil
+}
+
+// Load adds the chains of the JSON or YAML file to the registry. The format is
+// chosen by the extension of the file, .yaml and .yml files are YAML.
+func (r *Registry) Load(file string) error {
+	chains, err := LoadChains(file)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, chain := range chains {
+		r.add(chain)
+	}
+	return nil
+}
+
+// Chain returns the chain with the chain ID.
+func (r *Registry) Chain(id uint64) (*Chain, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	chain, ok := r.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownChain, id)
+	}
+	return chain, nil
+}
+
+// ChainByKey returns the chain with the key, like "mainnet" or "sepolia".
+func (r *Registry) ChainByKey(key string) (*Chain, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	chain, ok := r.byKey[strings.ToLower(key)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownChain, key)
+	}
+	return chain, nil
+}
+
+// Chains returns all chains of the registry ordered by chain ID.
+func (r *Registry) Chains() []*Chain {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	chains := make([]*Chain, 0, len(r.byID))
+	for _, chain := range r.byID {
+		chains = append(chains, chain)
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].ID < chains[j].ID
+	})
+	return chains
+}
+
+// add adds the chain, removing the chains it replaces. The caller must hold
+// the lock.
+func (r *Registry) add(chain *Chain) {
+	key := strings.ToLower(chain.Key)
+	if old, ok := r.byID[chain.ID]; ok {
+		delete(r.byKey, strings.ToLower(old.Key))
+	}
+	if old, ok := r.byKey[key]; ok {
+		delete(r.byID, old.ID)
+	}
+
+	r.byID[chain.ID] = chain
+	r.byKey[key] = chain
+}
+
+// LoadChains reads a list of chains from the JSON or YAML file. The format is
+// chosen by the extension of the file, .yaml and .yml files are YAML.
+func LoadChains(file string) ([]*Chain, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var chains []*Chain
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &chains)
+	default:
+		err = json.Unmarshal(data, &chains)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+
+	for _, chain := range chains {
+		if err := chain.validate(); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", file, err)
+		}
+	}
+	return chains, nil
+}
+
+// BuiltinChains returns new copies of the chains every registry starts with.
+func BuiltinChains() []*Chain {
+	eth := Currency{Symbol: "ETH", Decimals: 18}
+
+	return []*Chain{
+		{
+			ID:       1,
+			Key:      "mainnet",
+			Name:     "Ethereum Mainnet",
+			Currency: eth,
+			RPC: []string{
+				"https://ethereum-rpc.publicnode.com",
+				"https://cloudflare-eth.com",
+			},
+			Explorer:      etherscanExplorer("https://etherscan.io"),
+			EIP1559:       true,
+			Confirmations: 12,
+		},
+		{
+			ID:       11155111,
+			Key:      "sepolia",
+			Name:     "Sepolia",
+			Currency: eth,
+			RPC: []string{
+				"https://ethereum-sepolia-rpc.publicnode.com",
+				"https://rpc.sepolia.org",
+			},
+			Explorer:      etherscanExplorer("https://sepolia.etherscan.io"),
+			EIP1559:       true,
+			Confirmations: 3,
+			Testnet:       true,
+		},
+		{
+			ID:       17000,
+			Key:      "holesky",
+			Name:     "Holesky",
+			Currency: eth,
+			RPC: []string{
+				"https://ethereum-holesky-rpc.publicnode.com",
+			},
+			Explorer:      etherscanExplorer("https://holesky.etherscan.io"),
+			EIP1559:       true,
+			Confirmations: 3,
+			Testnet:       true,
+		},
+		{
+			ID:       10,
+			Key:      "optimism",
+			Name:     "OP Mainnet",
+			Currency: eth,
+			RPC: []string{
+				"https://mainnet.optimism.io",
+			},
+			Explorer:      etherscanExplorer("https://optimistic.etherscan.io"),
+			EIP1559:       true,
+			Confirmations: 1,
+		},
+		{
+			ID:       42161,
+			Key:      "arbitrum",
+			Name:     "Arbitrum One",
+			Currency: eth,
+			RPC: []string{
+				"https://arb1.arbitrum.io/rpc",
+			},
+			Explorer:      etherscanExplorer("https://arbiscan.io"),
+			EIP1559:       true,
+			Confirmations: 1,
+		},
+		{
+			ID:       8453,
+			Key:      "base",
+			Name:     "Base",
+			Currency: eth,
+			RPC: []string{
+				"https://mainnet.base.org",
+			},
+			Explorer:      etherscanExplorer("https://basescan.org"),
+			EIP1559:       true,
+			Confirmations: 1,
+		},
+		{
+			ID:       137,
+			Key:      "polygon",
+			Name:     "Polygon PoS",
+			Currency: Currency{Symbol: "POL", Decimals: 18},
+			RPC: []string{
+				"https://polygon-rpc.com",
+			},
+			Explorer:      etherscanExplorer("https://polygonscan.com"),
+			EIP1559:       true,
+			Confirmations: 64,
+		},
+	}
+}
+
+// etherscanExplorer returns the URL templates of an Etherscan-like explorer.
+func etherscanExplorer(baseURL string) Explorer {
+	return Explorer{
+		Tx:      baseURL + "/tx/{hash}",
+		Address: baseURL + "/address/{address}",
+		Block:   baseURL + "/block/{number}",
+	}
+}
+
+// expandTemplate replaces the placeholder in the template with the value.
+func expandTemplate(template string, placeholder string, value string) string {
+	if template == "" {
+		return ""
+	}
+	return strings.ReplaceAll(template, placeholder, value)
+}
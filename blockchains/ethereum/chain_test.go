@@ -0,0 +1,95 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/hdwallet"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/internal/rpctest"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/provider"
+)
+
+const (
+	testMnemonic = "abandon abandon abandon abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon about"
+	gwei = 1e9
+)
+
+// newTestNode starts a JSON-RPC stand-in of a London chain, whose head
+// carries a base fee, answering the requests CreateTransaction makes.
+func newTestNode(t *testing.T) string {
+	head, err := json.Marshal(&types.Header{
+		Number:     big.NewInt(1),
+		Difficulty: new(big.Int),
+		GasLimit:   30000000,
+		BaseFee:    big.NewInt(gwei),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := map[string]interface{}{
+		"eth_chainId":              "0x1",
+		"eth_getBlockByNumber":     json.RawMessage(head),
+		"eth_gasPrice":             hexutil.EncodeBig(big.NewInt(2 * gwei)),
+		"eth_maxPriorityFeePerGas": hexutil.EncodeBig(big.NewInt(gwei)),
+		"eth_getBalance":           hexutil.EncodeBig(big.NewInt(1e18)),
+		"eth_getTransactionCount":  "0x0",
+	}
+	return rpctest.NewNode(t, rpctest.Results(results)).URL
+}
+
+func TestCreateTransactionChainTxType(t *testing.T) {
+	url := newTestNode(t)
+	p, err := provider.NewProvider(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	wallet, err := NewSoftwareWalletFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := wallet.Derive(
+		hdwallet.DefaultCoinPath(hdwallet.CoinTypeETH, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+	tests := []struct {
+		name   string
+		client Backend
+		want   uint8
+	}{
+		{
+			"legacy registry chain",
+			&ChainClient{Provider: p, Chain: &Chain{ID: 1, EIP1559: false}},
+			types.LegacyTxType,
+		},
+		{
+			"eip-1559 registry chain",
+			&ChainClient{Provider: p, Chain: &Chain{ID: 1, EIP1559: true}},
+			types.DynamicFeeTxType,
+		},
+		{"raw client on a london chain", p, types.DynamicFeeTxType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tx, err := wallet.CreateTransaction(
+				context.Background(), test.client, account, to, big.NewInt(1), 21000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tx.Type() != test.want {
+				t.Fatalf("transaction type %d, want %d", tx.Type(), test.want)
+			}
+		})
+	}
+}
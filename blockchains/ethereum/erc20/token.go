@@ -0,0 +1,383 @@
+package erc20
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/utils"
+)
+
+// erc20ABI is the ABI of the methods of the ERC-20 standard, including the
+// optional metadata methods.
+const erc20ABI = `[
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[{"name":"spender","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"transferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}
+]`
+
+// bytes32ABI is used to decode the name and symbol of tokens that predate the
+// standard and return them as bytes32, like MKR.
+const bytes32ABI = `[
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bytes32"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bytes32"}]}
+]`
+
+var (
+	parsedABI        = mustParseABI(erc20ABI)
+	parsedBytes32ABI = mustParseABI(bytes32ABI)
+)
+
+// ErrNoContract is returned when there is no contract code at the address of
+// the token.
+var ErrNoContract = errors.New("no contract code at token address")
+
+// Metadata holds the optional metadata of an ERC-20 token.
+type Metadata struct {
+	Name     string
+	Symbol   string
+	Decimals uint8
+}
+
+// Token is an ERC-20 token contract. It reads the state of the token and
+// builds transactions calling it, which are signed through the wallet like
+// any other transaction.
+type Token struct {
+	address common.Address
+
+	decimalsLock sync.Mutex
+	decimals     *uint8
+}
+
+// NewToken returns the ERC-20 token at the address.
+func NewToken(address common.Address) *Token {
+	return &Token{address: address}
+}
+
+// Address returns the address of the token contract.
+func (t *Token) Address() common.Address {
+	return t.address
+}
+
+// Name returns the name of the token.
+func (t *Token) Name(
+	ctx context.Context,
+	client bind.ContractCaller,
+) (string, error) {
+	return t.callString(ctx, client, "name")
+}
+
+// Symbol returns the symbol of the token.
+func (t *Token) Symbol(
+	ctx context.Context,
+	client bind.ContractCaller,
+) (string, error) {
+	return t.callString(ctx, client, "symbol")
+}
+
+// Decimals returns the number of decimals of the token. The value is cached
+// after the first successful call.
+func (t *Token) Decimals(
+	ctx context.Context,
+	client bind.ContractCaller,
+) (uint8, error) {
+	t.decimalsLock.Lock()
+	defer t.decimalsLock.Unlock()
+
+	if t.decimals != nil {
+		return *t.decimals, nil
+	}
+
+	out, err := t.call(ctx, client, parsedABI, "decimals")
+	if err != nil {
+		return 0, err
+	}
+	decimals := *abi.ConvertType(out[0], new(uint8)).(*uint8)
+
+	t.decimals = &decimals
+	return decimals, nil
+}
+
+// Metadata returns the name, symbol and decimals of the token.
+func (t *Token) Metadata(
+	ctx context.Context,
+	client bind.ContractCaller,
+) (Metadata, error) {
+	name, err := t.Name(ctx, client)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	symbol, err := t.Symbol(ctx, client)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	decimals, err := t.Decimals(ctx, client)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	return Metadata{
+		Name:     name,
+		Symbol:   symbol,
+		Decimals: decimals,
+	}, nil
+}
+
+// TotalSupply returns the total supply of the token in its smallest units.
+func (t *Token) TotalSupply(
+	ctx context.Context,
+	client bind.ContractCaller,
+) (*big.Int, error) {
+	return t.callBigInt(ctx, client, "totalSupply")
+}
+
+// BalanceOf returns the token balance of the owner in the smallest units of
+// the token.
+func (t *Token) BalanceOf(
+	ctx context.Context,
+	client bind.ContractCaller,
+	owner common.Address,
+) (*big.Int, error) {
+	return t.callBigInt(ctx, client, "balanceOf", owner)
+}
+
+// BalanceOfDecimal returns the token balance of the owner converted using the
+// decimals of the token.
+func (t *Token) BalanceOfDecimal(
+	ctx context.Context,
+	client bind.ContractCaller,
+	owner common.Address,
+) (*big.Float, error) {
+	balance, err := t.BalanceOf(ctx, client, owner)
+	if err != nil {
+		return nil, err
+	}
+	return t.ToDecimal(ctx, client, balance)
+}
+
+// Allowance returns the amount the spender is allowed to transfer on behalf of
+// the owner, in the smallest units of the token.
+func (t *Token) Allowance(
+	ctx context.Context,
+	client bind.ContractCaller,
+	owner common.Address,
+	spender common.Address,
+) (*big.Int, error) {
+	return t.callBigInt(ctx, client, "allowance", owner, spender)
+}
+
+// ToDecimal converts an amount in the smallest units of the token to its
+// decimal representation.
+func (t *Token) ToDecimal(
+	ctx context.Context,
+	client bind.ContractCaller,
+	amount *big.Int,
+) (*big.Float, error) {
+	decimals, err := t.Decimals(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return utils.Units2Decimal(amount, decimals), nil
+}
+
+// FromDecimal converts a decimal amount of the token to its smallest units.
+func (t *Token) FromDecimal(
+	ctx context.Context,
+	client bind.ContractCaller,
+	amount *big.Float,
+) (*big.Int, error) {
+	decimals, err := t.Decimals(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return utils.Decimal2Units(amount, decimals), nil
+}
+
+// Transfer builds an unsigned transaction transferring amount tokens from the
+// account to the recipient.
+func (t *Token) Transfer(
+	ctx context.Context,
+	wallet ethereum.Wallet,
+	client ethereum.Backend,
+	account accounts.Account,
+	to common.Address,
+	amount *big.Int,
+) (*types.Transaction, error) {
+	data, err := TransferData(to, amount)
+	if err != nil {
+		return nil, err
+	}
+	return t.createTransaction(ctx, wallet, client, account, data)
+}
+
+// Approve builds an unsigned transaction allowing the spender to transfer up
+// to amount tokens on behalf of the account.
+func (t *Token) Approve(
+	ctx context.Context,
+	wallet ethereum.Wallet,
+	client ethereum.Backend,
+	account accounts.Account,
+	spender common.Address,
+	amount *big.Int,
+) (*types.Transaction, error) {
+	data, err := ApproveData(spender, amount)
+	if err != nil {
+		return nil, err
+	}
+	return t.createTransaction(ctx, wallet, client, account, data)
+}
+
+// Revoke builds an unsigned transaction setting the allowance of the spender
+// on behalf of the account back to zero.
+func (t *Token) Revoke(
+	ctx context.Context,
+	wallet ethereum.Wallet,
+	client ethereum.Backend,
+	account accounts.Account,
+	spender common.Address,
+) (*types.Transaction, error) {
+	return t.Approve(ctx, wallet, client, account, spender, new(big.Int))
+}
+
+// TransferFrom builds an unsigned transaction in which the account, as
+// spender, transfers amount tokens from the owner to the recipient.
+func (t *Token) TransferFrom(
+	ctx context.Context,
+	wallet ethereum.Wallet,
+	client ethereum.Backend,
+	account accounts.Account,
+	from common.Address,
+	to common.Address,
+	amount *big.Int,
+) (*types.Transaction, error) {
+	data, err := TransferFromData(from, to, amount)
+	if err != nil {
+		return nil, err
+	}
+	return t.createTransaction(ctx, wallet, client, account, data)
+}
+
+// TransferData returns the calldata of transfer(to, amount).
+func TransferData(to common.Address, amount *big.Int) ([]byte, error) {
+	return parsedABI.Pack("transfer", to, amount)
+}
+
+// ApproveData returns the calldata of approve(spender, amount).
+func ApproveData(spender common.Address, amount *big.Int) ([]byte, error) {
+	return parsedABI.Pack("approve", spender, amount)
+}
+
+// TransferFromData returns the calldata of transferFrom(from, to, amount).
+func TransferFromData(
+	from common.Address,
+	to common.Address,
+	amount *big.Int,
+) ([]byte, error) {
+	return parsedABI.Pack("transferFrom", from, to, amount)
+}
+
+// createTransaction builds a transaction calling the token with the calldata,
+// estimating its gas limit.
+func (t *Token) createTransaction(
+	ctx context.Context,
+	wallet ethereum.Wallet,
+	client ethereum.Backend,
+	account accounts.Account,
+	data []byte,
+) (*types.Transaction, error) {
+	return wallet.CreateTransactionWithData(
+		ctx, client, account, t.address, new(big.Int), data, 0)
+}
+
+// callBigInt calls a method of the token returning a uint256.
+func (t *Token) callBigInt(
+	ctx context.Context,
+	client bind.ContractCaller,
+	method string,
+	args ...interface{},
+) (*big.Int, error) {
+	out, err := t.call(ctx, client, parsedABI, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	return abi.ConvertType(out[0], new(big.Int)).(*big.Int), nil
+}
+
+// callString calls a method of the token returning a string, falling back to
+// bytes32 for tokens that predate the standard.
+func (t *Token) callString(
+	ctx context.Context,
+	client bind.ContractCaller,
+	method string,
+) (string, error) {
+	out, err := t.call(ctx, client, parsedABI, method)
+	if err == nil {
+		return out[0].(string), nil
+	}
+
+	out, fallbackErr := t.call(ctx, client, parsedBytes32ABI, method)
+	if fallbackErr != nil {
+		return "", err
+	}
+	value := out[0].([32]byte)
+	return string(bytes.TrimRight(value[:], "\x00")), nil
+}
+
+// call calls a read-only method of the token and unpacks its outputs.
+func (t *Token) call(
+	ctx context.Context,
+	client bind.ContractCaller,
+	contractABI abi.ABI,
+	method string,
+	args ...interface{},
+) ([]interface{}, error) {
+	input, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := geth.CallMsg{To: &t.address, Data: input}
+	output, err := client.CallContract(ctx, msg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(output) == 0 {
+		code, err := client.CodeAt(ctx, t.address, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(code) == 0 {
+			return nil, ErrNoContract
+		}
+	}
+
+	return contractABI.Unpack(method, output)
+}
+
+// mustParseABI parses the JSON ABI, panicking if it is invalid.
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
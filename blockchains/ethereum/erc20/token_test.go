@@ -0,0 +1,371 @@
+package erc20
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/hdwallet"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon " +
+	"abandon abandon abandon abandon abandon about"
+
+var (
+	tokenAddress = common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	alice        = common.HexToAddress("0x000000000000000000000000000000000000a11c")
+	bob          = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+)
+
+// fakeBackend is a backend of a London chain whose token contract returns the
+// outputs of its methods, failing the calls of methods without an output.
+type fakeBackend struct {
+	code    []byte
+	outputs map[string][]byte
+	calls   map[string]int
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		code:    []byte{0x60, 0x80},
+		outputs: map[string][]byte{},
+		calls:   map[string]int{},
+	}
+}
+
+// setOutput packs the values as the outputs of the method of the ABI.
+func (b *fakeBackend) setOutput(
+	t *testing.T,
+	method string,
+	bytes32 bool,
+	values ...interface{},
+) {
+	contractABI := parsedABI
+	if bytes32 {
+		contractABI = parsedBytes32ABI
+	}
+	output, err := contractABI.Methods[method].Outputs.Pack(values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.outputs[method] = output
+}
+
+func (b *fakeBackend) CallContract(
+	ctx context.Context,
+	msg geth.CallMsg,
+	number *big.Int,
+) ([]byte, error) {
+	for name, method := range parsedABI.Methods {
+		if bytes.HasPrefix(msg.Data, method.ID) {
+			b.calls[name]++
+			output, ok := b.outputs[name]
+			if !ok {
+				return nil, errors.New("execution reverted")
+			}
+			return output, nil
+		}
+	}
+	return nil, errors.New("unknown method")
+}
+
+func (b *fakeBackend) CodeAt(
+	ctx context.Context,
+	account common.Address,
+	number *big.Int,
+) ([]byte, error) {
+	return b.code, nil
+}
+
+func (b *fakeBackend) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (b *fakeBackend) HeaderByNumber(
+	ctx context.Context,
+	number *big.Int,
+) (*types.Header, error) {
+	return &types.Header{
+		Number:   big.NewInt(1),
+		GasLimit: 30000000,
+		BaseFee:  big.NewInt(1e9),
+	}, nil
+}
+
+func (b *fakeBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(2e9), nil
+}
+
+func (b *fakeBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1e9), nil
+}
+
+func (b *fakeBackend) EstimateGas(
+	ctx context.Context,
+	msg geth.CallMsg,
+) (uint64, error) {
+	return 50000, nil
+}
+
+func (b *fakeBackend) PendingBalanceAt(
+	ctx context.Context,
+	account common.Address,
+) (*big.Int, error) {
+	return big.NewInt(1e18), nil
+}
+
+func (b *fakeBackend) PendingStorageAt(
+	ctx context.Context,
+	account common.Address,
+	key common.Hash,
+) ([]byte, error) {
+	return nil, nil
+}
+
+func (b *fakeBackend) PendingCodeAt(
+	ctx context.Context,
+	account common.Address,
+) ([]byte, error) {
+	return b.code, nil
+}
+
+func (b *fakeBackend) PendingNonceAt(
+	ctx context.Context,
+	account common.Address,
+) (uint64, error) {
+	return 0, nil
+}
+
+func (b *fakeBackend) PendingTransactionCount(
+	ctx context.Context,
+) (uint, error) {
+	return 0, nil
+}
+
+// word returns the hex of the value left-padded to an ABI word.
+func word(value string) string {
+	return fmt.Sprintf("%064s", value)
+}
+
+func TestTransactionCalldata(t *testing.T) {
+	wallet, err := ethereum.NewSoftwareWalletFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := wallet.Derive(
+		hdwallet.DefaultCoinPath(hdwallet.CoinTypeETH, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := NewToken(tokenAddress)
+	amount := big.NewInt(1000000)
+
+	tests := []struct {
+		name   string
+		create func(
+			context.Context,
+			ethereum.Backend,
+			accounts.Account,
+		) (*types.Transaction, error)
+		data string
+	}{
+		{
+			"transfer",
+			func(
+				ctx context.Context,
+				client ethereum.Backend,
+				account accounts.Account,
+			) (*types.Transaction, error) {
+				return token.Transfer(ctx, wallet, client, account, bob, amount)
+			},
+			"a9059cbb" + word("b0b") + word("f4240"),
+		},
+		{
+			"approve",
+			func(
+				ctx context.Context,
+				client ethereum.Backend,
+				account accounts.Account,
+			) (*types.Transaction, error) {
+				return token.Approve(ctx, wallet, client, account, bob, amount)
+			},
+			"095ea7b3" + word("b0b") + word("f4240"),
+		},
+		{
+			"revoke",
+			func(
+				ctx context.Context,
+				client ethereum.Backend,
+				account accounts.Account,
+			) (*types.Transaction, error) {
+				return token.Revoke(ctx, wallet, client, account, bob)
+			},
+			"095ea7b3" + word("b0b") + word("0"),
+		},
+		{
+			"transferFrom",
+			func(
+				ctx context.Context,
+				client ethereum.Backend,
+				account accounts.Account,
+			) (*types.Transaction, error) {
+				return token.TransferFrom(
+					ctx, wallet, client, account, alice, bob, amount)
+			},
+			"23b872dd" + word("a11c") + word("b0b") + word("f4240"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tx, err := test.create(context.Background(), newFakeBackend(), account)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *tx.To() != tokenAddress || tx.Value().Sign() != 0 {
+				t.Fatal("transaction is not a call of the token")
+			}
+			if got := hex.EncodeToString(tx.Data()); got != test.data {
+				t.Fatalf("calldata %s, want %s", got, test.data)
+			}
+		})
+	}
+}
+
+func TestNameSymbol(t *testing.T) {
+	var maker [32]byte
+	copy(maker[:], "Maker")
+	var mkr [32]byte
+	copy(mkr[:], "MKR")
+
+	tests := []struct {
+		name    string
+		bytes32 bool
+		outputs [2]interface{}
+		want    [2]string
+	}{
+		{"string", false, [2]interface{}{"Dai Stablecoin", "DAI"},
+			[2]string{"Dai Stablecoin", "DAI"}},
+		{"bytes32", true, [2]interface{}{maker, mkr},
+			[2]string{"Maker", "MKR"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeBackend()
+			client.setOutput(t, "name", test.bytes32, test.outputs[0])
+			client.setOutput(t, "symbol", test.bytes32, test.outputs[1])
+			token := NewToken(tokenAddress)
+
+			name, err := token.Name(context.Background(), client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			symbol, err := token.Symbol(context.Background(), client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != test.want[0] || symbol != test.want[1] {
+				t.Fatalf("name %q and symbol %q, want %q and %q",
+					name, symbol, test.want[0], test.want[1])
+			}
+		})
+	}
+}
+
+func TestNoContract(t *testing.T) {
+	client := newFakeBackend()
+	client.code = nil
+	client.outputs["name"] = nil
+
+	_, err := NewToken(tokenAddress).Name(context.Background(), client)
+	if !errors.Is(err, ErrNoContract) {
+		t.Fatalf("Name returned %v, want %v", err, ErrNoContract)
+	}
+}
+
+func TestDecimalsCache(t *testing.T) {
+	client := newFakeBackend()
+	token := NewToken(tokenAddress)
+
+	// failed calls are not cached
+	if _, err := token.Decimals(context.Background(), client); err == nil {
+		t.Fatal("Decimals succeeded without an output")
+	}
+
+	client.setOutput(t, "decimals", false, uint8(6))
+	for i := 0; i < 3; i++ {
+		decimals, err := token.Decimals(context.Background(), client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decimals != 6 {
+			t.Fatalf("decimals %d, want 6", decimals)
+		}
+	}
+	if calls := client.calls["decimals"]; calls != 2 {
+		t.Fatalf("decimals called %d times, want 2", calls)
+	}
+}
+
+func TestDecimalConversion(t *testing.T) {
+	tests := []struct {
+		decimals uint8
+		units    string
+		decimal  string
+	}{
+		{6, "1100000", "1.1"},
+		{6, "1", "0.000001"},
+		{18, "1234500000000000000000", "1234.5"},
+		{18, "1", "0.000000000000000001"},
+		{0, "42", "42"},
+	}
+	for _, test := range tests {
+		client := newFakeBackend()
+		client.setOutput(t, "decimals", false, test.decimals)
+		token := NewToken(tokenAddress)
+		units, _ := new(big.Int).SetString(test.units, 10)
+		decimal, _, err := big.ParseFloat(test.decimal, 10, 256, big.ToNearestEven)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := token.ToDecimal(context.Background(), client, units)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text := got.Text('f', int(test.decimals)); text != decimal.Text(
+			'f', int(test.decimals)) {
+			t.Fatalf("%s units with %d decimals are %s, want %s",
+				test.units, test.decimals, text, test.decimal)
+		}
+
+		gotUnits, err := token.FromDecimal(context.Background(), client, decimal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotUnits.Cmp(units) != 0 {
+			t.Fatalf("%s with %d decimals is %v units, want %s",
+				test.decimal, test.decimals, gotUnits, test.units)
+		}
+	}
+
+	// float64 amounts are not off by binary rounding
+	client := newFakeBackend()
+	client.setOutput(t, "decimals", false, uint8(6))
+	units, err := NewToken(tokenAddress).FromDecimal(
+		context.Background(), client, big.NewFloat(1.1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if units.Int64() != 1100000 {
+		t.Fatalf("1.1 with 6 decimals is %v units, want 1100000", units)
+	}
+}
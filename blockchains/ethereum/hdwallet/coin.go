@@ -0,0 +1,400 @@
+package hdwallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/solsticewallet/solstice-core/blockchains/bitcoin"
+)
+
+// CoinType is a coin type registered in SLIP-44, the second level of BIP-44
+// derivation paths.
+type CoinType uint32
+
+const (
+	CoinTypeBTC     CoinType = 0
+	CoinTypeTestnet CoinType = 1
+	CoinTypeLTC     CoinType = 2
+	CoinTypeDOGE    CoinType = 3
+	CoinTypeETH     CoinType = 60
+	CoinTypeETC     CoinType = 61
+)
+
+var (
+	// ErrUnknownCoin is returned for coin types that are not registered.
+	ErrUnknownCoin = errors.New("unknown coin type")
+	// ErrCoinPathMismatch is returned when the coin type level of a path is
+	// not the hardened coin type of the coin it is derived for.
+	ErrCoinPathMismatch = errors.New("derivation path is for another coin")
+)
+
+// AddressEncoder encodes the public key at the derivation path as an address.
+// The path lets Bitcoin-like coins choose the address type by the BIP-43
+// purpose.
+type AddressEncoder func(
+	publicKey *btcec.PublicKey,
+	path accounts.DerivationPath,
+) (string, error)
+
+// Coin describes a SLIP-44 coin the wallet derives accounts for.
+type Coin struct {
+	Type   CoinType
+	Symbol string
+	Name   string
+	// Params are the network parameters of the coin, the version bytes of
+	// its addresses and extended keys.
+	Params *chaincfg.Params
+	// EVM is set for coins with Ethereum accounts, which the wallet pins as
+	// regular accounts so it can sign for them.
+	EVM     bool
+	Encoder AddressEncoder
+}
+
+// CoinAccount is an account of a coin derived from the seed of the wallet.
+type CoinAccount struct {
+	Coin    CoinType
+	Address string
+	URL     accounts.URL
+}
+
+// coinRegistry holds the registered coins by coin type.
+var coinRegistry = struct {
+	lock  sync.RWMutex
+	coins map[CoinType]*Coin
+}{
+	coins: map[CoinType]*Coin{},
+}
+
+func init() {
+	for _, coin := range builtinCoins() {
+		coinRegistry.coins[coin.Type] = coin
+	}
+}
+
+// RegisterCoin registers the coin, replacing a coin of the same type.
+func RegisterCoin(coin *Coin) error {
+	switch {
+	case coin.Symbol == "":
+		return fmt.Errorf("coin %d has no symbol", coin.Type)
+	case coin.Params == nil:
+		return fmt.Errorf("coin %s has no network params", coin.Symbol)
+	case coin.Encoder == nil:
+		return fmt.Errorf("coin %s has no address encoder", coin.Symbol)
+	}
+
+	coinRegistry.lock.Lock()
+	defer coinRegistry.lock.Unlock()
+
+	coinRegistry.coins[coin.Type] = coin
+	return nil
+}
+
+// CoinByType returns the registered coin of the coin type.
+func CoinByType(coinType CoinType) (*Coin, error) {
+	coinRegistry.lock.RLock()
+	defer coinRegistry.lock.RUnlock()
+
+	coin, ok := coinRegistry.coins[coinType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownCoin, coinType)
+	}
+	return coin, nil
+}
+
+// Coins returns the registered coins ordered by coin type.
+func Coins() []*Coin {
+	coinRegistry.lock.RLock()
+	defer coinRegistry.lock.RUnlock()
+
+	coins := make([]*Coin, 0, len(coinRegistry.coins))
+	for _, coin := range coinRegistry.coins {
+		coins = append(coins, coin)
+	}
+	sort.Slice(coins, func(i, j int) bool {
+		return coins[i].Type < coins[j].Type
+	})
+	return coins
+}
+
+// DefaultCoinPath returns the BIP-44 path of the address at the index of the
+// account of the coin: m/44'/coin_type'/account'/0/index.
+func DefaultCoinPath(
+	coinType CoinType,
+	account uint32,
+	index uint32,
+) accounts.DerivationPath {
+	return accounts.DerivationPath{
+		44 + hdkeychain.HardenedKeyStart,
+		uint32(coinType) + hdkeychain.HardenedKeyStart,
+		account + hdkeychain.HardenedKeyStart,
+		0,
+		index,
+	}
+}
+
+// DeriveCoin derives the account of the coin at the path and, if pin is set,
+// pins it to the wallet. The second level of the path must be the hardened
+// coin type, so the account is not pinned under the wrong coin. Accounts of
+// EVM coins are pinned as regular accounts as well.
+func (w *Wallet) DeriveCoin(
+	coinType CoinType,
+	path accounts.DerivationPath,
+	pin bool,
+) (CoinAccount, error) {
+	coin, err := CoinByType(coinType)
+	if err != nil {
+		return CoinAccount{}, err
+	}
+	if len(path) < 2 ||
+		path[1] != uint32(coinType)+hdkeychain.HardenedKeyStart {
+		return CoinAccount{}, fmt.Errorf(
+			"%w: %v is not a path of coin type %d",
+			ErrCoinPathMismatch, path, coinType)
+	}
+
+	publicKey, err := func() (*btcec.PublicKey, error) {
+		w.stateLock.RLock()
+		defer w.stateLock.RUnlock()
+
+		key, err := w.deriveExtendedPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return key.ECPubKey()
+	}()
+	if err != nil {
+		return CoinAccount{}, err
+	}
+
+	address, err := coin.Encoder(publicKey, path)
+	if err != nil {
+		return CoinAccount{}, err
+	}
+
+	account := CoinAccount{
+		Coin:    coinType,
+		Address: address,
+		URL:     accounts.URL{Path: path.String()},
+	}
+	if !pin {
+		return account, nil
+	}
+
+	if coin.EVM {
+		if _, err := w.Derive(path, true); err != nil {
+			return CoinAccount{}, err
+		}
+	}
+
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	for _, acct := range w.coinAccounts {
+		if acct.Coin == account.Coin && acct.Address == account.Address {
+			return account, nil
+		}
+	}
+	w.coinAccounts = append(w.coinAccounts, account)
+	if err := w.updateKeystore(); err != nil {
+		w.coinAccounts = w.coinAccounts[:len(w.coinAccounts)-1]
+		return CoinAccount{}, err
+	}
+	return account, nil
+}
+
+// CoinExtendedPublicKey returns the extended public key at the derivation
+// path encoded with the version bytes of the network of the coin, e.g. tpub
+// for the testnet coin type.
+func (w *Wallet) CoinExtendedPublicKey(
+	coinType CoinType,
+	path accounts.DerivationPath,
+) (string, error) {
+	coin, err := CoinByType(coinType)
+	if err != nil {
+		return "", err
+	}
+
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	key, err := w.neuteredKey(path)
+	if err != nil {
+		return "", err
+	}
+	key, err = key.CloneWithVersion(coin.Params.HDPublicKeyID[:])
+	if err != nil {
+		return "", err
+	}
+	return key.String(), nil
+}
+
+// CoinAccounts returns the coin accounts pinned to the wallet, only those of
+// the given coin types if any are given. They are listed apart from Accounts,
+// which implements accounts.Wallet and can only hold Ethereum addresses.
+func (w *Wallet) CoinAccounts(coinTypes ...CoinType) []CoinAccount {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	cpy := make([]CoinAccount, 0, len(w.coinAccounts))
+	for _, account := range w.coinAccounts {
+		if len(coinTypes) == 0 || containsCoinType(coinTypes, account.Coin) {
+			cpy = append(cpy, account)
+		}
+	}
+	return cpy
+}
+
+// UnpinCoin removes the coin account from the pinned coin accounts. The
+// regular account of an EVM coin account stays pinned.
+func (w *Wallet) UnpinCoin(account CoinAccount) error {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	for i, acct := range w.coinAccounts {
+		if acct.Coin == account.Coin && acct.Address == account.Address {
+			prev := w.coinAccounts
+			w.coinAccounts = append(
+				append([]CoinAccount{}, prev[:i]...), prev[i+1:]...)
+			if err := w.updateKeystore(); err != nil {
+				w.coinAccounts = prev
+				return err
+			}
+			return nil
+		}
+	}
+	return errors.New("account not found")
+}
+
+// containsCoinType returns whether the coin type is in the list.
+func containsCoinType(coinTypes []CoinType, coinType CoinType) bool {
+	for _, t := range coinTypes {
+		if t == coinType {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinCoins returns the coins registered by default.
+func builtinCoins() []*Coin {
+	return []*Coin{
+		{
+			Type:    CoinTypeBTC,
+			Symbol:  "BTC",
+			Name:    "Bitcoin",
+			Params:  &chaincfg.MainNetParams,
+			Encoder: bitcoinEncoder(&chaincfg.MainNetParams),
+		},
+		{
+			Type:    CoinTypeTestnet,
+			Symbol:  "tBTC",
+			Name:    "Bitcoin Testnet",
+			Params:  &chaincfg.TestNet3Params,
+			Encoder: bitcoinEncoder(&chaincfg.TestNet3Params),
+		},
+		{
+			Type:    CoinTypeLTC,
+			Symbol:  "LTC",
+			Name:    "Litecoin",
+			Params:  &LitecoinParams,
+			Encoder: bitcoinEncoder(&LitecoinParams),
+		},
+		{
+			Type:    CoinTypeDOGE,
+			Symbol:  "DOGE",
+			Name:    "Dogecoin",
+			Params:  &DogecoinParams,
+			Encoder: bitcoinEncoder(&DogecoinParams),
+		},
+		{
+			Type:    CoinTypeETH,
+			Symbol:  "ETH",
+			Name:    "Ethereum",
+			Params:  &chaincfg.MainNetParams,
+			EVM:     true,
+			Encoder: ethereumEncoder,
+		},
+		{
+			Type:    CoinTypeETC,
+			Symbol:  "ETC",
+			Name:    "Ethereum Classic",
+			Params:  &chaincfg.MainNetParams,
+			EVM:     true,
+			Encoder: ethereumEncoder,
+		},
+	}
+}
+
+// ethereumEncoder encodes the public key as an EIP-55 checksummed address.
+func ethereumEncoder(
+	publicKey *btcec.PublicKey,
+	path accounts.DerivationPath,
+) (string, error) {
+	return crypto.PubkeyToAddress(*publicKey.ToECDSA()).Hex(), nil
+}
+
+// bitcoinEncoder returns an encoder of addresses of a Bitcoin-like network,
+// whose type follows the purpose of the path: P2PKH for BIP-44, P2SH-P2WPKH
+// for BIP-49, P2WPKH for BIP-84 and P2TR for BIP-86. Segwit addresses require
+// the network to have a bech32 prefix.
+func bitcoinEncoder(params *chaincfg.Params) AddressEncoder {
+	return func(
+		publicKey *btcec.PublicKey,
+		path accounts.DerivationPath,
+	) (string, error) {
+		if len(path) == 0 {
+			return "", errors.New("empty derivation path")
+		}
+
+		addrType := bitcoin.P2PKH
+		for _, t := range []bitcoin.AddressType{
+			bitcoin.P2PKH, bitcoin.P2SHP2WPKH, bitcoin.P2WPKH, bitcoin.P2TR,
+		} {
+			if path[0] == t.Purpose()+hdkeychain.HardenedKeyStart {
+				addrType = t
+			}
+		}
+		if addrType != bitcoin.P2PKH && params.Bech32HRPSegwit == "" {
+			return "", fmt.Errorf(
+				"%s addresses are not supported on %s", addrType, params.Name)
+		}
+
+		address, err := bitcoin.NewAddress(addrType, publicKey, params)
+		if err != nil {
+			return "", err
+		}
+		return address.EncodeAddress(), nil
+	}
+}
+
+// LitecoinParams are the network parameters of the Litecoin main network
+// needed to encode its addresses and keys.
+var LitecoinParams = chaincfg.Params{
+	Name:             "litecoin",
+	Bech32HRPSegwit:  "ltc",
+	PubKeyHashAddrID: 0x30,
+	ScriptHashAddrID: 0x32,
+	PrivateKeyID:     0xb0,
+	HDPrivateKeyID:   [4]byte{0x04, 0x88, 0xad, 0xe4},
+	HDPublicKeyID:    [4]byte{0x04, 0x88, 0xb2, 0x1e},
+	HDCoinType:       uint32(CoinTypeLTC),
+}
+
+// DogecoinParams are the network parameters of the Dogecoin main network
+// needed to encode its addresses and keys.
+var DogecoinParams = chaincfg.Params{
+	Name:             "dogecoin",
+	PubKeyHashAddrID: 0x1e,
+	ScriptHashAddrID: 0x16,
+	PrivateKeyID:     0x9e,
+	HDPrivateKeyID:   [4]byte{0x02, 0xfa, 0xc3, 0x98},
+	HDPublicKeyID:    [4]byte{0x02, 0xfa, 0xca, 0xfd},
+	HDCoinType:       uint32(CoinTypeDOGE),
+}
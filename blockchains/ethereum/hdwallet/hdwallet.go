@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
@@ -16,8 +17,8 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/solsticewallet/solstice-core/bip39"
 	"github.com/solsticewallet/solstice-core/blockchains/ethereum/utils"
-	"github.com/tyler-smith/go-bip39"
 )
 
 // This code is based upon the code form:
@@ -39,13 +40,24 @@ type Wallet struct {
 	seed      []byte
 	url       accounts.URL
 	paths     map[common.Address]accounts.DerivationPath
+	imported  map[common.Address]*ecdsa.PrivateKey
 	accounts  []accounts.Account
-	stateLock sync.RWMutex
+	keystore  *keystoreFile
+	storeKey  []byte
+
+	coinAccounts []CoinAccount
+	stateLock    sync.RWMutex
+
+	pubKeys    map[string]*hdkeychain.ExtendedKey
+	pubKeyLock sync.Mutex
+	keyCache   *keyCache
 }
 
 // newWallet creates a new Wallet using the provided seed.
 //
 // It takes a seed []byte as a parameter and returns a *Wallet and an error.
+// The network of the master key only sets the version bytes of serialized
+// keys, which CoinExtendedPublicKey replaces with those of the coin.
 func newWallet(seed []byte) (*Wallet, error) {
 	masterKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
 	if err != nil {
@@ -57,6 +69,9 @@ func newWallet(seed []byte) (*Wallet, error) {
 		seed:      seed,
 		accounts:  []accounts.Account{},
 		paths:     map[common.Address]accounts.DerivationPath{},
+		imported:  map[common.Address]*ecdsa.PrivateKey{},
+		pubKeys:   map[string]*hdkeychain.ExtendedKey{},
+		keyCache:  newKeyCache(DefaultKeyCacheSize, DefaultKeyCacheTTL),
 	}, nil
 }
 
@@ -66,11 +81,12 @@ func NewFromMnemonic(mnemonic string, passOpt ...string) (*Wallet, error) {
 		return nil, errors.New("mnemonic is required")
 	}
 
-	if !bip39.IsMnemonicValid(mnemonic) {
-		return nil, errors.New("mnemonic is invalid")
+	canonical, err := bip39.CanonicalMnemonic(mnemonic)
+	if err != nil {
+		return nil, fmt.Errorf("mnemonic is invalid: %w", err)
 	}
 
-	seed, err := utils.NewSeedFromMnemonic(mnemonic, passOpt...)
+	seed, err := utils.NewSeedFromMnemonic(canonical, passOpt...)
 	if err != nil {
 		return nil, err
 	}
@@ -79,7 +95,7 @@ func NewFromMnemonic(mnemonic string, passOpt ...string) (*Wallet, error) {
 	if err != nil {
 		return nil, err
 	}
-	wallet.mnemonic = mnemonic
+	wallet.mnemonic = canonical
 
 	return wallet, nil
 }
@@ -98,25 +114,57 @@ func (w *Wallet) URL() accounts.URL {
 	return w.url
 }
 
-// Status implements accounts.Wallet, returning a custom status message from the
-// underlying vendor-specivic hardware wallet implementation, however this does
-// nothing since this is not a hardware device.
+// Status implements accounts.Wallet, returning whether the secrets of the
+// wallet are loaded.
 func (w *Wallet) Status() (string, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	if w.masterKey == nil {
+		return "locked", nil
+	}
 	return "ok", nil
 }
 
-// Open implements accounts.Wallet, however this does nothing since this is not
-// a hardware device.
+// Open implements accounts.Wallet, decrypting the keystore of the wallet with
+// the passphrase. Wallets that are not backed by a keystore are always open.
 func (w *Wallet) Open(passphrase string) error {
-	return nil
+	if !w.hasKeystore() {
+		return nil
+	}
+	return w.unlock(passphrase)
 }
 
-// Close implements accounts.Wallet, however this dow nothing since this is not
-// a hardware wallet
+// Close implements accounts.Wallet, wiping the secrets of wallets backed by a
+// keystore from memory until the wallet is opened again.
 func (w *Wallet) Close() error {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	if w.keystore != nil {
+		w.lock()
+	}
 	return nil
 }
 
+// Lock wipes the key cache, the seed, the master key and imported keys from
+// memory. Wallets backed by a keystore can be opened again with their
+// passphrase, other wallets can only derive the public keys of the accounts
+// they derived before.
+func (w *Wallet) Lock() {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	w.lock()
+}
+
+// SetKeyCache sets the number of intermediate extended private keys the wallet
+// caches and for how long. A size of zero disables the cache, a ttl of zero
+// keeps keys cached until they are evicted or the wallet is locked.
+func (w *Wallet) SetKeyCache(size int, ttl time.Duration) {
+	w.keyCache.resize(size, ttl)
+}
+
 // Accounts implements accounts.Wallet, returning the list of accounts pinned to
 // the wallet. If self-derivation was enabled, the account list is periodically
 // expanded based on current chain state.
@@ -137,20 +185,43 @@ func (w *Wallet) Contains(account accounts.Account) bool {
 	w.stateLock.RLock()
 	defer w.stateLock.RUnlock()
 
-	_, exists := w.paths[account.Address]
+	if _, exists := w.paths[account.Address]; exists {
+		return true
+	}
+	_, exists := w.imported[account.Address]
 	return exists
 }
 
 // Unpin unpins account from list of pinned accounts.
 func (w *Wallet) Unpin(account accounts.Account) error {
-	w.stateLock.RLock()
-	defer w.stateLock.RUnlock()
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
 
 	addrStr := account.Address.String()
 	for i, acct := range w.accounts {
 		if acct.Address.String() == addrStr {
-			w.accounts = removeAtIndex(w.accounts, i)
+			prev := w.accounts
+			path, pinned := w.paths[account.Address]
+			key, imported := w.imported[account.Address]
+
+			w.accounts = removeAtIndex(
+				append([]accounts.Account{}, w.accounts...), i)
 			delete(w.paths, account.Address)
+			delete(w.imported, account.Address)
+			if err := w.updateKeystore(); err != nil {
+				w.accounts = prev
+				if pinned {
+					w.paths[account.Address] = path
+				}
+				if imported {
+					w.imported[account.Address] = key
+				}
+				return err
+			}
+
+			if key != nil {
+				zeroKey(key)
+			}
 			return nil
 		}
 	}
@@ -191,9 +262,16 @@ func (w *Wallet) Derive(
 	w.stateLock.Lock()
 	defer w.stateLock.Unlock()
 
-	if _, ok := w.paths[address]; !ok {
+	_, pinned := w.paths[address]
+	_, imported := w.imported[address]
+	if !pinned && !imported {
 		w.accounts = append(w.accounts, account)
 		w.paths[address] = path
+		if err := w.updateKeystore(); err != nil {
+			w.accounts = w.accounts[:len(w.accounts)-1]
+			delete(w.paths, address)
+			return accounts.Account{}, err
+		}
 	}
 	return account, nil
 }
@@ -211,17 +289,19 @@ func (w *Wallet) SelfDerive(
 		iter := accounts.DefaultIterator(basePath)
 		numEmpty := 0
 		for numEmpty < 10 {
-			derivPath, err := accounts.ParseDerivationPath(iter().String())
+			// The iterator reuses its path, copy it as it may get pinned
+			derivPath := append(accounts.DerivationPath{}, iter()...)
+
+			addr, err := func() (common.Address, error) {
+				w.stateLock.RLock()
+				defer w.stateLock.RUnlock()
+				return w.deriveAddress(derivPath)
+			}()
 			if err != nil {
 				return
 			}
 
-			addr, err := w.deriveAddress(derivPath)
-			if err != nil {
-				return
-			}
-
-			used, err := w.isAddressUsed(ctx, addr, chain)
+			used, err := isAddressUsed(ctx, addr, chain)
 			if err != nil {
 				return
 			}
@@ -242,12 +322,10 @@ func (w *Wallet) SignHash(
 	account accounts.Account,
 	hash []byte,
 ) ([]byte, error) {
-	path, ok := w.paths[account.Address]
-	if !ok {
-		return nil, accounts.ErrUnknownAccount
-	}
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
 
-	privateKey, err := w.derivePrivateKey(path)
+	privateKey, err := w.signingKey(account)
 	if err != nil {
 		return nil, err
 	}
@@ -265,12 +343,7 @@ func (w *Wallet) SignTxEIP155(
 	w.stateLock.RLock()
 	defer w.stateLock.RUnlock()
 
-	path, ok := w.paths[account.Address]
-	if !ok {
-		return nil, accounts.ErrUnknownAccount
-	}
-
-	privateKey, err := w.derivePrivateKey(path)
+	privateKey, err := w.signingKey(account)
 	if err != nil {
 		return nil, err
 	}
@@ -306,16 +379,20 @@ func (w *Wallet) SignTx(
 	w.stateLock.RLock()
 	defer w.stateLock.RUnlock()
 
-	path, ok := w.paths[account.Address]
-	if !ok {
-		return nil, accounts.ErrUnknownAccount
-	}
-
-	privateKey, err := w.derivePrivateKey(path)
+	privateKey, err := w.signingKey(account)
 	if err != nil {
 		return nil, err
 	}
+	return signTx(privateKey, account, tx, chainID)
+}
 
+// signTx signs the transaction with the private key of the account.
+func signTx(
+	privateKey *ecdsa.PrivateKey,
+	account accounts.Account,
+	tx *types.Transaction,
+	chainID *big.Int,
+) (*types.Transaction, error) {
 	signer := types.LatestSignerForChainID(chainID)
 
 	signedTx, err := types.SignTx(tx, signer, privateKey)
@@ -345,8 +422,15 @@ func (w *Wallet) SignHashWithPassphrase(
 	passphrase string,
 	hash []byte,
 ) ([]byte, error) {
-	// TODO Implement passphrase ??
-	return w.SignHash(account, hash)
+	if !w.hasKeystore() {
+		return w.SignHash(account, hash)
+	}
+
+	privateKey, err := w.privateKeyWithPassphrase(account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash, privateKey)
 }
 
 // SignTxWithPassphrase implements accounts.Wallet, attempting to sign the given
@@ -357,18 +441,34 @@ func (w *Wallet) SignTxWithPassphrase(
 	tx *types.Transaction,
 	chainID *big.Int,
 ) (*types.Transaction, error) {
-	// TODO Implement passphrase ??
-	return w.SignTx(account, tx, chainID)
+	if !w.hasKeystore() {
+		return w.SignTx(account, tx, chainID)
+	}
+
+	privateKey, err := w.privateKeyWithPassphrase(account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return signTx(privateKey, account, tx, chainID)
 }
 
 // PrivateKey returns the ECDSA private key of the account.
 func (w *Wallet) PrivateKey(
 	account accounts.Account,
 ) (*ecdsa.PrivateKey, error) {
+	if w.isImported(account) {
+		w.stateLock.RLock()
+		defer w.stateLock.RUnlock()
+		return w.signingKey(account)
+	}
+
 	path, err := utils.ParseDerivationPath(account.URL.Path)
 	if err != nil {
 		return nil, err
 	}
+
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
 	return w.derivePrivateKey(path)
 }
 
@@ -393,10 +493,21 @@ func (w *Wallet) PrivateKeyHex(account accounts.Account) (string, error) {
 
 // PublicKey returns the ECDSA public key of the acount.
 func (w *Wallet) PublicKey(account accounts.Account) (*ecdsa.PublicKey, error) {
+	if w.isImported(account) {
+		privateKey, err := w.PrivateKey(account)
+		if err != nil {
+			return nil, err
+		}
+		return &privateKey.PublicKey, nil
+	}
+
 	path, err := utils.ParseDerivationPath(account.URL.Path)
 	if err != nil {
 		return nil, err
 	}
+
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
 	return w.derivePublicKey(path)
 }
 
@@ -448,11 +559,31 @@ func (w *Wallet) AddressHex(account accounts.Account) (string, error) {
 
 // Path returns the derivation path of the account.
 func (w *Wallet) Path(account accounts.Account) (string, error) {
+	if w.isImported(account) {
+		return "", ErrImportedAccount
+	}
 	return account.URL.Path, nil
 }
 
-// SignData signs keccak256(data). The mimetype parameter describes the type of
-// data being signed.
+// ExtendedPublicKey returns the BIP-32 extended public key (xpub) at the
+// derivation path, e.g. the account level m/44'/60'/0', to create a watch-only
+// wallet from.
+func (w *Wallet) ExtendedPublicKey(
+	path accounts.DerivationPath,
+) (string, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	key, err := w.neuteredKey(path)
+	if err != nil {
+		return "", err
+	}
+	return key.String(), nil
+}
+
+// SignData implements accounts.Wallet, attempting to sign the data with the
+// given account. The mimetype selects how the data is hashed, see
+// signDataHash.
 func (w *Wallet) SignData(
 	account accounts.Account,
 	mimetype string,
@@ -461,11 +592,20 @@ func (w *Wallet) SignData(
 	if !w.Contains(account) {
 		return nil, accounts.ErrUnknownAccount
 	}
-	return w.SignHash(account, crypto.Keccak256(data))
+
+	hash, canonicalV, err := signDataHash(mimetype, data)
+	if err != nil {
+		return nil, err
+	}
+	if !canonicalV {
+		return w.SignHash(account, hash)
+	}
+	return ethereumV(w.SignHash(account, hash))
 }
 
-// SignDataWithPassphrase signs keccak256(data). The mimietype parameter
-// describes the type of data being signed.
+// SignDataWithPassphrase implements accounts.Wallet, attempting to sign the
+// data with the given account using the passphrase as extra authentication.
+// The mimetype selects how the data is hashed, see signDataHash.
 func (w *Wallet) SignDataWithPassphrase(
 	account accounts.Account,
 	passphrase string,
@@ -475,7 +615,15 @@ func (w *Wallet) SignDataWithPassphrase(
 	if !w.Contains(account) {
 		return nil, accounts.ErrUnknownAccount
 	}
-	return w.SignHashWithPassphrase(account, passphrase, crypto.Keccak256(data))
+
+	hash, canonicalV, err := signDataHash(mimetype, data)
+	if err != nil {
+		return nil, err
+	}
+	if !canonicalV {
+		return w.SignHashWithPassphrase(account, passphrase, hash)
+	}
+	return ethereumV(w.SignHashWithPassphrase(account, passphrase, hash))
 }
 
 // SignText implements accounts.Wallet, attempting to sign the given text. The
@@ -505,42 +653,212 @@ func (w *Wallet) SignTextWithPassphrase(
 		account, passphrase, accounts.TextHash(text))
 }
 
+// hasKeystore returns whether the wallet is backed by a keystore file.
+func (w *Wallet) hasKeystore() bool {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	return w.keystore != nil
+}
+
+// signingKey returns the private key of a pinned or imported account. The
+// caller must hold the state lock.
+func (w *Wallet) signingKey(
+	account accounts.Account,
+) (*ecdsa.PrivateKey, error) {
+	if privateKey, ok := w.imported[account.Address]; ok {
+		if privateKey == nil {
+			return nil, ErrWalletLocked
+		}
+		return privateKey, nil
+	}
+
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	return w.derivePrivateKey(path)
+}
+
 // derivePrivateKey derives the private key of the derivation path.
 func (w *Wallet) derivePrivateKey(
 	path accounts.DerivationPath,
 ) (*ecdsa.PrivateKey, error) {
-	var err error
+	key, err := w.deriveExtendedKey(path)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zero()
+
+	return extendedPrivateKey(key)
+}
+
+// deriveExtendedKey derives the extended private key of the derivation path.
+// The key of the parent node, e.g. m/44'/60'/0'/0 for m/44'/60'/0'/0/1, is
+// taken from the key cache or cached after deriving it from the master key,
+// so consecutive keys of the same account only need a single derivation. The
+// caller must hold the state lock and zero the returned key.
+func (w *Wallet) deriveExtendedKey(
+	path accounts.DerivationPath,
+) (*hdkeychain.ExtendedKey, error) {
+	if w.masterKey == nil {
+		return nil, ErrWalletLocked
+	}
+	if len(path) == 0 {
+		return cloneExtendedKey(w.masterKey)
+	}
+
+	parentPath := path[:len(path)-1]
+	id := parentPath.String()
+
+	parent, ok := w.keyCache.get(id)
+	if !ok {
+		var err error
+		if parent, err = deriveExtendedKeyFrom(w.masterKey, parentPath); err != nil {
+			return nil, err
+		}
+		w.keyCache.add(id, parent)
+	}
+	defer parent.Zero()
+
+	return parent.Derive(path[len(path)-1])
+}
+
+// derivePrivateKeyFrom derives the private key of the derivation path starting
+// at the master key.
+func derivePrivateKeyFrom(
+	masterKey *hdkeychain.ExtendedKey,
+	path accounts.DerivationPath,
+) (*ecdsa.PrivateKey, error) {
+	key, err := deriveExtendedKeyFrom(masterKey, path)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zero()
+
+	return extendedPrivateKey(key)
+}
 
-	key := w.masterKey
-	for _, n := range path {
-		if key, err = key.Derive(n); err != nil {
+// deriveExtendedKeyFrom derives the extended private key of the relative path
+// starting at the given key. Intermediate keys are zeroed, the starting key is
+// left untouched and the caller must zero the returned key.
+func deriveExtendedKeyFrom(
+	key *hdkeychain.ExtendedKey,
+	relPath accounts.DerivationPath,
+) (*hdkeychain.ExtendedKey, error) {
+	if len(relPath) == 0 {
+		return cloneExtendedKey(key)
+	}
+
+	start := key
+	for _, n := range relPath {
+		child, err := key.Derive(n)
+		if key != start {
+			key.Zero()
+		}
+		if err != nil {
 			return nil, err
 		}
+		key = child
 	}
+	return key, nil
+}
 
+// extendedPrivateKey returns the ECDSA private key of the extended key.
+func extendedPrivateKey(
+	key *hdkeychain.ExtendedKey,
+) (*ecdsa.PrivateKey, error) {
 	privateKey, err := key.ECPrivKey()
 	if err != nil {
 		return nil, err
 	}
+	defer privateKey.Zero()
 
 	return privateKey.ToECDSA(), nil
 }
 
-// derivePublicKey derives the publick ey of the derivation path.
+// derivePublicKey derives the public key of the derivation path. Only the
+// hardened part of the path is derived from the master key, once, after which
+// its extended public key is cached and the remainder of the path is derived
+// without touching private keys.
 func (w *Wallet) derivePublicKey(
 	path accounts.DerivationPath,
 ) (*ecdsa.PublicKey, error) {
-	privateKeyECDSA, err := w.derivePrivateKey(path)
+	key, err := w.deriveExtendedPublicKey(path)
 	if err != nil {
 		return nil, err
 	}
+	return extendedPublicKey(key)
+}
 
-	publicKey := privateKeyECDSA.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, errors.New("failed to get public key")
+// deriveExtendedPublicKey derives the extended public key of the derivation
+// path from the cached extended public key of its parent.
+func (w *Wallet) deriveExtendedPublicKey(
+	path accounts.DerivationPath,
+) (*hdkeychain.ExtendedKey, error) {
+	if len(path) == 0 || path[len(path)-1] >= hdkeychain.HardenedKeyStart {
+		return w.neuteredKey(path)
+	}
+
+	parent, err := w.neuteredKey(path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	return parent.Derive(path[len(path)-1])
+}
+
+// neuteredKey returns the cached extended public key of the derivation path,
+// deriving and caching it on first use.
+func (w *Wallet) neuteredKey(
+	path accounts.DerivationPath,
+) (*hdkeychain.ExtendedKey, error) {
+	id := path.String()
+
+	w.pubKeyLock.Lock()
+	key, ok := w.pubKeys[id]
+	w.pubKeyLock.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var err error
+	if len(path) > 0 && path[len(path)-1] < hdkeychain.HardenedKeyStart {
+		parent, err := w.neuteredKey(path[:len(path)-1])
+		if err != nil {
+			return nil, err
+		}
+		key, err = parent.Derive(path[len(path)-1])
+		if err != nil {
+			return nil, err
+		}
+	} else if key, err = w.deriveNeuteredKey(path); err != nil {
+		return nil, err
+	}
+
+	w.pubKeyLock.Lock()
+	w.pubKeys[id] = key
+	w.pubKeyLock.Unlock()
+	return key, nil
+}
+
+// deriveNeuteredKey derives the extended public key of a path ending in a
+// hardened index, which requires the private derivation from the master key.
+func (w *Wallet) deriveNeuteredKey(
+	path accounts.DerivationPath,
+) (*hdkeychain.ExtendedKey, error) {
+	key, err := w.deriveExtendedKey(path)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zero()
+
+	neutered, err := key.Neuter()
+	if err != nil {
+		return nil, err
 	}
-	return publicKeyECDSA, nil
+	// The neutered key shares its buffers with the private key, which is
+	// about to be zeroed, round-trip it to get an independent copy.
+	return hdkeychain.NewKeyFromString(neutered.String())
 }
 
 // deriveAddress derives the account address of the drivation path.
@@ -554,7 +872,9 @@ func (w *Wallet) deriveAddress(
 	return crypto.PubkeyToAddress(*publicKeyECDSA), nil
 }
 
-func (w *Wallet) isAddressUsed(
+// isAddressUsed returns whether the address holds a balance or has sent
+// transactions.
+func isAddressUsed(
 	ctx context.Context,
 	address common.Address,
 	chain ethereum.ChainStateReader,
@@ -582,5 +902,5 @@ func (w *Wallet) isAddressUsed(
 
 // removAtIndex removes an account at index.
 func removeAtIndex(accts []accounts.Account, index int) []accounts.Account {
-	return append(accts[:index], accts[index-1:]...)
+	return append(accts[:index], accts[index+1:]...)
 }
@@ -0,0 +1,172 @@
+package hdwallet
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/solsticewallet/solstice-core/bip39"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon " +
+	"abandon abandon abandon abandon abandon about"
+
+func TestLockWhileDeriving(t *testing.T) {
+	wallet, err := NewFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without the key cache every derivation starts at the master key
+	wallet.SetKeyCache(0, 0)
+	account, err := wallet.Derive(DefaultCoinPath(CoinTypeETH, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the goroutines keep deriving the key while the wallet is locked
+	var wg sync.WaitGroup
+	started := make(chan struct{}, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				privateKey, err := wallet.PrivateKey(account)
+				if j == 0 {
+					started <- struct{}{}
+				}
+				if errors.Is(err, ErrWalletLocked) {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if crypto.PubkeyToAddress(privateKey.PublicKey) != account.Address {
+					t.Error("derived the key of another account")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		<-started
+	}
+	wallet.Lock()
+	wg.Wait()
+
+	if _, err := wallet.PrivateKey(account); !errors.Is(err, ErrWalletLocked) {
+		t.Fatalf("PrivateKey after Lock returned %v, want %v", err, ErrWalletLocked)
+	}
+}
+
+func TestNewFromMnemonicWordError(t *testing.T) {
+	_, err := NewFromMnemonic(strings.Replace(testMnemonic, "about", "abuot", 1))
+
+	var wordErr *bip39.WordError
+	if !errors.As(err, &wordErr) {
+		t.Fatalf("NewFromMnemonic returned %v, want a word error", err)
+	}
+	if !errors.Is(err, bip39.ErrUnknownWord) {
+		t.Fatalf("NewFromMnemonic returned %v, want %v", err, bip39.ErrUnknownWord)
+	}
+	if wordErr.Index != 11 || wordErr.Word != "abuot" ||
+		len(wordErr.Suggestions) == 0 || wordErr.Suggestions[0] != "about" {
+		t.Fatalf("word error %+v, want word 11 abuot suggesting about", wordErr)
+	}
+}
+
+func TestRecoverMnemonic(t *testing.T) {
+	// the first Ethereum address of the mnemonic
+	const address = "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+	path := DefaultCoinPath(CoinTypeETH, 0, 0)
+
+	tests := []struct {
+		name     string
+		mnemonic string
+		address  string
+		err      error
+	}{
+		{"valid mnemonic", strings.ToUpper(testMnemonic), address, nil},
+		{
+			"misspelt word",
+			strings.Replace(testMnemonic, "about", "abuot", 1),
+			strings.ToLower(address),
+			nil,
+		},
+		{
+			"other address",
+			strings.Replace(testMnemonic, "about", "abuot", 1),
+			"0x000000000000000000000000000000000000dEaD",
+			ErrNoMatchingMnemonic,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recovered, err := RecoverMnemonic(context.Background(),
+				test.mnemonic, CoinTypeETH, path, test.address)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("RecoverMnemonic returned %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recovered != testMnemonic {
+				t.Fatalf("recovered %q, want %q", recovered, testMnemonic)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := RecoverMnemonic(ctx,
+		strings.Replace(testMnemonic, "about", "abuot", 1),
+		CoinTypeETH, path, address)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RecoverMnemonic returned %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDeriveCoinPathMismatch(t *testing.T) {
+	wallet, err := NewFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []accounts.DerivationPath{
+		DefaultCoinPath(CoinTypeETH, 0, 0),
+		{44 + hdkeychain.HardenedKeyStart},
+		// the coin type must be hardened
+		{44 + hdkeychain.HardenedKeyStart, uint32(CoinTypeBTC), 0, 0, 0},
+	} {
+		_, err := wallet.DeriveCoin(CoinTypeBTC, path, true)
+		if !errors.Is(err, ErrCoinPathMismatch) {
+			t.Fatalf("DeriveCoin(%v) returned %v, want %v",
+				path, err, ErrCoinPathMismatch)
+		}
+	}
+	if coins := wallet.CoinAccounts(); len(coins) != 0 {
+		t.Fatalf("pinned coin accounts %v", coins)
+	}
+
+	// the purpose is free, e.g. to derive native segwit addresses
+	account, err := wallet.DeriveCoin(CoinTypeBTC, accounts.DerivationPath{
+		84 + hdkeychain.HardenedKeyStart,
+		uint32(CoinTypeBTC) + hdkeychain.HardenedKeyStart,
+		hdkeychain.HardenedKeyStart, 0, 0,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Address != "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu" {
+		t.Fatalf("address %s, want the first BIP-84 address", account.Address)
+	}
+}
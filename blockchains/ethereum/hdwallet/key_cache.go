@@ -0,0 +1,202 @@
+package hdwallet
+
+import (
+	"container/list"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+const (
+	// DefaultKeyCacheSize is the number of intermediate extended keys a
+	// wallet caches by default.
+	DefaultKeyCacheSize = 16
+	// DefaultKeyCacheTTL is the time an intermediate extended key stays
+	// cached by default.
+	DefaultKeyCacheTTL = 5 * time.Minute
+)
+
+// keyCache is an LRU cache of extended private keys with a time to live. Keys
+// are copied in and out of the cache, and zeroed when they are evicted, expire
+// or the cache gets purged. Expired keys are removed by a timer, so they do
+// not stay in memory while the wallet is idle.
+type keyCache struct {
+	lock     sync.Mutex
+	size     int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	order    *list.List
+	timer    *time.Timer
+	deadline time.Time
+}
+
+type keyCacheEntry struct {
+	id    string
+	key   *hdkeychain.ExtendedKey
+	added time.Time
+}
+
+// newKeyCache creates a cache holding at most size keys for at most ttl. A
+// size of zero disables the cache, a ttl of zero disables expiry.
+func newKeyCache(size int, ttl time.Duration) *keyCache {
+	return &keyCache{
+		size:    size,
+		ttl:     ttl,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// get returns a copy of the cached key, which the caller must zero after use.
+func (c *keyCache) get(id string) (*hdkeychain.ExtendedKey, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.expire()
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*keyCacheEntry)
+	key, err := cloneExtendedKey(entry.key)
+	if err != nil {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return key, true
+}
+
+// add caches a copy of the key, evicting the least recently used keys when
+// the cache is full.
+func (c *keyCache) add(id string, key *hdkeychain.ExtendedKey) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+	if elem, ok := c.entries[id]; ok {
+		c.remove(elem)
+	}
+	c.expire()
+
+	cpy, err := cloneExtendedKey(key)
+	if err != nil {
+		return
+	}
+
+	c.entries[id] = c.order.PushFront(&keyCacheEntry{
+		id:    id,
+		key:   cpy,
+		added: time.Now(),
+	})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	c.expire()
+}
+
+// resize changes the size and ttl of the cache, evicting keys that no longer
+// fit.
+func (c *keyCache) resize(size int, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.size = size
+	c.ttl = ttl
+	for c.order.Len() > 0 && c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	c.expire()
+}
+
+// purge zeroes and removes all cached keys.
+func (c *keyCache) purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+	c.expire()
+}
+
+// expire zeroes and removes the expired keys, and schedules the removal of the
+// next key to expire. Keys are ordered by use rather than age, so every key is
+// checked. The caller must hold the lock.
+func (c *keyCache) expire() {
+	var next time.Time
+	if c.ttl > 0 {
+		now := time.Now()
+		for elem := c.order.Back(); elem != nil; {
+			prev := elem.Prev()
+			expiry := elem.Value.(*keyCacheEntry).added.Add(c.ttl)
+			if !now.Before(expiry) {
+				c.remove(elem)
+			} else if next.IsZero() || expiry.Before(next) {
+				next = expiry
+			}
+			elem = prev
+		}
+	}
+
+	if c.timer != nil && c.deadline.Equal(next) {
+		return
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.deadline = next
+	if next.IsZero() {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(next), func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		// a timer stopped while firing was replaced already
+		if c.timer == timer {
+			c.timer = nil
+			c.expire()
+		}
+	})
+	c.timer = timer
+}
+
+// remove zeroes and removes the cache entry. The caller must hold the lock.
+func (c *keyCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*keyCacheEntry)
+	entry.key.Zero()
+	delete(c.entries, entry.id)
+}
+
+// cloneExtendedKey returns a copy of the extended private key that shares no
+// buffers with the original, so either can be zeroed independently.
+func cloneExtendedKey(
+	key *hdkeychain.ExtendedKey,
+) (*hdkeychain.ExtendedKey, error) {
+	privateKey, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	defer privateKey.Zero()
+
+	parentFP := make([]byte, 4)
+	binary.BigEndian.PutUint32(parentFP, key.ParentFingerprint())
+
+	return hdkeychain.NewExtendedKey(
+		append([]byte{}, key.Version()...),
+		privateKey.Serialize(),
+		key.ChainCode(),
+		parentFP,
+		key.Depth(),
+		key.ChildIndex(),
+		true,
+	), nil
+}
@@ -0,0 +1,72 @@
+package hdwallet
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func newTestKey(t *testing.T, seed byte) *hdkeychain.ExtendedKey {
+	key, err := hdkeychain.NewMaster(
+		[]byte(fmt.Sprintf("%032d", seed)), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// cachedKeys returns the number of keys in the cache.
+func cachedKeys(c *keyCache) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.entries)
+}
+
+func TestKeyCacheExpiry(t *testing.T) {
+	c := newKeyCache(4, 50*time.Millisecond)
+	c.add("old", newTestKey(t, 1))
+	time.Sleep(60 * time.Millisecond)
+
+	// adding a key removes the expired ones, not only those looked up
+	c.add("new", newTestKey(t, 2))
+	if n := cachedKeys(c); n != 1 {
+		t.Fatalf("%d keys cached, want 1", n)
+	}
+	key, ok := c.get("new")
+	if !ok {
+		t.Fatal("key was not cached")
+	}
+	key.Zero()
+
+	// expired keys are removed while the cache is not used
+	deadline := time.Now().Add(5 * time.Second)
+	for cachedKeys(c) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expired key was not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := c.get("new"); ok {
+		t.Fatal("expired key was returned")
+	}
+}
+
+func TestKeyCacheNoExpiry(t *testing.T) {
+	c := newKeyCache(2, 0)
+	for i := 0; i < 3; i++ {
+		c.add(fmt.Sprint(i), newTestKey(t, byte(i)))
+	}
+	if n := cachedKeys(c); n != 2 {
+		t.Fatalf("%d keys cached, want 2", n)
+	}
+	if _, ok := c.get("0"); ok {
+		t.Fatal("least recently used key was not evicted")
+	}
+	if c.timer != nil {
+		t.Fatal("expiry timer without a ttl")
+	}
+}
@@ -0,0 +1,657 @@
+package hdwallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/utils"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeyStoreScheme is the URL scheme of wallets backed by a keystore file.
+const KeyStoreScheme = "keystore"
+
+// KDF identifies the key derivation function used to derive the encryption key
+// of a keystore file from its passphrase.
+type KDF string
+
+const (
+	// KDFScrypt derives the encryption key using scrypt.
+	KDFScrypt KDF = "scrypt"
+	// KDFArgon2id derives the encryption key using argon2id.
+	KDFArgon2id KDF = "argon2id"
+)
+
+// DefaultKDF is the key derivation function used when none is provided.
+var DefaultKDF = KDFScrypt
+
+const (
+	keystoreVersion = 1
+	keystoreCipher  = "aes-256-gcm"
+	keystoreKeyLen  = 32
+	keystoreSaltLen = 32
+
+	scryptN = 1 << 18
+	scryptR = 8
+	scryptP = 1
+
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+)
+
+var (
+	// ErrWalletLocked is returned when secret material is required from a
+	// wallet that has not been opened.
+	ErrWalletLocked = errors.New("wallet is locked")
+	// ErrNoKeystore is returned for keystore operations on a wallet that is
+	// not backed by a keystore file.
+	ErrNoKeystore = errors.New("wallet is not backed by a keystore")
+	// ErrDecrypt is returned when the keystore cannot be decrypted with the
+	// given passphrase.
+	ErrDecrypt = errors.New("could not decrypt keystore with given passphrase")
+)
+
+// keystoreFile is the on-disk representation of an encrypted wallet. The
+// pinned accounts and coin accounts are stored in plaintext, so they can be
+// listed while the wallet is locked, but are authenticated by the cipher.
+type keystoreFile struct {
+	Version  int                   `json:"version"`
+	Accounts []keystoreAccount     `json:"accounts"`
+	Coins    []keystoreCoinAccount `json:"coins,omitempty"`
+	Crypto   keystoreCrypto        `json:"crypto"`
+}
+
+type keystoreAccount struct {
+	Address  common.Address `json:"address"`
+	Path     string         `json:"path,omitempty"`
+	Imported bool           `json:"imported,omitempty"`
+}
+
+type keystoreCoinAccount struct {
+	Coin    CoinType `json:"coin"`
+	Address string   `json:"address"`
+	Path    string   `json:"path"`
+}
+
+type keystoreCrypto struct {
+	KDF        KDF           `json:"kdf"`
+	KDFParams  kdfParams     `json:"kdfparams"`
+	Cipher     string        `json:"cipher"`
+	Nonce      hexutil.Bytes `json:"nonce"`
+	CipherText hexutil.Bytes `json:"ciphertext"`
+}
+
+type kdfParams struct {
+	Salt    hexutil.Bytes `json:"salt"`
+	KeyLen  int           `json:"keylen"`
+	N       int           `json:"n,omitempty"`
+	R       int           `json:"r,omitempty"`
+	P       int           `json:"p,omitempty"`
+	Time    uint32        `json:"time,omitempty"`
+	Memory  uint32        `json:"memory,omitempty"`
+	Threads uint8         `json:"threads,omitempty"`
+}
+
+// keystoreSecret is the plaintext that gets encrypted in the keystore file.
+type keystoreSecret struct {
+	Mnemonic string          `json:"mnemonic,omitempty"`
+	Seed     hexutil.Bytes   `json:"seed"`
+	Imported []hexutil.Bytes `json:"imported,omitempty"`
+}
+
+// zero overwrites the key material of the secret with zeros.
+func (s *keystoreSecret) zero() {
+	zeroBytes(s.Seed)
+	for _, keyBytes := range s.Imported {
+		zeroBytes(keyBytes)
+	}
+}
+
+// Load returns a locked wallet from the keystore file. The pinned accounts are
+// available immediately, the wallet needs to be opened with its passphrase
+// before it can sign.
+func Load(file string) (*Wallet, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := new(keystoreFile)
+	if err := json.Unmarshal(data, ks); err != nil {
+		return nil, err
+	}
+	if ks.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", ks.Version)
+	}
+
+	wallet := &Wallet{
+		url:      accounts.URL{Scheme: KeyStoreScheme, Path: file},
+		keystore: ks,
+		accounts: []accounts.Account{},
+		paths:    map[common.Address]accounts.DerivationPath{},
+		imported: map[common.Address]*ecdsa.PrivateKey{},
+		pubKeys:  map[string]*hdkeychain.ExtendedKey{},
+		keyCache: newKeyCache(DefaultKeyCacheSize, DefaultKeyCacheTTL),
+	}
+	for _, acct := range ks.Accounts {
+		if acct.Imported {
+			wallet.accounts = append(wallet.accounts, accounts.Account{
+				Address: acct.Address,
+				URL: accounts.URL{
+					Scheme: ImportedScheme,
+					Path:   acct.Address.Hex(),
+				},
+			})
+			wallet.imported[acct.Address] = nil
+			continue
+		}
+
+		path, err := accounts.ParseDerivationPath(acct.Path)
+		if err != nil {
+			return nil, err
+		}
+		wallet.accounts = append(wallet.accounts, accounts.Account{
+			Address: acct.Address,
+			URL:     accounts.URL{Path: path.String()},
+		})
+		wallet.paths[acct.Address] = path
+	}
+	for _, acct := range ks.Coins {
+		path, err := accounts.ParseDerivationPath(acct.Path)
+		if err != nil {
+			return nil, err
+		}
+		wallet.coinAccounts = append(wallet.coinAccounts, CoinAccount{
+			Coin:    acct.Coin,
+			Address: acct.Address,
+			URL:     accounts.URL{Path: path.String()},
+		})
+	}
+	return wallet, nil
+}
+
+// Save encrypts the wallet secrets with the passphrase and writes them,
+// together with the pinned accounts and coin accounts, to the keystore file.
+// From then on the wallet is backed by the file and can be locked and opened
+// again. Accounts pinned, unpinned or imported while the wallet is open are
+// written to the file as well.
+func (w *Wallet) Save(file string, passphrase string, kdfOpt ...KDF) error {
+	kdf := DefaultKDF
+	if len(kdfOpt) > 0 {
+		kdf = kdfOpt[0]
+	}
+
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	if w.seed == nil {
+		return ErrWalletLocked
+	}
+
+	params, err := newKDFParams(kdf)
+	if err != nil {
+		return err
+	}
+	key, err := deriveKeystoreKey(kdf, params, passphrase)
+	if err != nil {
+		return err
+	}
+
+	ks, err := w.encryptKeystore(kdf, params, key)
+	if err == nil {
+		err = writeKeystoreFile(file, ks)
+	}
+	if err != nil {
+		zeroBytes(key)
+		return err
+	}
+
+	w.keystore = ks
+	w.url = accounts.URL{Scheme: KeyStoreScheme, Path: file}
+	zeroBytes(w.storeKey)
+	w.storeKey = key
+	return nil
+}
+
+// ChangePassphrase re-encrypts the keystore file of the wallet with a new
+// passphrase.
+func (w *Wallet) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	if w.keystore == nil {
+		return ErrNoKeystore
+	}
+
+	secret, err := decryptKeystore(w.keystore, oldPassphrase)
+	if err != nil {
+		return err
+	}
+	defer secret.zero()
+
+	kdf := w.keystore.Crypto.KDF
+	params, err := newKDFParams(kdf)
+	if err != nil {
+		return err
+	}
+	key, err := deriveKeystoreKey(kdf, params, newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	ks, err := newKeystoreFile(
+		secret, w.keystore.Accounts, w.keystore.Coins, kdf, params, key)
+	if err == nil {
+		err = writeKeystoreFile(w.url.Path, ks)
+	}
+	if err != nil {
+		zeroBytes(key)
+		return err
+	}
+
+	w.keystore = ks
+	if w.storeKey != nil {
+		zeroBytes(w.storeKey)
+		w.storeKey = key
+	} else {
+		zeroBytes(key)
+	}
+	return nil
+}
+
+// updateKeystore re-encrypts the keystore file with the current accounts of
+// an open wallet, so that they survive closing it. Wallets that are not backed
+// by a keystore are left as they are. The caller must hold the state lock.
+func (w *Wallet) updateKeystore() error {
+	if w.keystore == nil {
+		return nil
+	}
+	if w.storeKey == nil {
+		return ErrWalletLocked
+	}
+
+	ks, err := w.encryptKeystore(
+		w.keystore.Crypto.KDF, w.keystore.Crypto.KDFParams, w.storeKey)
+	if err != nil {
+		return err
+	}
+	if err := writeKeystoreFile(w.url.Path, ks); err != nil {
+		return err
+	}
+
+	w.keystore = ks
+	return nil
+}
+
+// unlock decrypts the keystore and loads its secrets into the wallet. The key
+// of the keystore is kept until the wallet is locked to update the file.
+func (w *Wallet) unlock(passphrase string) error {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	key, err := deriveKeystoreKey(
+		w.keystore.Crypto.KDF, w.keystore.Crypto.KDFParams, passphrase)
+	if err != nil {
+		return err
+	}
+	secret, err := openKeystore(w.keystore, key)
+	if err != nil {
+		zeroBytes(key)
+		return err
+	}
+
+	masterKey, err := hdkeychain.NewMaster(secret.Seed, &chaincfg.MainNetParams)
+	if err != nil {
+		zeroBytes(key)
+		secret.zero()
+		return err
+	}
+
+	imported := make(map[common.Address]*ecdsa.PrivateKey, len(secret.Imported))
+	for _, keyBytes := range secret.Imported {
+		privateKey, err := crypto.ToECDSA(keyBytes)
+		zeroBytes(keyBytes)
+		if err != nil {
+			zeroBytes(key)
+			secret.zero()
+			return err
+		}
+		imported[crypto.PubkeyToAddress(privateKey.PublicKey)] = privateKey
+	}
+
+	w.lock()
+	w.storeKey = key
+	w.mnemonic = secret.Mnemonic
+	w.seed = secret.Seed
+	w.masterKey = masterKey
+	for address := range w.imported {
+		w.imported[address] = imported[address]
+	}
+	return nil
+}
+
+// lock wipes the secrets of the wallet from memory. The caller must hold the
+// state lock.
+func (w *Wallet) lock() {
+	w.keyCache.purge()
+	zeroBytes(w.storeKey)
+	zeroBytes(w.seed)
+	if w.masterKey != nil {
+		w.masterKey.Zero()
+	}
+	w.storeKey = nil
+	w.mnemonic = ""
+	w.seed = nil
+	w.masterKey = nil
+	for address, privateKey := range w.imported {
+		if privateKey != nil {
+			zeroKey(privateKey)
+		}
+		w.imported[address] = nil
+	}
+}
+
+// privateKeyWithPassphrase returns the private key of a pinned or imported
+// account from the keystore decrypted with the passphrase, regardless of
+// whether the wallet is open.
+func (w *Wallet) privateKeyWithPassphrase(
+	account accounts.Account,
+	passphrase string,
+) (*ecdsa.PrivateKey, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	path, pinned := w.paths[account.Address]
+	_, imported := w.imported[account.Address]
+	if !pinned && !imported {
+		return nil, accounts.ErrUnknownAccount
+	}
+
+	secret, err := decryptKeystore(w.keystore, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer secret.zero()
+
+	if imported {
+		for _, keyBytes := range secret.Imported {
+			privateKey, err := crypto.ToECDSA(keyBytes)
+			if err != nil {
+				return nil, err
+			}
+			if crypto.PubkeyToAddress(privateKey.PublicKey) == account.Address {
+				return privateKey, nil
+			}
+			zeroKey(privateKey)
+		}
+		return nil, accounts.ErrUnknownAccount
+	}
+
+	masterKey, err := hdkeychain.NewMaster(secret.Seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+	defer masterKey.Zero()
+
+	return derivePrivateKeyFrom(masterKey, path)
+}
+
+// encryptKeystore encrypts the secrets, pinned accounts and coin accounts of
+// the wallet with the key. The caller must hold the state lock.
+func (w *Wallet) encryptKeystore(
+	kdf KDF,
+	params kdfParams,
+	key []byte,
+) (*keystoreFile, error) {
+	accts := make([]keystoreAccount, 0, len(w.accounts))
+	secret := &keystoreSecret{
+		Mnemonic: w.mnemonic,
+		Seed:     w.seed,
+	}
+	for _, acct := range w.accounts {
+		if privateKey, ok := w.imported[acct.Address]; ok {
+			if privateKey == nil {
+				return nil, ErrWalletLocked
+			}
+			accts = append(accts, keystoreAccount{
+				Address:  acct.Address,
+				Imported: true,
+			})
+			secret.Imported = append(
+				secret.Imported, crypto.FromECDSA(privateKey))
+			continue
+		}
+
+		accts = append(accts, keystoreAccount{
+			Address: acct.Address,
+			Path:    w.paths[acct.Address].String(),
+		})
+	}
+	defer func() {
+		for _, keyBytes := range secret.Imported {
+			zeroBytes(keyBytes)
+		}
+	}()
+
+	coins := make([]keystoreCoinAccount, len(w.coinAccounts))
+	for i, acct := range w.coinAccounts {
+		coins[i] = keystoreCoinAccount{
+			Coin:    acct.Coin,
+			Address: acct.Address,
+			Path:    acct.URL.Path,
+		}
+	}
+
+	return newKeystoreFile(secret, accts, coins, kdf, params, key)
+}
+
+// newKeystoreFile encrypts the secret with the key derived by the kdf with the
+// parameters.
+func newKeystoreFile(
+	secret *keystoreSecret,
+	accts []keystoreAccount,
+	coins []keystoreCoinAccount,
+	kdf KDF,
+	params kdfParams,
+	key []byte,
+) (*keystoreFile, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(plaintext)
+
+	aad, err := keystoreAAD(accts, coins)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keystoreFile{
+		Version:  keystoreVersion,
+		Accounts: accts,
+		Coins:    coins,
+		Crypto: keystoreCrypto{
+			KDF:        kdf,
+			KDFParams:  params,
+			Cipher:     keystoreCipher,
+			Nonce:      nonce,
+			CipherText: gcm.Seal(nil, nonce, plaintext, aad),
+		},
+	}, nil
+}
+
+// decryptKeystore decrypts the secret of the keystore with the passphrase.
+func decryptKeystore(
+	ks *keystoreFile,
+	passphrase string,
+) (*keystoreSecret, error) {
+	key, err := deriveKeystoreKey(ks.Crypto.KDF, ks.Crypto.KDFParams, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(key)
+
+	return openKeystore(ks, key)
+}
+
+// openKeystore decrypts the secret of the keystore with the key.
+func openKeystore(ks *keystoreFile, key []byte) (*keystoreSecret, error) {
+	if ks.Crypto.Cipher != keystoreCipher {
+		return nil, fmt.Errorf("unsupported cipher %q", ks.Crypto.Cipher)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ks.Crypto.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid keystore nonce")
+	}
+
+	aad, err := keystoreAAD(ks.Accounts, ks.Coins)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, ks.Crypto.Nonce, ks.Crypto.CipherText, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	defer zeroBytes(plaintext)
+
+	secret := new(keystoreSecret)
+	if err := json.Unmarshal(plaintext, secret); err != nil {
+		return nil, err
+	}
+	if len(secret.Seed) == 0 {
+		return nil, errors.New("keystore does not contain a seed")
+	}
+	return secret, nil
+}
+
+// keystoreAAD returns the additional data authenticating the plaintext
+// accounts and coin accounts of a keystore.
+func keystoreAAD(
+	accts []keystoreAccount,
+	coins []keystoreCoinAccount,
+) ([]byte, error) {
+	return json.Marshal(struct {
+		Accounts []keystoreAccount     `json:"accounts"`
+		Coins    []keystoreCoinAccount `json:"coins,omitempty"`
+	}{accts, coins})
+}
+
+// newKDFParams returns the default parameters with a random salt for the key
+// derivation function.
+func newKDFParams(kdf KDF) (kdfParams, error) {
+	salt := make([]byte, keystoreSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return kdfParams{}, err
+	}
+
+	switch kdf {
+	case KDFScrypt:
+		return kdfParams{
+			Salt:   salt,
+			KeyLen: keystoreKeyLen,
+			N:      scryptN,
+			R:      scryptR,
+			P:      scryptP,
+		}, nil
+	case KDFArgon2id:
+		return kdfParams{
+			Salt:    salt,
+			KeyLen:  keystoreKeyLen,
+			Time:    argon2Time,
+			Memory:  argon2Memory,
+			Threads: argon2Threads,
+		}, nil
+	default:
+		return kdfParams{}, fmt.Errorf("unsupported kdf %q", kdf)
+	}
+}
+
+// deriveKeystoreKey derives the encryption key from the passphrase.
+func deriveKeystoreKey(
+	kdf KDF,
+	params kdfParams,
+	passphrase string,
+) ([]byte, error) {
+	if params.KeyLen != keystoreKeyLen {
+		return nil, fmt.Errorf("unsupported key length %d", params.KeyLen)
+	}
+
+	switch kdf {
+	case KDFScrypt:
+		return scrypt.Key(
+			[]byte(passphrase), params.Salt,
+			params.N, params.R, params.P, params.KeyLen,
+		)
+	case KDFArgon2id:
+		if params.Time == 0 || params.Memory == 0 || params.Threads == 0 {
+			return nil, errors.New("invalid argon2id parameters")
+		}
+		return argon2.IDKey(
+			[]byte(passphrase), params.Salt,
+			params.Time, params.Memory, params.Threads, uint32(params.KeyLen),
+		), nil
+	default:
+		return nil, fmt.Errorf("unsupported kdf %q", kdf)
+	}
+}
+
+// newGCM returns an AES-GCM cipher for the key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeKeystoreFile atomically writes the keystore to file, only readable by
+// the current user.
+func writeKeystoreFile(file string, ks *keystoreFile) error {
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteFileAtomic(file, data)
+}
+
+// zeroBytes overwrites the byte slice with zeros.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// zeroKey overwrites the private scalar of the key with zeros.
+func zeroKey(privateKey *ecdsa.PrivateKey) {
+	privateKey.D.SetUint64(0)
+}
@@ -0,0 +1,214 @@
+package hdwallet
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+func TestKeystoreCoinAccounts(t *testing.T) {
+	wallet, err := NewFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	btc, err := wallet.DeriveCoin(
+		CoinTypeBTC, DefaultCoinPath(CoinTypeBTC, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etc, err := wallet.DeriveCoin(
+		CoinTypeETC, DefaultCoinPath(CoinTypeETC, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "wallet.json")
+	if err := wallet.Save(file, "passphrase", KDFArgon2id); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.CoinAccounts(); !reflect.DeepEqual(
+		got, []CoinAccount{btc, etc}) {
+		t.Fatalf("loaded coin accounts %v, want %v", got, []CoinAccount{btc, etc})
+	}
+	if got := loaded.CoinAccounts(CoinTypeBTC); !reflect.DeepEqual(
+		got, []CoinAccount{btc}) {
+		t.Fatalf("loaded bitcoin accounts %v, want %v", got, []CoinAccount{btc})
+	}
+	if !reflect.DeepEqual(loaded.Accounts(), wallet.Accounts()) {
+		t.Fatalf("loaded accounts %v, want %v",
+			loaded.Accounts(), wallet.Accounts())
+	}
+	if err := loaded.Open("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the coin accounts are authenticated by the cipher
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := new(keystoreFile)
+	if err := json.Unmarshal(data, ks); err != nil {
+		t.Fatal(err)
+	}
+	ks.Coins[0].Address = etc.Address
+	if err := writeKeystoreFile(file, ks); err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tampered.Open("passphrase"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("Open returned %v, want %v", err, ErrDecrypt)
+	}
+}
+
+func TestKeystoreAccountsAfterSave(t *testing.T) {
+	wallet, err := NewFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "wallet.json")
+	if err := wallet.Save(file, "passphrase", KDFArgon2id); err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyjson, err := keystore.EncryptKey(&keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+		PrivateKey: privateKey,
+	}, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := wallet.ImportKeystoreV3(keyjson, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned, err := wallet.Derive(DefaultCoinPath(CoinTypeETH, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpinned, err := wallet.Derive(DefaultCoinPath(CoinTypeETH, 0, 1), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wallet.Unpin(unpinned); err != nil {
+		t.Fatal(err)
+	}
+	btc, err := wallet.DeriveCoin(
+		CoinTypeBTC, DefaultCoinPath(CoinTypeBTC, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := wallet.Accounts()
+
+	if err := wallet.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallet.Open("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	key, err := wallet.PrivateKey(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.D.Cmp(privateKey.D) != 0 {
+		t.Fatal("imported key lost after closing the wallet")
+	}
+
+	if err := wallet.ChangePassphrase("passphrase", "new"); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Accounts(), want) {
+		t.Fatalf("loaded accounts %v, want %v", loaded.Accounts(), want)
+	}
+	if got := loaded.CoinAccounts(); !reflect.DeepEqual(
+		got, []CoinAccount{btc}) {
+		t.Fatalf("loaded coin accounts %v, want %v", got, []CoinAccount{btc})
+	}
+	if err := loaded.Open("new"); err != nil {
+		t.Fatal(err)
+	}
+	for _, account := range []accounts.Account{imported, pinned} {
+		if _, err := loaded.PrivateKey(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a closed wallet cannot write the accounts to its keystore
+	if err := loaded.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loaded.ImportKeystoreV3(keyjson, "secret"); !errors.Is(
+		err, ErrWalletLocked) {
+		t.Fatalf("ImportKeystoreV3 returned %v, want %v", err, ErrWalletLocked)
+	}
+}
+
+func TestKeystoreAccountsAuthenticated(t *testing.T) {
+	wallet, err := NewFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wallet.Derive(DefaultCoinPath(CoinTypeETH, 0, 0), true); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "wallet.json")
+	if err := wallet.Save(file, "passphrase", KDFArgon2id); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := new(keystoreFile)
+	if err := json.Unmarshal(data, ks); err != nil {
+		t.Fatal(err)
+	}
+	if ks.Coins != nil {
+		t.Fatalf("keystore without coin accounts lists %v", ks.Coins)
+	}
+	loaded, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Open("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	ks.Accounts[0].Path = "m/44'/60'/0'/0/1"
+	if err := writeKeystoreFile(file, ks); err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tampered.Open("passphrase"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("Open returned %v, want %v", err, ErrDecrypt)
+	}
+}
@@ -0,0 +1,108 @@
+package hdwallet
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/solsticewallet/solstice-core/bip39"
+)
+
+// ErrNoMatchingMnemonic is returned when no correction of a mnemonic derives
+// the known address.
+var ErrNoMatchingMnemonic = errors.New("no mnemonic derives the address")
+
+// RecoverMnemonic returns the mnemonic deriving the known address of the coin
+// at the path: the mnemonic itself, as its wordlist spells it, if it does, or
+// else the one of its corrections with a valid checksum that does, see
+// bip39.ChecksumCandidates.
+// Every candidate takes a PBKDF2 seed derivation, so recovering a 12 words
+// mnemonic with a wrong word can take a few seconds.
+func RecoverMnemonic(
+	ctx context.Context,
+	mnemonic string,
+	coinType CoinType,
+	path accounts.DerivationPath,
+	address string,
+	passOpt ...string,
+) (string, error) {
+	coin, err := CoinByType(coinType)
+	if err != nil {
+		return "", err
+	}
+
+	var password string
+	if len(passOpt) > 0 {
+		password = passOpt[0]
+	}
+
+	matches := func(candidate string) (bool, error) {
+		derived, err := deriveCoinAddress(
+			bip39.NewSeed(candidate, password), coin, path)
+		if err != nil {
+			return false, err
+		}
+		if coin.EVM {
+			return strings.EqualFold(derived, address), nil
+		}
+		return derived == address, nil
+	}
+
+	if canonical, err := bip39.CanonicalMnemonic(mnemonic); err == nil {
+		ok, err := matches(canonical)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return canonical, nil
+		}
+	}
+
+	candidates, err := bip39.ChecksumCandidates(mnemonic)
+	if err != nil {
+		return "", err
+	}
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		ok, err := matches(candidate.Mnemonic)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return candidate.Mnemonic, nil
+		}
+	}
+	return "", ErrNoMatchingMnemonic
+}
+
+// deriveCoinAddress derives the address of the coin at the path from the
+// seed.
+func deriveCoinAddress(
+	seed []byte,
+	coin *Coin,
+	path accounts.DerivationPath,
+) (string, error) {
+	masterKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return "", err
+	}
+	defer masterKey.Zero()
+
+	key, err := deriveExtendedKeyFrom(masterKey, path)
+	if err != nil {
+		return "", err
+	}
+	defer key.Zero()
+
+	publicKey, err := key.ECPubKey()
+	if err != nil {
+		return "", err
+	}
+	return coin.Encoder(publicKey, path)
+}
@@ -0,0 +1,105 @@
+package hdwallet
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+// ImportedScheme is the URL scheme of accounts that were imported from a Web3
+// Secret Storage file instead of being derived from the seed.
+const ImportedScheme = "imported"
+
+// ErrImportedAccount is returned when an HD operation, like resolving the
+// derivation path, is requested for an imported account.
+var ErrImportedAccount = errors.New("account is not derived from the seed")
+
+// ExportKeystoreV3 exports the private key of the account as a Web3 Secret
+// Storage (keystore v3) JSON document encrypted with the passphrase. The
+// account can be any account derived by Derive or an imported account.
+func (w *Wallet) ExportKeystoreV3(
+	account accounts.Account,
+	passphrase string,
+) ([]byte, error) {
+	privateKey, err := w.PrivateKey(account)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &keystore.Key{
+		Id:         id,
+		Address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+		PrivateKey: privateKey,
+	}
+	return keystore.EncryptKey(
+		key, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+}
+
+// ImportKeystoreV3 decrypts the Web3 Secret Storage (keystore v3) JSON document
+// with the passphrase and pins its key as a standalone account next to the HD
+// accounts of the wallet. A wallet backed by a keystore file has to be open to
+// encrypt the key into the file.
+func (w *Wallet) ImportKeystoreV3(
+	keyjson []byte,
+	passphrase string,
+) (accounts.Account, error) {
+	key, err := keystore.DecryptKey(keyjson, passphrase)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	return w.importKey(key.PrivateKey)
+}
+
+// importKey pins the private key as an imported account.
+func (w *Wallet) importKey(
+	privateKey *ecdsa.PrivateKey,
+) (accounts.Account, error) {
+	address := crypto.PubkeyToAddress(privateKey.PublicKey)
+	account := accounts.Account{
+		Address: address,
+		URL: accounts.URL{
+			Scheme: ImportedScheme,
+			Path:   address.Hex(),
+		},
+	}
+
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	if _, ok := w.paths[address]; ok {
+		return accounts.Account{}, errors.New("account is already pinned")
+	}
+	prev, ok := w.imported[address]
+	if !ok {
+		w.accounts = append(w.accounts, account)
+	}
+	w.imported[address] = privateKey
+	if err := w.updateKeystore(); err != nil {
+		if ok {
+			w.imported[address] = prev
+		} else {
+			w.accounts = w.accounts[:len(w.accounts)-1]
+			delete(w.imported, address)
+		}
+		return accounts.Account{}, err
+	}
+	return account, nil
+}
+
+// isImported returns whether the account was imported rather than derived.
+func (w *Wallet) isImported(account accounts.Account) bool {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	_, ok := w.imported[account.Address]
+	return ok
+}
@@ -0,0 +1,156 @@
+package hdwallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// ErrUnsupportedMimetype is returned by SignData for content types it does not
+// know how to hash.
+var ErrUnsupportedMimetype = errors.New("unsupported mimetype")
+
+// SignTypedData signs the EIP-712 domain separated hash of the typed data. The
+// signature uses the canonical Ethereum V of 27 or 28.
+func (w *Wallet) SignTypedData(
+	account accounts.Account,
+	typedData apitypes.TypedData,
+) ([]byte, error) {
+	if !w.Contains(account) {
+		return nil, accounts.ErrUnknownAccount
+	}
+
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	return ethereumV(w.SignHash(account, hash))
+}
+
+// SignTypedDataWithPassphrase signs the EIP-712 domain separated hash of the
+// typed data using the passphrase as extra authentication. The signature uses
+// the canonical Ethereum V of 27 or 28.
+func (w *Wallet) SignTypedDataWithPassphrase(
+	account accounts.Account,
+	passphrase string,
+	typedData apitypes.TypedData,
+) ([]byte, error) {
+	if !w.Contains(account) {
+		return nil, accounts.ErrUnknownAccount
+	}
+
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	return ethereumV(w.SignHashWithPassphrase(account, passphrase, hash))
+}
+
+// signDataHash returns the hash to sign for data of the mimetype, the same way
+// clef does, and whether the signature uses the Ethereum V of 27 or 28:
+//
+//   - data/validator: a 20 byte validator address followed by the message,
+//     hashed following EIP-191 version 0.
+//   - application/x-clique-header: an RLP encoded header without seal, hashed
+//     as clique seal hash. The signature keeps V as 0 or 1.
+//   - data/typed: JSON encoded EIP-712 typed data.
+//   - text/plain: text hashed following EIP-191 version 0x45 (personal_sign).
+//
+// Other mimetypes are rejected with ErrUnsupportedMimetype, as clef does.
+func signDataHash(mimetype string, data []byte) ([]byte, bool, error) {
+	mediaType, _, err := mime.ParseMediaType(mimetype)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch mediaType {
+	case accounts.MimetypeDataWithValidator:
+		if len(data) < common.AddressLength {
+			return nil, false, errors.New("validator address is missing")
+		}
+		msg := append([]byte{0x19, 0x00}, data...)
+		return crypto.Keccak256(msg), true, nil
+
+	case accounts.MimetypeClique:
+		header := new(types.Header)
+		if err := rlp.DecodeBytes(data, header); err != nil {
+			return nil, false, err
+		}
+		hash, err := cliqueSealHash(header)
+		if err != nil {
+			return nil, false, err
+		}
+		return hash, false, nil
+
+	case accounts.MimetypeTypedData:
+		var typedData apitypes.TypedData
+		if err := json.Unmarshal(data, &typedData); err != nil {
+			return nil, false, err
+		}
+		hash, _, err := apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			return nil, false, err
+		}
+		return hash, true, nil
+
+	case accounts.MimetypeTextPlain:
+		return accounts.TextHash(data), true, nil
+
+	default:
+		return nil, false, fmt.Errorf("%w: %s", ErrUnsupportedMimetype, mimetype)
+	}
+}
+
+// cliqueSealHash returns the hash a clique signer seals for the header, whose
+// extra data does not contain the seal yet. It matches clique.SealHash without
+// pulling the consensus engine and its database dependencies into the wallet.
+func cliqueSealHash(header *types.Header) ([]byte, error) {
+	if header.WithdrawalsHash != nil || header.ExcessBlobGas != nil ||
+		header.BlobGasUsed != nil || header.ParentBeaconRoot != nil {
+		return nil, errors.New("unexpected post-merge fields in clique header")
+	}
+
+	enc := []interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra,
+		header.MixDigest,
+		header.Nonce,
+	}
+	if header.BaseFee != nil {
+		enc = append(enc, header.BaseFee)
+	}
+
+	data, err := rlp.EncodeToBytes(enc)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(data), nil
+}
+
+// ethereumV converts the V of a signature from 0 or 1 to 27 or 28.
+func ethereumV(sig []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
@@ -0,0 +1,37 @@
+package hdwallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/utils"
+)
+
+func TestSignDataMimetypes(t *testing.T) {
+	wallet, err := NewFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := wallet.Derive(DefaultCoinPath(CoinTypeETH, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("hello")
+	signature, err := wallet.SignData(account, accounts.MimetypeTextPlain, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.VerifyText(data, signature, account.Address); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mimetype := range []string{"application/octet-stream", "text/html"} {
+		_, err := wallet.SignData(account, mimetype, data)
+		if !errors.Is(err, ErrUnsupportedMimetype) {
+			t.Errorf("SignData(%s) returned %v, want %v",
+				mimetype, err, ErrUnsupportedMimetype)
+		}
+	}
+}
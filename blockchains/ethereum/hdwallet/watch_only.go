@@ -0,0 +1,464 @@
+package hdwallet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// ErrWatchOnly is returned by every signing operation of a watch-only wallet.
+var ErrWatchOnly = errors.New("watch-only wallet cannot sign")
+
+// WatchOnlyWallet is a wallet that only holds the extended public key of an
+// account, e.g. m/44'/60'/0'. It derives the addresses and public keys of the
+// non-hardened paths below that account, but holds no private material and
+// cannot sign.
+type WatchOnlyWallet struct {
+	accountKey  *hdkeychain.ExtendedKey
+	accountPath accounts.DerivationPath
+	url         accounts.URL
+	paths       map[common.Address]accounts.DerivationPath
+	accounts    []accounts.Account
+	stateLock   sync.RWMutex
+}
+
+// NewWatchOnlyFromXPub returns a new watch-only wallet from the BIP-32 extended
+// public key of the account at the account path.
+func NewWatchOnlyFromXPub(
+	xpub string,
+	accountPath accounts.DerivationPath,
+) (*WatchOnlyWallet, error) {
+	accountKey, err := hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		return nil, err
+	}
+	if accountKey.IsPrivate() {
+		return nil, errors.New("extended key is private, expected xpub")
+	}
+	if int(accountKey.Depth()) != len(accountPath) {
+		return nil, fmt.Errorf(
+			"extended key depth %d does not match account path %s",
+			accountKey.Depth(), accountPath,
+		)
+	}
+
+	return &WatchOnlyWallet{
+		accountKey:  accountKey,
+		accountPath: accountPath,
+		accounts:    []accounts.Account{},
+		paths:       map[common.Address]accounts.DerivationPath{},
+	}, nil
+}
+
+// URL implements accounts.Wallet, returning the URL of the device that the
+// wallet is on, however this does nothing since this is not a hardware device.
+func (w *WatchOnlyWallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, returning that the wallet is watch-only.
+func (w *WatchOnlyWallet) Status() (string, error) {
+	return "watch-only", nil
+}
+
+// Open implements accounts.Wallet, however this does nothing since there are
+// no secrets to unlock.
+func (w *WatchOnlyWallet) Open(passphrase string) error {
+	return nil
+}
+
+// Close implements accounts.Wallet, however this does nothing since there are
+// no secrets to wipe.
+func (w *WatchOnlyWallet) Close() error {
+	return nil
+}
+
+// Lock does nothing since the wallet holds no secrets.
+func (w *WatchOnlyWallet) Lock() {}
+
+// Accounts implements accounts.Wallet, returning the list of accounts pinned to
+// the wallet.
+func (w *WatchOnlyWallet) Accounts() []accounts.Account {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account
+// is or is not pinned into this wallet instance.
+func (w *WatchOnlyWallet) Contains(account accounts.Account) bool {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	_, exists := w.paths[account.Address]
+	return exists
+}
+
+// Unpin unpins account from list of pinned accounts.
+func (w *WatchOnlyWallet) Unpin(account accounts.Account) error {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	for i, acct := range w.accounts {
+		if acct.Address == account.Address {
+			w.accounts = removeAtIndex(w.accounts, i)
+			delete(w.paths, account.Address)
+			return nil
+		}
+	}
+
+	return errors.New("account not found")
+}
+
+// Derive implements accounts.Wallet, deriving a new account at the specific
+// derivation path. The path must be a non-hardened descendant of the account
+// path of the wallet. If pin is set to true, the account will be added to the
+// list of tracked accounts.
+func (w *WatchOnlyWallet) Derive(
+	path accounts.DerivationPath,
+	pin bool,
+) (accounts.Account, error) {
+	address, err := w.deriveAddress(path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+
+	account := accounts.Account{
+		Address: address,
+		URL: accounts.URL{
+			Scheme: "",
+			Path:   path.String(),
+		},
+	}
+
+	if !pin {
+		return account, nil
+	}
+
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	if _, ok := w.paths[address]; !ok {
+		w.accounts = append(w.accounts, account)
+		w.paths[address] = path
+	}
+	return account, nil
+}
+
+// SelfDerive implements accounts.Wallet, trying to discover accounts that the
+// user used previously (based on the chain state), but did not explicitly pin
+// to the wallet manually.
+func (w *WatchOnlyWallet) SelfDerive(
+	base []accounts.DerivationPath,
+	chain ethereum.ChainStateReader,
+) {
+	ctx := context.Background()
+	for _, basePath := range base {
+		iter := accounts.DefaultIterator(basePath)
+		numEmpty := 0
+		for numEmpty < 10 {
+			// The iterator reuses its path, copy it as it may get pinned
+			derivPath := append(accounts.DerivationPath{}, iter()...)
+
+			addr, err := w.deriveAddress(derivPath)
+			if err != nil {
+				return
+			}
+
+			used, err := isAddressUsed(ctx, addr, chain)
+			if err != nil {
+				return
+			}
+
+			numEmpty++
+			if used {
+				numEmpty = 0
+				if _, err = w.Derive(derivPath, true); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// SignData implements accounts.Wallet, but always fails since the wallet is
+// watch-only.
+func (w *WatchOnlyWallet) SignData(
+	account accounts.Account,
+	mimetype string,
+	data []byte,
+) ([]byte, error) {
+	return nil, ErrWatchOnly
+}
+
+// SignDataWithPassphrase implements accounts.Wallet, but always fails since the
+// wallet is watch-only.
+func (w *WatchOnlyWallet) SignDataWithPassphrase(
+	account accounts.Account,
+	passphrase string,
+	mimetype string,
+	data []byte,
+) ([]byte, error) {
+	return nil, ErrWatchOnly
+}
+
+// SignText implements accounts.Wallet, but always fails since the wallet is
+// watch-only.
+func (w *WatchOnlyWallet) SignText(
+	account accounts.Account,
+	text []byte,
+) ([]byte, error) {
+	return nil, ErrWatchOnly
+}
+
+// SignTextWithPassphrase implements accounts.Wallet, but always fails since the
+// wallet is watch-only.
+func (w *WatchOnlyWallet) SignTextWithPassphrase(
+	account accounts.Account,
+	passphrase string,
+	text []byte,
+) ([]byte, error) {
+	return nil, ErrWatchOnly
+}
+
+// SignTypedData always fails since the wallet is watch-only.
+func (w *WatchOnlyWallet) SignTypedData(
+	account accounts.Account,
+	typedData apitypes.TypedData,
+) ([]byte, error) {
+	return nil, ErrWatchOnly
+}
+
+// SignTypedDataWithPassphrase always fails since the wallet is watch-only.
+func (w *WatchOnlyWallet) SignTypedDataWithPassphrase(
+	account accounts.Account,
+	passphrase string,
+	typedData apitypes.TypedData,
+) ([]byte, error) {
+	return nil, ErrWatchOnly
+}
+
+// SignTx implements accounts.Wallet, but always fails since the wallet is
+// watch-only.
+func (w *WatchOnlyWallet) SignTx(
+	account accounts.Account,
+	tx *types.Transaction,
+	chainID *big.Int,
+) (*types.Transaction, error) {
+	return nil, ErrWatchOnly
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, but always fails since the
+// wallet is watch-only.
+func (w *WatchOnlyWallet) SignTxWithPassphrase(
+	account accounts.Account,
+	passphrase string,
+	tx *types.Transaction,
+	chainID *big.Int,
+) (*types.Transaction, error) {
+	return nil, ErrWatchOnly
+}
+
+// SignHash always fails since the wallet is watch-only.
+func (w *WatchOnlyWallet) SignHash(
+	account accounts.Account,
+	hash []byte,
+) ([]byte, error) {
+	return nil, ErrWatchOnly
+}
+
+// SignHashWithPassphrase always fails since the wallet is watch-only.
+func (w *WatchOnlyWallet) SignHashWithPassphrase(
+	account accounts.Account,
+	passphrase string,
+	hash []byte,
+) ([]byte, error) {
+	return nil, ErrWatchOnly
+}
+
+// PrivateKey always fails since the wallet is watch-only.
+func (w *WatchOnlyWallet) PrivateKey(
+	account accounts.Account,
+) (*ecdsa.PrivateKey, error) {
+	return nil, ErrWatchOnly
+}
+
+// PrivateKeyBytes always fails since the wallet is watch-only.
+func (w *WatchOnlyWallet) PrivateKeyBytes(
+	account accounts.Account,
+) ([]byte, error) {
+	return nil, ErrWatchOnly
+}
+
+// PrivateKeyHex always fails since the wallet is watch-only.
+func (w *WatchOnlyWallet) PrivateKeyHex(
+	account accounts.Account,
+) (string, error) {
+	return "", ErrWatchOnly
+}
+
+// PublicKey returns the ECDSA public key of the account.
+func (w *WatchOnlyWallet) PublicKey(
+	account accounts.Account,
+) (*ecdsa.PublicKey, error) {
+	path, err := accounts.ParseDerivationPath(account.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	return w.derivePublicKey(path)
+}
+
+// PublicKeyBytes returns the ECDSA public key in bytes format of the account.
+func (w *WatchOnlyWallet) PublicKeyBytes(
+	account accounts.Account,
+) ([]byte, error) {
+	publicKey, err := w.PublicKey(account)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.FromECDSAPub(publicKey), nil
+}
+
+// PublicKeyHex returns the ECDSA public key in hex string format of the
+// account.
+func (w *WatchOnlyWallet) PublicKeyHex(
+	account accounts.Account,
+) (string, error) {
+	publicKeyBytes, err := w.PublicKeyBytes(account)
+	if err != nil {
+		return "", err
+	}
+	return hexutil.Encode(publicKeyBytes)[4:], nil
+}
+
+// Address returns the address of the account.
+func (w *WatchOnlyWallet) Address(
+	account accounts.Account,
+) (common.Address, error) {
+	publicKey, err := w.PublicKey(account)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// AddressBytes returns the address in bytes format of the account.
+func (w *WatchOnlyWallet) AddressBytes(account accounts.Account) ([]byte, error) {
+	address, err := w.Address(account)
+	if err != nil {
+		return nil, err
+	}
+	return address.Bytes(), nil
+}
+
+// AddressHex returns the address in hex string format of the account.
+func (w *WatchOnlyWallet) AddressHex(account accounts.Account) (string, error) {
+	address, err := w.Address(account)
+	if err != nil {
+		return "", err
+	}
+	return address.Hex(), nil
+}
+
+// Path returns the derivation path of the account.
+func (w *WatchOnlyWallet) Path(account accounts.Account) (string, error) {
+	return account.URL.Path, nil
+}
+
+// ExtendedPublicKey returns the BIP-32 extended public key at the derivation
+// path, which must be the account path of the wallet or a non-hardened
+// descendant of it.
+func (w *WatchOnlyWallet) ExtendedPublicKey(
+	path accounts.DerivationPath,
+) (string, error) {
+	key, err := w.deriveExtendedKey(path)
+	if err != nil {
+		return "", err
+	}
+	return key.String(), nil
+}
+
+// deriveExtendedKey derives the extended public key of the derivation path
+// from the account key.
+func (w *WatchOnlyWallet) deriveExtendedKey(
+	path accounts.DerivationPath,
+) (*hdkeychain.ExtendedKey, error) {
+	if len(path) < len(w.accountPath) {
+		return nil, fmt.Errorf(
+			"path %s is not below account %s", path, w.accountPath)
+	}
+	for i, n := range w.accountPath {
+		if path[i] != n {
+			return nil, fmt.Errorf(
+				"path %s is not below account %s", path, w.accountPath)
+		}
+	}
+	return derivePublicChild(w.accountKey, path[len(w.accountPath):])
+}
+
+// derivePublicKey derives the public key of the derivation path.
+func (w *WatchOnlyWallet) derivePublicKey(
+	path accounts.DerivationPath,
+) (*ecdsa.PublicKey, error) {
+	key, err := w.deriveExtendedKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return extendedPublicKey(key)
+}
+
+// deriveAddress derives the account address of the derivation path.
+func (w *WatchOnlyWallet) deriveAddress(
+	path accounts.DerivationPath,
+) (common.Address, error) {
+	publicKey, err := w.derivePublicKey(path)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// derivePublicChild derives the non-hardened descendant of the extended key
+// at the relative path.
+func derivePublicChild(
+	key *hdkeychain.ExtendedKey,
+	relPath accounts.DerivationPath,
+) (*hdkeychain.ExtendedKey, error) {
+	var err error
+	for _, n := range relPath {
+		if n >= hdkeychain.HardenedKeyStart {
+			return nil, errors.New(
+				"hardened derivation requires the private key")
+		}
+		if key, err = key.Derive(n); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// extendedPublicKey returns the ECDSA public key of the extended key.
+func extendedPublicKey(
+	key *hdkeychain.ExtendedKey,
+) (*ecdsa.PublicKey, error) {
+	publicKey, err := key.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	return publicKey.ToECDSA(), nil
+}
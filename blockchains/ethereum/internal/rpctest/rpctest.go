@@ -0,0 +1,91 @@
+// Package rpctest implements local JSON-RPC stand-ins of Ethereum nodes for
+// tests.
+package rpctest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// Reply is the reply of a stand-in node to a JSON-RPC request: an HTTP status
+// other than 200, a JSON-RPC error or a result.
+type Reply struct {
+	Status  int
+	Code    int
+	Message string
+	Result  interface{}
+}
+
+// Node is a local JSON-RPC stand-in answering every request with its handler.
+type Node struct {
+	URL string
+
+	requests atomic.Int32
+}
+
+// NewNode starts a node answering with the handler, closed when the test
+// ends.
+func NewNode(t testing.TB, handler func(method string) Reply) *Node {
+	n := &Node{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			n.requests.Add(1)
+
+			var req struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			reply := handler(req.Method)
+			if reply.Status != 0 {
+				http.Error(w, http.StatusText(reply.Status), reply.Status)
+				return
+			}
+
+			resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+			if reply.Code != 0 {
+				resp["error"] = map[string]interface{}{
+					"code":    reply.Code,
+					"message": reply.Message,
+				}
+			} else {
+				resp["result"] = reply.Result
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+		}))
+	t.Cleanup(server.Close)
+	n.URL = server.URL
+	return n
+}
+
+// Requests returns the number of requests the node received.
+func (n *Node) Requests() int {
+	return int(n.requests.Load())
+}
+
+// Result replies with the result to every request.
+func Result(value interface{}) func(string) Reply {
+	return func(string) Reply {
+		return Reply{Result: value}
+	}
+}
+
+// Results replies with the result of the method, or a method not found error
+// to the methods without one.
+func Results(results map[string]interface{}) func(string) Reply {
+	return func(method string) Reply {
+		result, ok := results[method]
+		if !ok {
+			return Reply{Code: -32601, Message: "method not found: " + method}
+		}
+		return Reply{Result: result}
+	}
+}
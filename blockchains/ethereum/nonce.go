@@ -0,0 +1,277 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/utils"
+)
+
+// NonceManager hands out transaction nonces per account and chain. It keeps
+// track of the nonces it issued, so concurrent sends from the same account
+// never get the same nonce, and reconciles them with the pending nonce of the
+// node. Nonces of transactions that were never sent or got dropped can be
+// released, after which they are issued again to fill the gap.
+type NonceManager struct {
+	lock     sync.Mutex
+	file     string
+	accounts map[nonceKey]*nonceState
+	entries  map[string]nonceFileEntry
+}
+
+type nonceKey struct {
+	chainID uint64
+	address common.Address
+}
+
+type nonceState struct {
+	lock     sync.Mutex
+	next     uint64
+	released []uint64
+}
+
+// nonceFileEntry is the persisted state of a single account.
+type nonceFileEntry struct {
+	Next     uint64   `json:"next"`
+	Released []uint64 `json:"released,omitempty"`
+}
+
+// NewNonceManager creates a new nonce manager. If a file is provided, the
+// state of the manager is loaded from and persisted to that file, so issued
+// nonces survive restarts.
+func NewNonceManager(fileOpt ...string) (*NonceManager, error) {
+	m := newNonceManager()
+	if len(fileOpt) == 0 || fileOpt[0] == "" {
+		return m, nil
+	}
+
+	m.file = fileOpt[0]
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// newNonceManager creates a nonce manager keeping its state in memory only.
+func newNonceManager() *NonceManager {
+	return &NonceManager{
+		accounts: map[nonceKey]*nonceState{},
+		entries:  map[string]nonceFileEntry{},
+	}
+}
+
+// Next returns the next nonce to use for the account on the chain. Released
+// nonces are issued first, otherwise the highest of the locally tracked nonce
+// and the pending nonce of the node is issued.
+func (m *NonceManager) Next(
+	ctx context.Context,
+	client ethereum.PendingStateReader,
+	chainID *big.Int,
+	address common.Address,
+) (uint64, error) {
+	key, state := m.state(chainID, address)
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	pending, err := client.PendingNonceAt(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+
+	// The state is restored if it cannot be persisted, the nonce is not
+	// issued then and must not leave a gap.
+	next, released := state.next, append([]uint64{}, state.released...)
+
+	// Nonces below the pending nonce were used by transactions the node
+	// already knows about, possibly sent from elsewhere.
+	if state.next < pending {
+		state.next = pending
+	}
+	for len(state.released) > 0 && state.released[0] < pending {
+		state.released = state.released[1:]
+	}
+
+	var nonce uint64
+	if len(state.released) > 0 {
+		nonce = state.released[0]
+		state.released = state.released[1:]
+	} else {
+		nonce = state.next
+		state.next++
+	}
+
+	if err := m.persist(key, state); err != nil {
+		state.next, state.released = next, released
+		return 0, err
+	}
+	return nonce, nil
+}
+
+// Release returns a nonce issued by Next, because its transaction was never
+// sent or got dropped from the mempool. The nonce is issued again by the next
+// call to Next.
+func (m *NonceManager) Release(
+	chainID *big.Int,
+	address common.Address,
+	nonce uint64,
+) error {
+	key, state := m.state(chainID, address)
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	if nonce >= state.next {
+		return fmt.Errorf("nonce %d was not issued", nonce)
+	}
+
+	i := sort.Search(len(state.released), func(i int) bool {
+		return state.released[i] >= nonce
+	})
+	if i < len(state.released) && state.released[i] == nonce {
+		return nil
+	}
+	released := state.released
+	state.released = append(append(append([]uint64{},
+		released[:i]...), nonce), released[i:]...)
+
+	if err := m.persist(key, state); err != nil {
+		state.released = released
+		return err
+	}
+	return nil
+}
+
+// Reset forgets the locally tracked nonces of the account on the chain, so the
+// next nonce is taken from the node again.
+func (m *NonceManager) Reset(chainID *big.Int, address common.Address) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	key := nonceKey{chainID.Uint64(), address}
+	delete(m.accounts, key)
+	if _, ok := m.entries[key.String()]; !ok {
+		return nil
+	}
+	delete(m.entries, key.String())
+	return m.write()
+}
+
+// state returns the tracked state of the account on the chain.
+func (m *NonceManager) state(
+	chainID *big.Int,
+	address common.Address,
+) (nonceKey, *nonceState) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	key := nonceKey{chainID.Uint64(), address}
+	state, ok := m.accounts[key]
+	if !ok {
+		state = &nonceState{}
+		m.accounts[key] = state
+	}
+	return key, state
+}
+
+// load reads the state of the manager from its file, if it exists.
+func (m *NonceManager) load() error {
+	data, err := os.ReadFile(m.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	entries := map[string]nonceFileEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	for id, entry := range entries {
+		key, err := parseNonceKey(id)
+		if err != nil {
+			return err
+		}
+		released := append([]uint64{}, entry.Released...)
+		sort.Slice(released, func(i, j int) bool {
+			return released[i] < released[j]
+		})
+		m.accounts[key] = &nonceState{
+			next:     entry.Next,
+			released: released,
+		}
+		m.entries[key.String()] = entry
+	}
+	return nil
+}
+
+// persist records the state of the account and writes all states to the file
+// of the manager. The previous record is restored if the file cannot be
+// written. The caller must hold the lock of the account state.
+func (m *NonceManager) persist(key nonceKey, state *nonceState) error {
+	if m.file == "" {
+		return nil
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	id := key.String()
+	prev, ok := m.entries[id]
+	m.entries[id] = nonceFileEntry{
+		Next:     state.next,
+		Released: append([]uint64{}, state.released...),
+	}
+	if err := m.write(); err != nil {
+		if ok {
+			m.entries[id] = prev
+		} else {
+			delete(m.entries, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// write writes the recorded states to the file of the manager. The caller must
+// hold the lock of the manager.
+func (m *NonceManager) write() error {
+	if m.file == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(m.file, data)
+}
+
+// String returns the key in the <chain id>:<address> format used in the file.
+func (k nonceKey) String() string {
+	return fmt.Sprintf("%d:%s", k.chainID, k.address.Hex())
+}
+
+// parseNonceKey parses a key in the <chain id>:<address> format.
+func parseNonceKey(id string) (nonceKey, error) {
+	chainID, address, ok := strings.Cut(id, ":")
+	if !ok || !common.IsHexAddress(address) {
+		return nonceKey{}, fmt.Errorf("invalid nonce entry %q", id)
+	}
+
+	n, err := strconv.ParseUint(chainID, 10, 64)
+	if err != nil {
+		return nonceKey{}, fmt.Errorf("invalid nonce entry %q", id)
+	}
+	return nonceKey{n, common.HexToAddress(address)}, nil
+}
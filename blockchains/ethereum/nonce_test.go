@@ -0,0 +1,150 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// pendingNonceReader reports a fixed pending nonce.
+type pendingNonceReader struct {
+	ethereum.PendingStateReader
+	nonce uint64
+}
+
+func (r pendingNonceReader) PendingNonceAt(
+	ctx context.Context,
+	account common.Address,
+) (uint64, error) {
+	return r.nonce, nil
+}
+
+func TestNonceManagerPersistFailure(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "nonces.json")
+	m, err := NewNonceManager(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	client := pendingNonceReader{nonce: 5}
+	chainID := big.NewInt(1)
+	address := common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+
+	if nonce, err := m.Next(ctx, client, chainID, address); err != nil || nonce != 5 {
+		t.Fatalf("Next returned %d, %v, want 5", nonce, err)
+	}
+
+	// a directory in place of the file makes the rename fail
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(file, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Next(ctx, client, chainID, address); err == nil {
+		t.Fatal("Next succeeded without persisting the nonce")
+	}
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if nonce, err := m.Next(ctx, client, chainID, address); err != nil || nonce != 6 {
+		t.Fatalf("Next after a failed persist returned %d, %v, want 6", nonce, err)
+	}
+}
+
+func TestNonceManagerRelease(t *testing.T) {
+	m, err := NewNonceManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	client := pendingNonceReader{nonce: 0}
+	chainID := big.NewInt(1)
+	address := common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+
+	for want := uint64(0); want < 3; want++ {
+		nonce, err := m.Next(ctx, client, chainID, address)
+		if err != nil || nonce != want {
+			t.Fatalf("Next returned %d, %v, want %d", nonce, err, want)
+		}
+	}
+	if err := m.Release(chainID, address, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []uint64{1, 3} {
+		nonce, err := m.Next(ctx, client, chainID, address)
+		if err != nil || nonce != want {
+			t.Fatalf("Next returned %d, %v, want %d", nonce, err, want)
+		}
+	}
+}
+
+func TestNonceManagerPersistFailureOtherAccount(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "nonces.json")
+	m, err := NewNonceManager(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	client := pendingNonceReader{nonce: 5}
+	chainID := big.NewInt(1)
+	address := common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+	other := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.Next(ctx, client, chainID, address); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a directory in place of the file makes the rename fail
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(file, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Next(ctx, client, chainID, address); err == nil {
+		t.Fatal("Next succeeded without persisting the nonce")
+	}
+	if err := m.Release(chainID, address, 5); err == nil {
+		t.Fatal("Release succeeded without persisting the nonce")
+	}
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Next(ctx, client, chainID, other); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]nonceFileEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	entry := entries[nonceKey{1, address}.String()]
+	if entry.Next != 7 || len(entry.Released) != 0 {
+		t.Fatalf("persisted entry %+v, want the next nonce 7", entry)
+	}
+
+	// the failed release is not issued
+	if nonce, err := m.Next(ctx, client, chainID, address); err != nil || nonce != 7 {
+		t.Fatalf("Next returned %d, %v, want 7", nonce, err)
+	}
+}
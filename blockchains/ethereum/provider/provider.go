@@ -0,0 +1,589 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// DefaultRetries is the number of times a failed request is retried on
+	// the next endpoint by default.
+	DefaultRetries = 2
+	// DefaultBackoff is the delay before the first retry by default. The
+	// delay doubles with every retry.
+	DefaultBackoff = 200 * time.Millisecond
+	// maxBackoff caps the delay between retries.
+	maxBackoff = 5 * time.Second
+	// healthCheckTimeout bounds a single health check of an endpoint.
+	healthCheckTimeout = 5 * time.Second
+)
+
+// Strategy selects the endpoint a request is routed to first.
+type Strategy int
+
+const (
+	// RoundRobin spreads requests over the healthy endpoints in turn.
+	RoundRobin Strategy = iota
+	// Priority sends requests to the first healthy endpoint, in the order
+	// the endpoints were given.
+	Priority
+)
+
+var (
+	// ErrNoEndpoints is returned when a provider is created without
+	// endpoints.
+	ErrNoEndpoints = errors.New("no rpc endpoints")
+	// ErrNoQuorum is returned when not enough endpoints agree on the result
+	// of a quorum read.
+	ErrNoQuorum = errors.New("rpc endpoints did not reach quorum")
+)
+
+// Provider wraps several RPC endpoints of the same chain. Requests are routed
+// to healthy endpoints and retried with backoff on the next endpoint when they
+// fail with a transient error. Balance and nonce reads can require a quorum of
+// endpoints to agree.
+//
+// Provider implements the client interfaces used by the wallet, the token and
+// the transaction tracker.
+type Provider struct {
+	endpoints []*endpoint
+	next      uint32
+
+	lock     sync.RWMutex
+	strategy Strategy
+	retries  int
+	backoff  time.Duration
+	quorum   int
+}
+
+type endpoint struct {
+	url    string
+	client *ethclient.Client
+
+	lock    sync.Mutex
+	healthy bool
+}
+
+// NewProvider dials the endpoints. All endpoints start out healthy.
+func NewProvider(ctx context.Context, urls ...string) (*Provider, error) {
+	if len(urls) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	p := &Provider{
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+		quorum:  1,
+	}
+	for _, url := range urls {
+		client, err := rpc.DialContext(ctx, url)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("dial %s: %w", url, err)
+		}
+		p.endpoints = append(p.endpoints, &endpoint{
+			url:     url,
+			client:  ethclient.NewClient(client),
+			healthy: true,
+		})
+	}
+	return p, nil
+}
+
+// Close closes the connections to all endpoints.
+func (p *Provider) Close() {
+	for _, e := range p.endpoints {
+		e.client.Close()
+	}
+}
+
+// SetStrategy sets how requests are routed over the endpoints.
+func (p *Provider) SetStrategy(strategy Strategy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.strategy = strategy
+}
+
+// SetRetries sets how many times a request failing with a transient error is
+// retried, and the delay before the first retry.
+func (p *Provider) SetRetries(retries int, backoff time.Duration) error {
+	if retries < 0 {
+		return fmt.Errorf("retries must not be negative, got %d", retries)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.retries = retries
+	p.backoff = backoff
+	return nil
+}
+
+// SetQuorum sets how many endpoints must return the same balance or nonce
+// before it is accepted. A quorum of 1 disables quorum reads.
+func (p *Provider) SetQuorum(quorum int) error {
+	if quorum < 1 || quorum > len(p.endpoints) {
+		return fmt.Errorf(
+			"quorum must be between 1 and %d, got %d", len(p.endpoints), quorum)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.quorum = quorum
+	return nil
+}
+
+// Healthy returns the URLs of the endpoints currently considered healthy.
+func (p *Provider) Healthy() []string {
+	var urls []string
+	for _, e := range p.endpoints {
+		if e.isHealthy() {
+			urls = append(urls, e.url)
+		}
+	}
+	return urls
+}
+
+// CheckHealth requests the latest block number from every endpoint and marks
+// the endpoints healthy or unhealthy accordingly.
+func (p *Provider) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			_, err := e.client.BlockNumber(ctx)
+			e.report(err)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// StartHealthChecks checks the health of the endpoints at the interval until
+// the context is done.
+func (p *Provider) StartHealthChecks(
+	ctx context.Context,
+	interval time.Duration,
+) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.CheckHealth(ctx)
+			}
+		}
+	}()
+}
+
+// ChainID implements ethereum.ChainIDReader.
+func (p *Provider) ChainID(ctx context.Context) (*big.Int, error) {
+	return call(ctx, p, func(c *ethclient.Client) (*big.Int, error) {
+		return c.ChainID(ctx)
+	})
+}
+
+// BlockNumber implements ethereum.BlockNumberReader.
+func (p *Provider) BlockNumber(ctx context.Context) (uint64, error) {
+	return call(ctx, p, func(c *ethclient.Client) (uint64, error) {
+		return c.BlockNumber(ctx)
+	})
+}
+
+// HeaderByNumber returns the header of the block, or the latest header if the
+// number is nil.
+func (p *Provider) HeaderByNumber(
+	ctx context.Context,
+	number *big.Int,
+) (*types.Header, error) {
+	return call(ctx, p, func(c *ethclient.Client) (*types.Header, error) {
+		return c.HeaderByNumber(ctx, number)
+	})
+}
+
+// BalanceAt implements ethereum.ChainStateReader. The balance is read with a
+// quorum if one is set.
+func (p *Provider) BalanceAt(
+	ctx context.Context,
+	account common.Address,
+	blockNumber *big.Int,
+) (*big.Int, error) {
+	return quorumCall(ctx, p, func(c *ethclient.Client) (*big.Int, error) {
+		return c.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+// StorageAt implements ethereum.ChainStateReader.
+func (p *Provider) StorageAt(
+	ctx context.Context,
+	account common.Address,
+	key common.Hash,
+	blockNumber *big.Int,
+) ([]byte, error) {
+	return call(ctx, p, func(c *ethclient.Client) ([]byte, error) {
+		return c.StorageAt(ctx, account, key, blockNumber)
+	})
+}
+
+// CodeAt implements ethereum.ChainStateReader.
+func (p *Provider) CodeAt(
+	ctx context.Context,
+	account common.Address,
+	blockNumber *big.Int,
+) ([]byte, error) {
+	return call(ctx, p, func(c *ethclient.Client) ([]byte, error) {
+		return c.CodeAt(ctx, account, blockNumber)
+	})
+}
+
+// NonceAt implements ethereum.ChainStateReader. The nonce is read with a
+// quorum if one is set.
+func (p *Provider) NonceAt(
+	ctx context.Context,
+	account common.Address,
+	blockNumber *big.Int,
+) (uint64, error) {
+	return quorumCall(ctx, p, func(c *ethclient.Client) (uint64, error) {
+		return c.NonceAt(ctx, account, blockNumber)
+	})
+}
+
+// PendingBalanceAt implements ethereum.PendingStateReader. The balance is read
+// with a quorum if one is set.
+func (p *Provider) PendingBalanceAt(
+	ctx context.Context,
+	account common.Address,
+) (*big.Int, error) {
+	return quorumCall(ctx, p, func(c *ethclient.Client) (*big.Int, error) {
+		return c.PendingBalanceAt(ctx, account)
+	})
+}
+
+// PendingStorageAt implements ethereum.PendingStateReader.
+func (p *Provider) PendingStorageAt(
+	ctx context.Context,
+	account common.Address,
+	key common.Hash,
+) ([]byte, error) {
+	return call(ctx, p, func(c *ethclient.Client) ([]byte, error) {
+		return c.PendingStorageAt(ctx, account, key)
+	})
+}
+
+// PendingCodeAt implements ethereum.PendingStateReader.
+func (p *Provider) PendingCodeAt(
+	ctx context.Context,
+	account common.Address,
+) ([]byte, error) {
+	return call(ctx, p, func(c *ethclient.Client) ([]byte, error) {
+		return c.PendingCodeAt(ctx, account)
+	})
+}
+
+// PendingNonceAt implements ethereum.PendingStateReader. The nonce is read with
+// a quorum if one is set.
+func (p *Provider) PendingNonceAt(
+	ctx context.Context,
+	account common.Address,
+) (uint64, error) {
+	return quorumCall(ctx, p, func(c *ethclient.Client) (uint64, error) {
+		return c.PendingNonceAt(ctx, account)
+	})
+}
+
+// PendingTransactionCount implements ethereum.PendingStateReader.
+func (p *Provider) PendingTransactionCount(ctx context.Context) (uint, error) {
+	return call(ctx, p, func(c *ethclient.Client) (uint, error) {
+		return c.PendingTransactionCount(ctx)
+	})
+}
+
+// SuggestGasPrice implements ethereum.GasPricer.
+func (p *Provider) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return call(ctx, p, func(c *ethclient.Client) (*big.Int, error) {
+		return c.SuggestGasPrice(ctx)
+	})
+}
+
+// SuggestGasTipCap implements ethereum.GasPricer1559.
+func (p *Provider) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return call(ctx, p, func(c *ethclient.Client) (*big.Int, error) {
+		return c.SuggestGasTipCap(ctx)
+	})
+}
+
+// EstimateGas implements ethereum.GasEstimator.
+func (p *Provider) EstimateGas(
+	ctx context.Context,
+	msg ethereum.CallMsg,
+) (uint64, error) {
+	return call(ctx, p, func(c *ethclient.Client) (uint64, error) {
+		return c.EstimateGas(ctx, msg)
+	})
+}
+
+// CallContract implements ethereum.ContractCaller.
+func (p *Provider) CallContract(
+	ctx context.Context,
+	msg ethereum.CallMsg,
+	blockNumber *big.Int,
+) ([]byte, error) {
+	return call(ctx, p, func(c *ethclient.Client) ([]byte, error) {
+		return c.CallContract(ctx, msg, blockNumber)
+	})
+}
+
+// TransactionByHash implements ethereum.TransactionReader.
+func (p *Provider) TransactionByHash(
+	ctx context.Context,
+	hash common.Hash,
+) (*types.Transaction, bool, error) {
+	type result struct {
+		tx        *types.Transaction
+		isPending bool
+	}
+	r, err := call(ctx, p, func(c *ethclient.Client) (result, error) {
+		tx, isPending, err := c.TransactionByHash(ctx, hash)
+		return result{tx, isPending}, err
+	})
+	return r.tx, r.isPending, err
+}
+
+// TransactionReceipt implements ethereum.TransactionReader.
+func (p *Provider) TransactionReceipt(
+	ctx context.Context,
+	hash common.Hash,
+) (*types.Receipt, error) {
+	return call(ctx, p, func(c *ethclient.Client) (*types.Receipt, error) {
+		return c.TransactionReceipt(ctx, hash)
+	})
+}
+
+// SendTransaction implements ethereum.TransactionSender. A retry that finds
+// the transaction already known to the node counts as success, since an
+// earlier attempt may have reached it before failing.
+func (p *Provider) SendTransaction(
+	ctx context.Context,
+	tx *types.Transaction,
+) error {
+	_, err := call(ctx, p, func(c *ethclient.Client) (struct{}, error) {
+		err := c.SendTransaction(ctx, tx)
+		if err != nil && strings.Contains(err.Error(), "already known") {
+			err = nil
+		}
+		return struct{}{}, err
+	})
+	return err
+}
+
+// call runs the request on the routed endpoint, retrying transient failures on
+// the next endpoint with exponential backoff.
+func call[T any](
+	ctx context.Context,
+	p *Provider,
+	request func(*ethclient.Client) (T, error),
+) (T, error) {
+	p.lock.RLock()
+	retries, backoff := p.retries, p.backoff
+	p.lock.RUnlock()
+
+	var (
+		result T
+		err    error
+	)
+	endpoints := p.route()
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, backoffDelay(backoff, attempt)); err != nil {
+				return result, err
+			}
+		}
+
+		e := endpoints[attempt%len(endpoints)]
+		result, err = request(e.client)
+		if !isTransient(ctx, err) {
+			if err == nil {
+				e.report(nil)
+			}
+			return result, err
+		}
+		e.report(err)
+	}
+	return result, err
+}
+
+// quorumCall runs the request on all healthy endpoints and returns the result
+// at least quorum of them agree on. Without a quorum set, it behaves like call.
+func quorumCall[T any](
+	ctx context.Context,
+	p *Provider,
+	request func(*ethclient.Client) (T, error),
+) (T, error) {
+	p.lock.RLock()
+	quorum := p.quorum
+	p.lock.RUnlock()
+
+	if quorum <= 1 {
+		return call(ctx, p, request)
+	}
+
+	endpoints := p.route()
+	results := make([]T, len(endpoints))
+	errs := make([]error, len(endpoints))
+
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Add(1)
+		go func(i int, e *endpoint) {
+			defer wg.Done()
+
+			results[i], errs[i] = request(e.client)
+			if errs[i] == nil || isTransient(ctx, errs[i]) {
+				e.report(errs[i])
+			}
+		}(i, e)
+	}
+	wg.Wait()
+
+	// Results are compared by their formatted value, which covers the big
+	// integers and integers quorum reads return.
+	votes := map[string]int{}
+	var lastErr error
+	for i, result := range results {
+		if errs[i] != nil {
+			lastErr = errs[i]
+			continue
+		}
+
+		key := fmt.Sprint(result)
+		if votes[key]++; votes[key] >= quorum {
+			return result, nil
+		}
+	}
+
+	var zero T
+	if lastErr != nil {
+		return zero, fmt.Errorf("%w: %v", ErrNoQuorum, lastErr)
+	}
+	return zero, ErrNoQuorum
+}
+
+// route returns the endpoints in the order requests should try them. Healthy
+// endpoints come first, but unhealthy ones are still tried as a last resort.
+func (p *Provider) route() []*endpoint {
+	p.lock.RLock()
+	strategy := p.strategy
+	p.lock.RUnlock()
+
+	start := 0
+	if strategy == RoundRobin {
+		start = int(atomic.AddUint32(&p.next, 1)-1) % len(p.endpoints)
+	}
+
+	var healthy, unhealthy []*endpoint
+	for i := range p.endpoints {
+		e := p.endpoints[(start+i)%len(p.endpoints)]
+		if e.isHealthy() {
+			healthy = append(healthy, e)
+		} else {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// report records the outcome of a request to the endpoint.
+func (e *endpoint) report(err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.healthy = err == nil
+}
+
+// isHealthy returns whether the last request to the endpoint succeeded.
+func (e *endpoint) isHealthy() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.healthy
+}
+
+// isTransient returns whether the request failed in a way another attempt,
+// possibly on another endpoint, can fix: rate limiting and server errors of the
+// endpoint, internal and limit errors of the node, and network failures. Any
+// other error, like a reverted call, a missing transaction or a request that
+// could not be encoded, is final.
+func isTransient(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests ||
+			httpErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.ErrorCode() {
+		case -32603, -32005: // internal error, limit exceeded
+			return true
+		}
+		return false
+	}
+
+	// The connection failed or was closed before the response was read
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoffDelay returns the delay before the retry.
+func backoffDelay(backoff time.Duration, attempt int) time.Duration {
+	delay := backoff << (attempt - 1)
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// sleep waits for the delay or until the context is done.
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/internal/rpctest"
+)
+
+func newTestProvider(t *testing.T, nodes ...*rpctest.Node) *Provider {
+	urls := make([]string, len(nodes))
+	for i, n := range nodes {
+		urls[i] = n.URL
+	}
+	p, err := NewProvider(context.Background(), urls...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	p.SetStrategy(Priority)
+	if err := p.SetRetries(DefaultRetries, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestFailover(t *testing.T) {
+	down := rpctest.NewNode(t, func(string) rpctest.Reply {
+		return rpctest.Reply{Status: http.StatusServiceUnavailable}
+	})
+	up := rpctest.NewNode(t, rpctest.Result("0x1"))
+	p := newTestProvider(t, down, up)
+
+	chainID, err := p.ChainID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chainID.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("chain id %v, want 1", chainID)
+	}
+
+	healthy := p.Healthy()
+	if len(healthy) != 1 || healthy[0] != up.URL {
+		t.Fatalf("healthy endpoints %v, want only %s", healthy, up.URL)
+	}
+
+	// the unhealthy endpoint is tried last from now on
+	if _, err := p.ChainID(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := down.Requests(); got != 1 {
+		t.Fatalf("failing endpoint got %d requests, want 1", got)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, status := range []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+	} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			var calls atomic.Int32
+			n := rpctest.NewNode(t, func(string) rpctest.Reply {
+				if calls.Add(1) == 1 {
+					return rpctest.Reply{Status: status}
+				}
+				return rpctest.Reply{Result: "0x10"}
+			})
+			p := newTestProvider(t, n)
+
+			number, err := p.BlockNumber(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if number != 16 {
+				t.Fatalf("block number %d, want 16", number)
+			}
+			if got := n.Requests(); got != 2 {
+				t.Fatalf("got %d requests, want 2", got)
+			}
+		})
+	}
+}
+
+func TestSetRetries(t *testing.T) {
+	n := rpctest.NewNode(t, func(string) rpctest.Reply {
+		return rpctest.Reply{Status: http.StatusBadGateway}
+	})
+	p := newTestProvider(t, n)
+
+	if err := p.SetRetries(-1, time.Millisecond); err == nil {
+		t.Fatal("SetRetries accepted a negative number of retries")
+	}
+	if _, err := p.BlockNumber(context.Background()); err == nil {
+		t.Fatal("BlockNumber succeeded against a failing node")
+	}
+	if got := n.Requests(); got != DefaultRetries+1 {
+		t.Fatalf("got %d requests, want %d", got, DefaultRetries+1)
+	}
+}
+
+func TestNoRetryOnFinalErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply rpctest.Reply
+	}{
+		{"reverted", rpctest.Reply{Code: 3, Message: "execution reverted"}},
+		{
+			"invalid params",
+			rpctest.Reply{Code: -32602, Message: "invalid params"},
+		},
+		{"bad request", rpctest.Reply{Status: http.StatusBadRequest}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := rpctest.NewNode(t, func(string) rpctest.Reply {
+				return test.reply
+			})
+			p := newTestProvider(t, n)
+
+			if _, err := p.BlockNumber(context.Background()); err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := n.Requests(); got != 1 {
+				t.Fatalf("got %d requests, want 1", got)
+			}
+		})
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	address := common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+	p := newTestProvider(t,
+		rpctest.NewNode(t, rpctest.Result("0x64")),
+		rpctest.NewNode(t, rpctest.Result("0x65")),
+		rpctest.NewNode(t, rpctest.Result("0x64")),
+	)
+
+	if err := p.SetQuorum(2); err != nil {
+		t.Fatal(err)
+	}
+	balance, err := p.BalanceAt(context.Background(), address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("balance %v, want 100", balance)
+	}
+
+	if err := p.SetQuorum(3); err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.BalanceAt(context.Background(), address, nil)
+	if !errors.Is(err, ErrNoQuorum) {
+		t.Fatalf("BalanceAt returned %v, want %v", err, ErrNoQuorum)
+	}
+}
@@ -0,0 +1,492 @@
+package siwe
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/utils"
+)
+
+// Version is the version of EIP-4361 messages.
+const Version = "1"
+
+// nonceAlphabet holds the characters of generated nonces.
+const nonceAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// NonceLength is the length of nonces generated by NewNonce.
+const NonceLength = 17
+
+// minNonceLength is the minimum length of a nonce required by EIP-4361.
+const minNonceLength = 8
+
+// DefaultClockSkew is how far the clock of the signer may be ahead of the
+// verifier by default.
+const DefaultClockSkew = 30 * time.Second
+
+const (
+	preambleSuffix = " wants you to sign in with your Ethereum account:"
+
+	uriTag            = "URI: "
+	versionTag        = "Version: "
+	chainIDTag        = "Chain ID: "
+	nonceTag          = "Nonce: "
+	issuedAtTag       = "Issued At: "
+	expirationTimeTag = "Expiration Time: "
+	notBeforeTag      = "Not Before: "
+	requestIDTag      = "Request ID: "
+	resourcesTag      = "Resources:"
+	resourcePrefix    = "- "
+)
+
+var (
+	// ErrInvalidMessage is returned when a message does not follow EIP-4361.
+	ErrInvalidMessage = errors.New("invalid sign-in message")
+	// ErrDomainMismatch is returned when a message is for another domain.
+	ErrDomainMismatch = errors.New("sign-in domain mismatch")
+	// ErrNonceMismatch is returned when a message carries another nonce.
+	ErrNonceMismatch = errors.New("sign-in nonce mismatch")
+	// ErrChainIDMismatch is returned when a message is for another chain.
+	ErrChainIDMismatch = errors.New("sign-in chain id mismatch")
+	// ErrExpired is returned when the expiration time of a message passed.
+	ErrExpired = errors.New("sign-in message expired")
+	// ErrNotYetValid is returned when the not before time of a message has
+	// not been reached.
+	ErrNotYetValid = errors.New("sign-in message not yet valid")
+	// ErrIssuedInFuture is returned when the issued at time of a message has
+	// not been reached.
+	ErrIssuedInFuture = errors.New("sign-in message issued in the future")
+	// ErrMissingExpectation is returned when the verify options lack the
+	// domain or nonce a message must be checked against.
+	ErrMissingExpectation = errors.New(
+		"expected sign-in domain and nonce required")
+)
+
+// TextSigner signs text following EIP-191, like hdwallet.Wallet.
+type TextSigner interface {
+	SignText(accounts.Account, []byte) ([]byte, error)
+}
+
+// Message is a Sign-In with Ethereum message as defined by EIP-4361.
+type Message struct {
+	Scheme         string
+	Domain         string
+	Address        common.Address
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        uint64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// VerifyOptions holds the values a signed message is validated against. The
+// domain and nonce are required, a zero chain id is not checked.
+type VerifyOptions struct {
+	Domain  string
+	Nonce   string
+	ChainID uint64
+	// Time is the time the validity window of the message is checked at,
+	// defaulting to now.
+	Time time.Time
+	// ClockSkew is how far the issued at and not before times of the
+	// message may be ahead of Time, DefaultClockSkew if zero. A negative
+	// skew allows none.
+	ClockSkew time.Duration
+}
+
+// NewMessage creates a message for the address to sign in to the domain,
+// issued now with a random nonce.
+func NewMessage(
+	domain string,
+	address common.Address,
+	uri string,
+	chainID uint64,
+) (*Message, error) {
+	nonce, err := NewNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		Domain:   domain,
+		Address:  address,
+		URI:      uri,
+		Version:  Version,
+		ChainID:  chainID,
+		Nonce:    nonce,
+		IssuedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// NewNonce returns a random alphanumeric nonce of NonceLength characters.
+func NewNonce() (string, error) {
+	max := big.NewInt(int64(len(nonceAlphabet)))
+
+	nonce := make([]byte, NonceLength)
+	for i := range nonce {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		nonce[i] = nonceAlphabet[n.Int64()]
+	}
+	return string(nonce), nil
+}
+
+// Validate checks that the fields of the message can be formatted as an
+// EIP-4361 message.
+func (m *Message) Validate() error {
+	if m.Domain == "" || strings.ContainsAny(m.Domain, " \n") {
+		return fmt.Errorf("%w: invalid domain %q", ErrInvalidMessage, m.Domain)
+	}
+	if strings.Contains(m.Statement, "\n") {
+		return fmt.Errorf("%w: statement spans lines", ErrInvalidMessage)
+	}
+	if _, err := url.Parse(m.URI); err != nil || m.URI == "" {
+		return fmt.Errorf("%w: invalid uri %q", ErrInvalidMessage, m.URI)
+	}
+	if m.Version != Version {
+		return fmt.Errorf(
+			"%w: unsupported version %q", ErrInvalidMessage, m.Version)
+	}
+	if !validNonce(m.Nonce) {
+		return fmt.Errorf("%w: invalid nonce %q", ErrInvalidMessage, m.Nonce)
+	}
+	if m.IssuedAt.IsZero() {
+		return fmt.Errorf("%w: missing issued at", ErrInvalidMessage)
+	}
+	if strings.Contains(m.RequestID, "\n") {
+		return fmt.Errorf("%w: request id spans lines", ErrInvalidMessage)
+	}
+	for _, resource := range m.Resources {
+		if _, err := url.Parse(resource); err != nil || resource == "" {
+			return fmt.Errorf(
+				"%w: invalid resource %q", ErrInvalidMessage, resource)
+		}
+	}
+	return nil
+}
+
+// String formats the message as the EIP-4361 text that gets signed.
+func (m *Message) String() string {
+	var b strings.Builder
+
+	if m.Scheme != "" {
+		b.WriteString(m.Scheme + "://")
+	}
+	b.WriteString(m.Domain + preambleSuffix + "\n")
+	b.WriteString(m.Address.Hex() + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString(uriTag + m.URI + "\n")
+	b.WriteString(versionTag + m.Version + "\n")
+	b.WriteString(chainIDTag + strconv.FormatUint(m.ChainID, 10) + "\n")
+	b.WriteString(nonceTag + m.Nonce + "\n")
+	b.WriteString(issuedAtTag + formatTime(m.IssuedAt))
+	if m.ExpirationTime != nil {
+		b.WriteString("\n" + expirationTimeTag + formatTime(*m.ExpirationTime))
+	}
+	if m.NotBefore != nil {
+		b.WriteString("\n" + notBeforeTag + formatTime(*m.NotBefore))
+	}
+	if m.RequestID != "" {
+		b.WriteString("\n" + requestIDTag + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\n" + resourcesTag)
+		for _, resource := range m.Resources {
+			b.WriteString("\n" + resourcePrefix + resource)
+		}
+	}
+	return b.String()
+}
+
+// Sign signs the message with the account, which must be the address of the
+// message. The signature uses the canonical Ethereum V of 27 or 28.
+func (m *Message) Sign(
+	signer TextSigner,
+	account accounts.Account,
+) ([]byte, error) {
+	if account.Address != m.Address {
+		return nil, fmt.Errorf(
+			"account %s does not match message address %s",
+			account.Address.Hex(), m.Address.Hex())
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	sig, err := signer.SignText(account, []byte(m.String()))
+	if err != nil {
+		return nil, err
+	}
+	if sig[crypto.RecoveryIDOffset] < 27 {
+		sig[crypto.RecoveryIDOffset] += 27
+	}
+	return sig, nil
+}
+
+// Verify checks that the message was signed by its address and that it is
+// valid for the options.
+func (m *Message) Verify(sig []byte, opts VerifyOptions) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if err := utils.VerifyText([]byte(m.String()), sig, m.Address); err != nil {
+		return err
+	}
+	return m.check(opts)
+}
+
+// Verify parses the signed EIP-4361 text, checks that it was signed by its
+// address and that it is valid for the options.
+func Verify(
+	text string,
+	sig []byte,
+	opts VerifyOptions,
+) (*Message, error) {
+	msg, err := ParseMessage(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.VerifyText([]byte(text), sig, msg.Address); err != nil {
+		return nil, err
+	}
+	if err := msg.check(opts); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// check checks the message against the options.
+func (m *Message) check(opts VerifyOptions) error {
+	if opts.Domain == "" || opts.Nonce == "" {
+		return ErrMissingExpectation
+	}
+	if opts.Domain != m.Domain {
+		return fmt.Errorf(
+			"%w: expected %s, got %s", ErrDomainMismatch, opts.Domain, m.Domain)
+	}
+	if opts.Nonce != m.Nonce {
+		return ErrNonceMismatch
+	}
+	if opts.ChainID != 0 && opts.ChainID != m.ChainID {
+		return fmt.Errorf(
+			"%w: expected %d, got %d", ErrChainIDMismatch, opts.ChainID, m.ChainID)
+	}
+
+	now := opts.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	skew := opts.ClockSkew
+	if skew == 0 {
+		skew = DefaultClockSkew
+	} else if skew < 0 {
+		skew = 0
+	}
+	if now.Add(skew).Before(m.IssuedAt) {
+		return fmt.Errorf(
+			"%w: %s", ErrIssuedInFuture, formatTime(m.IssuedAt))
+	}
+	if m.ExpirationTime != nil && !now.Before(*m.ExpirationTime) {
+		return fmt.Errorf(
+			"%w at %s", ErrExpired, formatTime(*m.ExpirationTime))
+	}
+	if m.NotBefore != nil && now.Add(skew).Before(*m.NotBefore) {
+		return fmt.Errorf(
+			"%w until %s", ErrNotYetValid, formatTime(*m.NotBefore))
+	}
+	return nil
+}
+
+// ParseMessage parses an EIP-4361 message.
+func ParseMessage(text string) (*Message, error) {
+	p := &parser{lines: strings.Split(text, "\n")}
+	msg := &Message{}
+
+	preamble, ok := p.next()
+	if !ok || !strings.HasSuffix(preamble, preambleSuffix) {
+		return nil, p.errorf("missing preamble")
+	}
+	msg.Domain = strings.TrimSuffix(preamble, preambleSuffix)
+	if scheme, domain, ok := strings.Cut(msg.Domain, "://"); ok {
+		msg.Scheme, msg.Domain = scheme, domain
+	}
+
+	address, ok := p.next()
+	if !ok || !common.IsHexAddress(address) ||
+		common.HexToAddress(address).Hex() != address {
+		return nil, p.errorf("address must be an EIP-55 checksummed address")
+	}
+	msg.Address = common.HexToAddress(address)
+
+	if line, ok := p.next(); !ok || line != "" {
+		return nil, p.errorf("expected empty line")
+	}
+	// The statement is optional and followed by an empty line. Messages
+	// without statement have either one or, following the current ABNF, two
+	// empty lines before the URI.
+	if line, ok := p.peek(); ok && !strings.HasPrefix(line, uriTag) {
+		p.next()
+		if line != "" {
+			msg.Statement = line
+			if line, ok := p.next(); !ok || line != "" {
+				return nil, p.errorf("expected empty line")
+			}
+		}
+	}
+
+	var err error
+	if msg.URI, err = p.tag(uriTag); err != nil {
+		return nil, err
+	}
+	if msg.Version, err = p.tag(versionTag); err != nil {
+		return nil, err
+	}
+
+	chainID, err := p.tag(chainIDTag)
+	if err != nil {
+		return nil, err
+	}
+	if msg.ChainID, err = strconv.ParseUint(chainID, 10, 64); err != nil {
+		return nil, p.errorf("invalid chain id %q", chainID)
+	}
+
+	if msg.Nonce, err = p.tag(nonceTag); err != nil {
+		return nil, err
+	}
+	if msg.IssuedAt, err = p.timeTag(issuedAtTag); err != nil {
+		return nil, err
+	}
+
+	if p.hasTag(expirationTimeTag) {
+		expirationTime, err := p.timeTag(expirationTimeTag)
+		if err != nil {
+			return nil, err
+		}
+		msg.ExpirationTime = &expirationTime
+	}
+	if p.hasTag(notBeforeTag) {
+		notBefore, err := p.timeTag(notBeforeTag)
+		if err != nil {
+			return nil, err
+		}
+		msg.NotBefore = &notBefore
+	}
+	if p.hasTag(requestIDTag) {
+		if msg.RequestID, err = p.tag(requestIDTag); err != nil {
+			return nil, err
+		}
+	}
+	if p.hasTag(resourcesTag) {
+		p.next()
+		for p.hasTag(resourcePrefix) {
+			resource, _ := p.tag(resourcePrefix)
+			msg.Resources = append(msg.Resources, resource)
+		}
+	}
+
+	if line, ok := p.next(); ok {
+		return nil, p.errorf("unexpected line %q", line)
+	}
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// parser reads the lines of a message.
+type parser struct {
+	lines []string
+	pos   int
+}
+
+// next returns the next line.
+func (p *parser) next() (string, bool) {
+	line, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return line, ok
+}
+
+// peek returns the next line without consuming it.
+func (p *parser) peek() (string, bool) {
+	if p.pos >= len(p.lines) {
+		return "", false
+	}
+	return p.lines[p.pos], true
+}
+
+// hasTag returns whether the next line starts with the tag.
+func (p *parser) hasTag(tag string) bool {
+	line, ok := p.peek()
+	return ok && strings.HasPrefix(line, tag)
+}
+
+// tag returns the value of the next line, which must start with the tag.
+func (p *parser) tag(tag string) (string, error) {
+	if !p.hasTag(tag) {
+		return "", p.errorf("expected %q", strings.TrimSpace(tag))
+	}
+	line, _ := p.next()
+	return strings.TrimPrefix(line, tag), nil
+}
+
+// timeTag returns the RFC 3339 time of the next line, which must start with the
+// tag.
+func (p *parser) timeTag(tag string) (time.Time, error) {
+	value, err := p.tag(tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, p.errorf("invalid time %q", value)
+	}
+	return t, nil
+}
+
+// errorf returns an ErrInvalidMessage error.
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidMessage, fmt.Sprintf(format, args...))
+}
+
+// validNonce returns whether the nonce has at least 8 alphanumeric characters.
+func validNonce(nonce string) bool {
+	if len(nonce) < minNonceLength {
+		return false
+	}
+	for _, c := range nonce {
+		if !strings.ContainsRune(nonceAlphabet, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatTime formats the time as RFC 3339, the format of EIP-4361.
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,148 @@
+package siwe
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/hdwallet"
+)
+
+func TestVerify(t *testing.T) {
+	wallet, err := hdwallet.NewFromMnemonic("abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon abandon abandon abandon about")
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := wallet.Derive(
+		hdwallet.DefaultCoinPath(hdwallet.CoinTypeETH, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := NewMessage(
+		"example.com", account.Address, "https://example.com/login", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := msg.Sign(wallet, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := VerifyOptions{Domain: "example.com", Nonce: msg.Nonce, ChainID: 1}
+	tests := []struct {
+		name string
+		opts VerifyOptions
+		want error
+	}{
+		{"valid", valid, nil},
+		{"no options", VerifyOptions{}, ErrMissingExpectation},
+		{"no nonce", VerifyOptions{Domain: "example.com"}, ErrMissingExpectation},
+		{"no domain", VerifyOptions{Nonce: msg.Nonce}, ErrMissingExpectation},
+		{
+			"other domain",
+			VerifyOptions{Domain: "evil.com", Nonce: msg.Nonce},
+			ErrDomainMismatch,
+		},
+		{
+			"other nonce",
+			VerifyOptions{Domain: "example.com", Nonce: "00000000"},
+			ErrNonceMismatch,
+		},
+		{
+			"other chain",
+			VerifyOptions{Domain: "example.com", Nonce: msg.Nonce, ChainID: 5},
+			ErrChainIDMismatch,
+		},
+		{
+			"issued in the future",
+			VerifyOptions{
+				Domain: "example.com",
+				Nonce:  msg.Nonce,
+				Time:   msg.IssuedAt.Add(-time.Minute),
+			},
+			ErrIssuedInFuture,
+		},
+		{
+			"issued within the clock skew",
+			VerifyOptions{
+				Domain: "example.com",
+				Nonce:  msg.Nonce,
+				Time:   msg.IssuedAt.Add(-10 * time.Second),
+			},
+			nil,
+		},
+		{
+			"issued within a custom clock skew",
+			VerifyOptions{
+				Domain:    "example.com",
+				Nonce:     msg.Nonce,
+				Time:      msg.IssuedAt.Add(-time.Minute),
+				ClockSkew: 2 * time.Minute,
+			},
+			nil,
+		},
+		{
+			"no clock skew",
+			VerifyOptions{
+				Domain:    "example.com",
+				Nonce:     msg.Nonce,
+				Time:      msg.IssuedAt.Add(-time.Second),
+				ClockSkew: -1,
+			},
+			ErrIssuedInFuture,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Verify(msg.String(), sig, test.opts)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("Verify returned %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyNotBefore(t *testing.T) {
+	wallet, err := hdwallet.NewFromMnemonic("abandon abandon abandon " +
+		"abandon abandon abandon abandon abandon abandon abandon abandon about")
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := wallet.Derive(
+		hdwallet.DefaultCoinPath(hdwallet.CoinTypeETH, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := NewMessage(
+		"example.com", account.Address, "https://example.com/login", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notBefore := msg.IssuedAt.Add(time.Hour)
+	msg.NotBefore = &notBefore
+	sig, err := msg.Sign(wallet, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		time time.Time
+		want error
+	}{
+		{notBefore.Add(-time.Minute), ErrNotYetValid},
+		{notBefore.Add(-10 * time.Second), nil},
+		{notBefore, nil},
+	} {
+		err := msg.Verify(sig, VerifyOptions{
+			Domain: "example.com",
+			Nonce:  msg.Nonce,
+			Time:   test.time,
+		})
+		if !errors.Is(err, test.want) {
+			t.Fatalf("Verify at %v returned %v, want %v", test.time, err, test.want)
+		}
+	}
+}
@@ -2,18 +2,43 @@ package ethereum
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/big"
+	"sync"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/solsticewallet/solstice-core/blockchains/ethereum/hdwallet"
 	"github.com/solsticewallet/solstice-core/blockchains/ethereum/utils"
+	"github.com/solsticewallet/solstice-core/slip39"
 )
 
 type SoftwareWallet struct {
 	WalletImp
+
+	feeLock           sync.RWMutex
+	txTypes           map[uint64]uint8
+	baseFeeMultiplier float64
+	gasLimitMargin    uint64
+
+	nonceLock sync.RWMutex
+	nonces    *NonceManager
+}
+
+// newSoftwareWallet wraps the wallet implementation into a SoftwareWallet with
+// the default transaction settings.
+func newSoftwareWallet(imp WalletImp) *SoftwareWallet {
+	return &SoftwareWallet{
+		WalletImp:         imp,
+		txTypes:           map[uint64]uint8{},
+		baseFeeMultiplier: DefaultBaseFeeMultiplier,
+		gasLimitMargin:    DefaultGasLimitMargin,
+		nonces:            newNonceManager(),
+	}
 }
 
 func NewSoftwareWalletFromMnemonic(
@@ -25,9 +50,7 @@ func NewSoftwareWalletFromMnemonic(
 		return nil, err
 	}
 
-	return &SoftwareWallet{
-		WalletImp: imp,
-	}, nil
+	return newSoftwareWallet(imp), nil
 }
 
 func NewSoftwareWalletFromSeed(seed []byte) (Wallet, error) {
@@ -36,14 +59,79 @@ func NewSoftwareWalletFromSeed(seed []byte) (Wallet, error) {
 		return nil, err
 	}
 
-	return &SoftwareWallet{
-		WalletImp: imp,
-	}, nil
+	return newSoftwareWallet(imp), nil
+}
+
+// NewSoftwareWalletFromShares returns a wallet seeded with the master secret
+// recovered from the SLIP-39 mnemonic shares and their passphrase.
+func NewSoftwareWalletFromShares(
+	shares []string,
+	passphrase string,
+) (Wallet, error) {
+	seed, err := slip39.Combine(shares, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSoftwareWalletFromSeed(seed)
+}
+
+// NewSoftwareWalletFromXPub returns a watch-only wallet from the BIP-32 extended
+// public key of the account at the account path. The wallet derives addresses
+// and builds transactions, but cannot sign them.
+func NewSoftwareWalletFromXPub(
+	xpub string,
+	accountPath accounts.DerivationPath,
+) (Wallet, error) {
+	imp, err := hdwallet.NewWatchOnlyFromXPub(xpub, accountPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSoftwareWallet(imp), nil
+}
+
+// NewSoftwareWalletFromKeystore loads a locked wallet from the keystore file.
+// The wallet needs to be opened with its passphrase before it can sign.
+func NewSoftwareWalletFromKeystore(file string) (Wallet, error) {
+	imp, err := hdwallet.Load(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSoftwareWallet(imp), nil
+}
+
+// Save encrypts the wallet secrets with the passphrase and writes them to the
+// keystore file.
+func (w *SoftwareWallet) Save(
+	file string,
+	passphrase string,
+	kdfOpt ...hdwallet.KDF,
+) error {
+	imp, ok := w.WalletImp.(keystoreImp)
+	if !ok {
+		return ErrKeystoreUnsupported
+	}
+	return imp.Save(file, passphrase, kdfOpt...)
+}
+
+// ChangePassphrase re-encrypts the keystore file of the wallet with a new
+// passphrase.
+func (w *SoftwareWallet) ChangePassphrase(
+	oldPassphrase string,
+	newPassphrase string,
+) error {
+	imp, ok := w.WalletImp.(keystoreImp)
+	if !ok {
+		return ErrKeystoreUnsupported
+	}
+	return imp.ChangePassphrase(oldPassphrase, newPassphrase)
 }
 
 func (w *SoftwareWallet) AccountBalance(
 	ctx context.Context,
-	client *ethclient.Client,
+	client ethereum.ChainStateReader,
 	account accounts.Account,
 	blockNumber *big.Int,
 ) (*big.Int, error) {
@@ -52,7 +140,7 @@ func (w *SoftwareWallet) AccountBalance(
 
 func (w *SoftwareWallet) AccountBalanceEth(
 	ctx context.Context,
-	client *ethclient.Client,
+	client ethereum.ChainStateReader,
 	account accounts.Account,
 	blockNumber *big.Int,
 ) (*big.Float, error) {
@@ -65,7 +153,7 @@ func (w *SoftwareWallet) AccountBalanceEth(
 
 func (w *SoftwareWallet) PendingAccountBalance(
 	ctx context.Context,
-	client *ethclient.Client,
+	client ethereum.PendingStateReader,
 	account accounts.Account,
 ) (*big.Int, error) {
 	return client.PendingBalanceAt(ctx, account.Address)
@@ -73,7 +161,7 @@ func (w *SoftwareWallet) PendingAccountBalance(
 
 func (w *SoftwareWallet) PendingAccountBallanceEth(
 	ctx context.Context,
-	client *ethclient.Client,
+	client ethereum.PendingStateReader,
 	account accounts.Account,
 ) (*big.Float, error) {
 	wei, err := w.PendingAccountBalance(ctx, client, account)
@@ -83,25 +171,302 @@ func (w *SoftwareWallet) PendingAccountBallanceEth(
 	return utils.Wei2Eth(wei), nil
 }
 
+// SetTxType sets the transaction envelope CreateTransaction builds for the
+// chain. Supported are types.LegacyTxType, types.AccessListTxType and
+// types.DynamicFeeTxType.
+func (w *SoftwareWallet) SetTxType(chainID *big.Int, txType uint8) error {
+	if chainID == nil {
+		return errors.New("chain id is required")
+	}
+	if !validTxType(txType) {
+		return errors.New("unsupported transaction type")
+	}
+
+	w.feeLock.Lock()
+	defer w.feeLock.Unlock()
+
+	w.txTypes[chainID.Uint64()] = txType
+	return nil
+}
+
+// TxType returns the transaction envelope configured for the chain. If no
+// envelope was configured, false is returned and CreateTransaction picks the
+// envelope of the registry chain when given a ChainClient, or else a
+// dynamic-fee transaction on chains reporting a base fee and a legacy one
+// otherwise.
+func (w *SoftwareWallet) TxType(chainID *big.Int) (uint8, bool, error) {
+	if chainID == nil {
+		return 0, false, errors.New("chain id is required")
+	}
+
+	w.feeLock.RLock()
+	defer w.feeLock.RUnlock()
+
+	txType, ok := w.txTypes[chainID.Uint64()]
+	return txType, ok, nil
+}
+
+// SetBaseFeeMultiplier sets the multiplier applied to the latest base fee when
+// computing the fee cap of dynamic-fee transactions.
+func (w *SoftwareWallet) SetBaseFeeMultiplier(multiplier float64) error {
+	if multiplier < 1 {
+		return errors.New("base fee multiplier must be at least 1")
+	}
+
+	w.feeLock.Lock()
+	defer w.feeLock.Unlock()
+
+	w.baseFeeMultiplier = multiplier
+	return nil
+}
+
+// ExportKeystoreV3 exports the private key of the account as a Web3 Secret
+// Storage (keystore v3) JSON document encrypted with the passphrase.
+func (w *SoftwareWallet) ExportKeystoreV3(
+	account accounts.Account,
+	passphrase string,
+) ([]byte, error) {
+	imp, ok := w.WalletImp.(keystoreImp)
+	if !ok {
+		return nil, ErrKeystoreUnsupported
+	}
+	return imp.ExportKeystoreV3(account, passphrase)
+}
+
+// ImportKeystoreV3 imports the key of a Web3 Secret Storage (keystore v3) JSON
+// document as a standalone account of the wallet.
+func (w *SoftwareWallet) ImportKeystoreV3(
+	keyjson []byte,
+	passphrase string,
+) (accounts.Account, error) {
+	imp, ok := w.WalletImp.(keystoreImp)
+	if !ok {
+		return accounts.Account{}, ErrKeystoreUnsupported
+	}
+	return imp.ImportKeystoreV3(keyjson, passphrase)
+}
+
+// NonceManager returns the nonce manager that issues the nonces of the
+// transactions created by the wallet.
+func (w *SoftwareWallet) NonceManager() *NonceManager {
+	w.nonceLock.RLock()
+	defer w.nonceLock.RUnlock()
+
+	return w.nonces
+}
+
+// SetNonceManager replaces the nonce manager of the wallet, e.g. with one that
+// persists its state to disk.
+func (w *SoftwareWallet) SetNonceManager(nonces *NonceManager) {
+	w.nonceLock.Lock()
+	defer w.nonceLock.Unlock()
+
+	w.nonces = nonces
+}
+
+// SetGasLimitMargin sets the safety margin, in percent, added on top of the
+// estimated gas limit of transactions.
+func (w *SoftwareWallet) SetGasLimitMargin(percent uint64) {
+	w.feeLock.Lock()
+	defer w.feeLock.Unlock()
+
+	w.gasLimitMargin = percent
+}
+
+// CreateTransaction builds an unsigned transaction transferring value to the
+// toAddress. The transaction envelope is selected per chain, see SetTxType.
+// If gassLimit is zero, the gas limit is estimated. The nonce is issued by the
+// nonce manager of the wallet. If the transaction is not sent, or gets
+// dropped, its nonce should be released to the manager.
 func (w *SoftwareWallet) CreateTransaction(
 	ctx context.Context,
-	client *ethclient.Client,
+	client Backend,
+	account accounts.Account,
+	toAddress common.Address,
+	value *big.Int,
+	gassLimit uint64,
+) (*types.Transaction, error) {
+	return w.CreateTransactionWithData(
+		ctx, client, account, toAddress, value, nil, gassLimit)
+}
+
+// CreateTransactionWithData builds an unsigned transaction calling toAddress
+// with value and data, see CreateTransaction. If gassLimit is zero, the gas
+// limit is estimated with the full call and increased by the gas limit margin.
+// ErrInsufficientFunds is returned when the pending balance of the account
+// cannot cover the value plus the maximum gas cost of the transaction.
+func (w *SoftwareWallet) CreateTransactionWithData(
+	ctx context.Context,
+	client Backend,
 	account accounts.Account,
 	toAddress common.Address,
 	value *big.Int,
+	data []byte,
 	gassLimit uint64,
 ) (*types.Transaction, error) {
-	nonce, err := client.NonceAt(ctx, account.Address, nil)
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := client.HeaderByNumber(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	gassPrice, err := client.SuggestGasPrice(ctx)
+	txType, err := w.selectTxType(client, chainID, head)
 	if err != nil {
 		return nil, err
 	}
 
-	tx := types.NewTransaction(
-		nonce, toAddress, value, gassLimit, gassPrice, []byte{})
-	return tx, nil
+	w.feeLock.RLock()
+	multiplier := w.baseFeeMultiplier
+	margin := w.gasLimitMargin
+	w.feeLock.RUnlock()
+
+	fees, err := suggestFees(ctx, client, txType, head, multiplier)
+	if err != nil {
+		return nil, err
+	}
+
+	if gassLimit == 0 {
+		msg := ethereum.CallMsg{
+			From:      account.Address,
+			To:        &toAddress,
+			GasPrice:  fees.gasPrice,
+			GasTipCap: fees.gasTipCap,
+			GasFeeCap: fees.gasFeeCap,
+			Value:     value,
+			Data:      data,
+		}
+		if gassLimit, err = estimateGas(ctx, client, msg, head, margin); err != nil {
+			return nil, err
+		}
+	}
+
+	balance, err := client.PendingBalanceAt(ctx, account.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFunds(balance, value, gassLimit, fees); err != nil {
+		return nil, err
+	}
+
+	// The nonce is issued last, so failures above do not leave a gap
+	nonce, err := w.NonceManager().Next(ctx, client, chainID, account.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTransaction(
+		txType, chainID, nonce, toAddress, value, gassLimit, fees, data)
+}
+
+// SpeedUpTransaction re-issues the pending transaction of the account with the
+// same nonce, payload and access list, bumping its fees by at least
+// ReplacementFeeBump percent. The replacement is signed with SignTx.
+func (w *SoftwareWallet) SpeedUpTransaction(
+	ctx context.Context,
+	client Backend,
+	account accounts.Account,
+	tx *types.Transaction,
+) (*types.Transaction, error) {
+	return w.replaceTransaction(ctx, client, account, tx,
+		tx.To(), tx.Value(), tx.Gas(), tx.Data(), tx.AccessList())
+}
+
+// CancelTransaction replaces the pending transaction of the account with a
+// zero-value transfer to itself with the same nonce, bumping its fees by at
+// least ReplacementFeeBump percent. The transfer has no access list, so it
+// needs no more than the intrinsic gas of a transfer. The replacement is
+// signed with SignTx.
+func (w *SoftwareWallet) CancelTransaction(
+	ctx context.Context,
+	client Backend,
+	account accounts.Account,
+	tx *types.Transaction,
+) (*types.Transaction, error) {
+	return w.replaceTransaction(ctx, client, account, tx,
+		&account.Address, new(big.Int), params.TxGas, nil, nil)
+}
+
+// replaceTransaction builds and signs a transaction replacing the pending
+// transaction.
+func (w *SoftwareWallet) replaceTransaction(
+	ctx context.Context,
+	client Backend,
+	account accounts.Account,
+	tx *types.Transaction,
+	toAddress *common.Address,
+	value *big.Int,
+	gasLimit uint64,
+	data []byte,
+	accessList types.AccessList,
+) (*types.Transaction, error) {
+	if _, r, _ := tx.RawSignatureValues(); r != nil && r.Sign() != 0 {
+		sender, err := txSender(tx)
+		if err != nil {
+			return nil, err
+		}
+		if sender != account.Address {
+			return nil, fmt.Errorf(
+				"transaction sent by %s, not %s",
+				sender.Hex(), account.Address.Hex())
+		}
+	}
+
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	w.feeLock.RLock()
+	multiplier := w.baseFeeMultiplier
+	w.feeLock.RUnlock()
+
+	fees, err := replacementFees(ctx, client, tx, head, multiplier)
+	if err != nil {
+		return nil, err
+	}
+
+	replacement, err := newReplacement(
+		tx, chainID, toAddress, value, gasLimit, fees, data, accessList)
+	if err != nil {
+		return nil, err
+	}
+	return w.SignTx(account, replacement, chainID)
+}
+
+// selectTxType returns the configured transaction type of the chain. Without
+// one, the type of the registry chain is used when the client is a
+// ChainClient, otherwise a dynamic-fee transaction is built when the chain
+// head carries a base fee.
+func (w *SoftwareWallet) selectTxType(
+	client Backend,
+	chainID *big.Int,
+	head *types.Header,
+) (uint8, error) {
+	txType, ok, err := w.TxType(chainID)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return txType, nil
+	}
+	if chainClient, ok := client.(*ChainClient); ok && chainClient.Chain != nil {
+		return chainClient.Chain.TxType(), nil
+	}
+	if head.BaseFee != nil {
+		return types.DynamicFeeTxType, nil
+	}
+	return types.LegacyTxType, nil
 }
@@ -0,0 +1,214 @@
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/hdwallet"
+)
+
+func newTestWallet(t *testing.T) (Wallet, accounts.Account) {
+	wallet, err := NewSoftwareWalletFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := wallet.Derive(
+		hdwallet.DefaultCoinPath(hdwallet.CoinTypeETH, 0, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet, account
+}
+
+// newPendingTx returns a signed transaction of the type with the fees of the
+// backend, paying 1 wei with data and the access list.
+func newPendingTx(
+	t *testing.T,
+	wallet Wallet,
+	account accounts.Account,
+	client *fakeBackend,
+	txType uint8,
+	accessList types.AccessList,
+) *types.Transaction {
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	var tx *types.Transaction
+	switch txType {
+	case types.LegacyTxType:
+		tx = types.NewTx(&types.LegacyTx{
+			Nonce:    client.nonce,
+			GasPrice: client.gasPrice,
+			Gas:      50000,
+			To:       &to,
+			Value:    big.NewInt(1),
+			Data:     []byte{1, 2, 3},
+		})
+	case types.DynamicFeeTxType:
+		tx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:    client.chainID,
+			Nonce:      client.nonce,
+			GasTipCap:  client.gasTipCap,
+			GasFeeCap:  big.NewInt(3 * gwei),
+			Gas:        50000,
+			To:         &to,
+			Value:      big.NewInt(1),
+			Data:       []byte{1, 2, 3},
+			AccessList: accessList,
+		})
+	}
+	signed, err := wallet.SignTx(account, tx, client.chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestSpeedUpTransaction(t *testing.T) {
+	wallet, account := newTestWallet(t)
+	accessList := types.AccessList{{
+		Address:     common.HexToAddress("0x000000000000000000000000000000000000bEEF"),
+		StorageKeys: []common.Hash{{1}},
+	}}
+
+	tests := []struct {
+		name       string
+		txType     uint8
+		accessList types.AccessList
+		// tip is the suggested tip, gasPrice the fee of legacy transactions
+		tip       int64
+		gasPrice  *big.Int
+		gasTipCap *big.Int
+		gasFeeCap *big.Int
+	}{
+		{
+			name:     "legacy fee bump",
+			txType:   types.LegacyTxType,
+			tip:      gwei,
+			gasPrice: big.NewInt(2.2 * gwei),
+		},
+		{
+			name:      "dynamic fee bump",
+			txType:    types.DynamicFeeTxType,
+			tip:       gwei,
+			gasTipCap: big.NewInt(1.1 * gwei),
+			gasFeeCap: big.NewInt(3.3 * gwei),
+		},
+		{
+			name:      "suggested fees above the bump",
+			txType:    types.DynamicFeeTxType,
+			tip:       5 * gwei,
+			gasTipCap: big.NewInt(5 * gwei),
+			gasFeeCap: big.NewInt(7 * gwei),
+		},
+		{
+			name:       "access list",
+			txType:     types.DynamicFeeTxType,
+			accessList: accessList,
+			tip:        gwei,
+			gasTipCap:  big.NewInt(1.1 * gwei),
+			gasFeeCap:  big.NewInt(3.3 * gwei),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeBackend()
+			client.nonce = 7
+			tx := newPendingTx(
+				t, wallet, account, client, test.txType, test.accessList)
+
+			client.gasTipCap = big.NewInt(test.tip)
+			replacement, err := wallet.SpeedUpTransaction(
+				context.Background(), client, account, tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if replacement.Type() != tx.Type() || replacement.Nonce() != tx.Nonce() ||
+				*replacement.To() != *tx.To() ||
+				replacement.Value().Cmp(tx.Value()) != 0 ||
+				string(replacement.Data()) != string(tx.Data()) ||
+				replacement.Gas() != tx.Gas() ||
+				len(replacement.AccessList()) != len(test.accessList) {
+				t.Fatal("replacement differs from the pending transaction")
+			}
+			if test.gasPrice != nil &&
+				replacement.GasPrice().Cmp(test.gasPrice) != 0 {
+				t.Fatalf("gas price %v, want %v",
+					replacement.GasPrice(), test.gasPrice)
+			}
+			if test.gasTipCap != nil &&
+				(replacement.GasTipCap().Cmp(test.gasTipCap) != 0 ||
+					replacement.GasFeeCap().Cmp(test.gasFeeCap) != 0) {
+				t.Fatalf("tip %v and fee cap %v, want %v and %v",
+					replacement.GasTipCap(), replacement.GasFeeCap(),
+					test.gasTipCap, test.gasFeeCap)
+			}
+		})
+	}
+}
+
+func TestCancelTransaction(t *testing.T) {
+	wallet, account := newTestWallet(t)
+	accessList := types.AccessList{{
+		Address:     common.HexToAddress("0x000000000000000000000000000000000000bEEF"),
+		StorageKeys: []common.Hash{{1}, {2}},
+	}}
+
+	for _, test := range []struct {
+		name       string
+		txType     uint8
+		accessList types.AccessList
+	}{
+		{"legacy", types.LegacyTxType, nil},
+		{"dynamic fee", types.DynamicFeeTxType, nil},
+		{"access list", types.DynamicFeeTxType, accessList},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeBackend()
+			client.nonce = 7
+			tx := newPendingTx(
+				t, wallet, account, client, test.txType, test.accessList)
+
+			cancel, err := wallet.CancelTransaction(
+				context.Background(), client, account, tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cancel.Nonce() != tx.Nonce() {
+				t.Fatalf("nonce %d, want %d", cancel.Nonce(), tx.Nonce())
+			}
+			if *cancel.To() != account.Address || cancel.Value().Sign() != 0 ||
+				len(cancel.Data()) != 0 {
+				t.Fatal("cancel is not a zero-value transfer to the account")
+			}
+			if len(cancel.AccessList()) != 0 {
+				t.Fatal("cancel keeps the access list")
+			}
+			intrinsic, err := core.IntrinsicGas(cancel.Data(),
+				cancel.AccessList(), false, true, true, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cancel.Gas() != params.TxGas || cancel.Gas() < intrinsic {
+				t.Fatalf("gas %d, intrinsic gas %d", cancel.Gas(), intrinsic)
+			}
+
+			// every fee is bumped by 10%
+			if tx.Type() == types.LegacyTxType {
+				if cancel.GasPrice().Cmp(big.NewInt(2.2*gwei)) != 0 {
+					t.Fatalf("gas price %v, want 2.2 gwei", cancel.GasPrice())
+				}
+			} else if cancel.GasTipCap().Cmp(big.NewInt(1.1*gwei)) != 0 ||
+				cancel.GasFeeCap().Cmp(big.NewInt(3.3*gwei)) != 0 {
+				t.Fatalf("tip %v and fee cap %v, want 1.1 and 3.3 gwei",
+					cancel.GasTipCap(), cancel.GasFeeCap())
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// DefaultTxPollInterval is the interval at which a tracker polls the node
+	// for the state of a transaction by default.
+	DefaultTxPollInterval = 4 * time.Second
+	// DefaultTxDropTimeout is the time a transaction can be unknown to the
+	// node before a tracker considers it dropped by default.
+	DefaultTxDropTimeout = 10 * time.Minute
+)
+
+// TxState is the state of a tracked transaction.
+type TxState int
+
+const (
+	// TxPending means the transaction is not included in a block yet.
+	TxPending TxState = iota
+	// TxIncluded means the transaction is included in a block, but does not
+	// have the required number of confirmations yet.
+	TxIncluded
+	// TxConfirmed means the transaction succeeded and has the required
+	// number of confirmations.
+	TxConfirmed
+	// TxFailed means the transaction reverted and has the required number of
+	// confirmations.
+	TxFailed
+	// TxDropped means the node no longer knows the transaction and its nonce
+	// is still unused.
+	TxDropped
+	// TxReplaced means another transaction with the same nonce was included.
+	TxReplaced
+)
+
+// String returns the name of the state.
+func (s TxState) String() string {
+	switch s {
+	case TxPending:
+		return "pending"
+	case TxIncluded:
+		return "included"
+	case TxConfirmed:
+		return "confirmed"
+	case TxFailed:
+		return "failed"
+	case TxDropped:
+		return "dropped"
+	case TxReplaced:
+		return "replaced"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// Final returns whether the tracker stops tracking a transaction in the state.
+func (s TxState) Final() bool {
+	switch s {
+	case TxConfirmed, TxFailed, TxDropped, TxReplaced:
+		return true
+	}
+	return false
+}
+
+// TxStatus is a state transition of a tracked transaction.
+type TxStatus struct {
+	State TxState
+	Hash  common.Hash
+	// Receipt is set when the transaction is included.
+	Receipt *types.Receipt
+	// Confirmations is the number of blocks on top of, and including, the
+	// block of the transaction.
+	Confirmations uint64
+	// Reorged is set when the block the transaction was included in is no
+	// longer part of the canonical chain.
+	Reorged bool
+}
+
+// TxTrackerBackend is the part of a client a tracker needs to follow the state
+// of transactions.
+type TxTrackerBackend interface {
+	ethereum.TransactionReader
+
+	HeaderByNumber(context.Context, *big.Int) (*types.Header, error)
+	NonceAt(context.Context, common.Address, *big.Int) (uint64, error)
+}
+
+// headSubscriber is implemented by backends that push new chain heads, which
+// makes the tracker check transactions as soon as a block arrives.
+type headSubscriber interface {
+	SubscribeNewHead(
+		context.Context,
+		chan<- *types.Header,
+	) (ethereum.Subscription, error)
+}
+
+// TxTracker follows sent transactions until they are confirmed, failed,
+// dropped or replaced.
+type TxTracker struct {
+	backend       TxTrackerBackend
+	confirmations uint64
+	pollInterval  time.Duration
+	dropTimeout   time.Duration
+}
+
+// NewTxTracker creates a tracker that considers transactions confirmed once
+// their block has the given number of confirmations.
+func NewTxTracker(
+	backend TxTrackerBackend,
+	confirmations uint64,
+) *TxTracker {
+	if confirmations == 0 {
+		confirmations = 1
+	}
+	return &TxTracker{
+		backend:       backend,
+		confirmations: confirmations,
+		pollInterval:  DefaultTxPollInterval,
+		dropTimeout:   DefaultTxDropTimeout,
+	}
+}
+
+// SetPollInterval sets the interval at which the node is polled.
+func (t *TxTracker) SetPollInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("poll interval must be positive")
+	}
+
+	t.pollInterval = interval
+	return nil
+}
+
+// SetDropTimeout sets the time a transaction can be unknown to the node before
+// it is considered dropped.
+func (t *TxTracker) SetDropTimeout(timeout time.Duration) {
+	t.dropTimeout = timeout
+}
+
+// Track follows the signed transaction and emits its state transitions on the
+// returned channel, starting with TxPending. The channel is closed after a
+// final state or when the context is done. The block of an included
+// transaction is re-checked on every poll, and a transaction whose block got
+// reorged out is reported again with Reorged set.
+func (t *TxTracker) Track(
+	ctx context.Context,
+	tx *types.Transaction,
+) (<-chan TxStatus, error) {
+	from, err := txSender(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(chan TxStatus, 8)
+	go t.run(ctx, tx, from, updates)
+	return updates, nil
+}
+
+// run polls the state of the transaction until it is final or the context is
+// done.
+func (t *TxTracker) run(
+	ctx context.Context,
+	tx *types.Transaction,
+	from common.Address,
+	updates chan<- TxStatus,
+) {
+	defer close(updates)
+
+	var heads chan *types.Header
+	if subscriber, ok := t.backend.(headSubscriber); ok {
+		heads = make(chan *types.Header, 1)
+		sub, err := subscriber.SubscribeNewHead(ctx, heads)
+		if err != nil {
+			heads = nil
+		} else {
+			defer sub.Unsubscribe()
+		}
+	}
+
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+
+	current := TxStatus{State: TxPending, Hash: tx.Hash()}
+	if !sendStatus(ctx, updates, current) {
+		return
+	}
+
+	lastSeen := time.Now()
+	for {
+		status, err := t.check(ctx, tx, from, current, &lastSeen)
+		// Errors of the backend are transient, the next poll retries
+		if err == nil && statusChanged(current, status) {
+			if !sendStatus(ctx, updates, status) {
+				return
+			}
+			current = status
+		}
+		if current.State.Final() {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-heads:
+		}
+	}
+}
+
+// check returns the current status of the transaction. lastSeen holds the last
+// time the node knew the transaction.
+func (t *TxTracker) check(
+	ctx context.Context,
+	tx *types.Transaction,
+	from common.Address,
+	current TxStatus,
+	lastSeen *time.Time,
+) (TxStatus, error) {
+	hash := tx.Hash()
+	wasIncluded := current.Receipt != nil
+
+	receipt, err := t.backend.TransactionReceipt(ctx, hash)
+	if err != nil && !errors.Is(err, ethereum.NotFound) {
+		return TxStatus{}, err
+	}
+	if err == nil && receipt != nil && receipt.BlockNumber != nil {
+		canonical, err := t.backend.HeaderByNumber(ctx, receipt.BlockNumber)
+		if err != nil {
+			return TxStatus{}, err
+		}
+
+		if canonical.Hash() == receipt.BlockHash {
+			*lastSeen = time.Now()
+			return t.includedStatus(ctx, hash, receipt, current)
+		}
+		// The node still returns the receipt of a block that got reorged
+		// out, the transaction is not included anymore.
+	}
+
+	status := TxStatus{
+		State:   TxPending,
+		Hash:    hash,
+		Reorged: wasIncluded || current.Reorged,
+	}
+
+	nonce, err := t.backend.NonceAt(ctx, from, nil)
+	if err != nil {
+		return TxStatus{}, err
+	}
+
+	_, _, err = t.backend.TransactionByHash(ctx, hash)
+	if err != nil && !errors.Is(err, ethereum.NotFound) {
+		return TxStatus{}, err
+	}
+	known := err == nil
+
+	if nonce > tx.Nonce() {
+		// The nonce is used. If the node knows the transaction, its receipt
+		// is not indexed yet, otherwise another transaction took the nonce.
+		if !known {
+			status.State = TxReplaced
+		}
+		return status, nil
+	}
+
+	if known {
+		*lastSeen = time.Now()
+	} else if time.Since(*lastSeen) > t.dropTimeout {
+		status.State = TxDropped
+	}
+	return status, nil
+}
+
+// includedStatus returns the status of the transaction included with the
+// receipt.
+func (t *TxTracker) includedStatus(
+	ctx context.Context,
+	hash common.Hash,
+	receipt *types.Receipt,
+	current TxStatus,
+) (TxStatus, error) {
+	head, err := t.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return TxStatus{}, err
+	}
+
+	var confirmations uint64
+	if head.Number.Cmp(receipt.BlockNumber) >= 0 {
+		confirmations = new(big.Int).Sub(
+			head.Number, receipt.BlockNumber).Uint64() + 1
+	}
+
+	status := TxStatus{
+		State:         TxIncluded,
+		Hash:          hash,
+		Receipt:       receipt,
+		Confirmations: confirmations,
+		Reorged: current.Reorged || (current.Receipt != nil &&
+			current.Receipt.BlockHash != receipt.BlockHash),
+	}
+	if confirmations >= t.confirmations {
+		status.State = TxConfirmed
+		if receipt.Status == types.ReceiptStatusFailed {
+			status.State = TxFailed
+		}
+	}
+	return status, nil
+}
+
+// statusChanged returns whether the status is a transition from the current
+// status.
+func statusChanged(current TxStatus, status TxStatus) bool {
+	if current.State != status.State {
+		return true
+	}
+	if current.Receipt == nil || status.Receipt == nil {
+		return current.Receipt != status.Receipt
+	}
+	return current.Receipt.BlockHash != status.Receipt.BlockHash
+}
+
+// sendStatus emits the status, returning false when the context is done.
+func sendStatus(
+	ctx context.Context,
+	updates chan<- TxStatus,
+	status TxStatus,
+) bool {
+	select {
+	case updates <- status:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// txSender returns the sender of the signed transaction.
+func txSender(tx *types.Transaction) (common.Address, error) {
+	var chainID *big.Int
+	if tx.Protected() {
+		chainID = tx.ChainId()
+	}
+	return types.Sender(types.LatestSignerForChainID(chainID), tx)
+}
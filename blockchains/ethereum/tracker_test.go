@@ -0,0 +1,314 @@
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeTrackerBackend is a TxTrackerBackend for a single transaction, whose
+// chain state the tests change while it is tracked.
+type fakeTrackerBackend struct {
+	lock    sync.Mutex
+	tx      *types.Transaction
+	known   bool
+	receipt *types.Receipt
+	blocks  map[uint64]*types.Header
+	head    uint64
+	nonce   uint64
+}
+
+func newFakeTrackerBackend(tx *types.Transaction) *fakeTrackerBackend {
+	b := &fakeTrackerBackend{
+		tx:     tx,
+		known:  true,
+		blocks: map[uint64]*types.Header{},
+	}
+	b.setHead(10)
+	return b
+}
+
+// setHead extends the canonical chain up to the block number.
+func (b *fakeTrackerBackend) setHead(number uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for n := b.head + 1; n <= number; n++ {
+		b.blocks[n] = &types.Header{Number: new(big.Int).SetUint64(n)}
+	}
+	b.head = number
+}
+
+// include includes the transaction in the canonical block with the status.
+func (b *fakeTrackerBackend) include(number uint64, status uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.receipt = &types.Receipt{
+		Status:      status,
+		TxHash:      b.tx.Hash(),
+		BlockHash:   b.blocks[number].Hash(),
+		BlockNumber: new(big.Int).SetUint64(number),
+	}
+	b.nonce = b.tx.Nonce() + 1
+}
+
+// reorg replaces the canonical block, the node keeps the receipt of the block
+// that got reorged out.
+func (b *fakeTrackerBackend) reorg(number uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.blocks[number] = &types.Header{
+		Number: new(big.Int).SetUint64(number),
+		Extra:  []byte("reorg"),
+	}
+	b.nonce = b.tx.Nonce()
+}
+
+func (b *fakeTrackerBackend) TransactionByHash(
+	ctx context.Context,
+	hash common.Hash,
+) (*types.Transaction, bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.known || hash != b.tx.Hash() {
+		return nil, false, ethereum.NotFound
+	}
+	return b.tx, b.receipt == nil, nil
+}
+
+func (b *fakeTrackerBackend) TransactionReceipt(
+	ctx context.Context,
+	hash common.Hash,
+) (*types.Receipt, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.receipt == nil || hash != b.tx.Hash() {
+		return nil, ethereum.NotFound
+	}
+	return b.receipt, nil
+}
+
+func (b *fakeTrackerBackend) HeaderByNumber(
+	ctx context.Context,
+	number *big.Int,
+) (*types.Header, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if number == nil {
+		return b.blocks[b.head], nil
+	}
+	header, ok := b.blocks[number.Uint64()]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return header, nil
+}
+
+func (b *fakeTrackerBackend) NonceAt(
+	ctx context.Context,
+	account common.Address,
+	number *big.Int,
+) (uint64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.nonce, nil
+}
+
+// newTrackedTx returns a signed transaction with the nonce 3.
+func newTrackedTx(t *testing.T) *types.Transaction {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)),
+		&types.DynamicFeeTx{
+			ChainID:   big.NewInt(1),
+			Nonce:     3,
+			GasTipCap: big.NewInt(gwei),
+			GasFeeCap: big.NewInt(3 * gwei),
+			Gas:       21000,
+			To:        &to,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// startTracker tracks the transaction of the backend, polling every
+// millisecond.
+func startTracker(
+	t *testing.T,
+	backend *fakeTrackerBackend,
+	confirmations uint64,
+	dropTimeout time.Duration,
+) <-chan TxStatus {
+	tracker := NewTxTracker(backend, confirmations)
+	if err := tracker.SetPollInterval(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	tracker.SetDropTimeout(dropTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	updates, err := tracker.Track(ctx, backend.tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return updates
+}
+
+// nextStatus returns the next status emitted by the tracker.
+func nextStatus(t *testing.T, updates <-chan TxStatus) TxStatus {
+	t.Helper()
+
+	select {
+	case status, ok := <-updates:
+		if !ok {
+			t.Fatal("tracker stopped")
+		}
+		return status
+	case <-time.After(5 * time.Second):
+		t.Fatal("no status from the tracker")
+	}
+	return TxStatus{}
+}
+
+// expectState fails unless the next status has the state, confirmations and
+// reorg flag.
+func expectState(
+	t *testing.T,
+	updates <-chan TxStatus,
+	state TxState,
+	confirmations uint64,
+	reorged bool,
+) TxStatus {
+	t.Helper()
+
+	status := nextStatus(t, updates)
+	if status.State != state || status.Confirmations != confirmations ||
+		status.Reorged != reorged {
+		t.Fatalf("status %v with %d confirmations, reorged %v, want %v with "+
+			"%d, reorged %v", status.State, status.Confirmations,
+			status.Reorged, state, confirmations, reorged)
+	}
+	return status
+}
+
+// expectClosed fails unless the tracker stops after its last status.
+func expectClosed(t *testing.T, updates <-chan TxStatus) {
+	t.Helper()
+
+	select {
+	case status, ok := <-updates:
+		if ok {
+			t.Fatalf("status %v after a final state", status.State)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tracker did not stop")
+	}
+}
+
+func TestTxTrackerConfirmations(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		status uint64
+		final  TxState
+	}{
+		{"successful", types.ReceiptStatusSuccessful, TxConfirmed},
+		{"reverted", types.ReceiptStatusFailed, TxFailed},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			backend := newFakeTrackerBackend(newTrackedTx(t))
+			updates := startTracker(t, backend, 3, time.Minute)
+			expectState(t, updates, TxPending, 0, false)
+
+			backend.include(10, test.status)
+			status := expectState(t, updates, TxIncluded, 1, false)
+			if status.Receipt == nil || status.Receipt.BlockNumber.Uint64() != 10 {
+				t.Fatal("included status without the receipt")
+			}
+
+			// one confirmation short of the threshold is no transition
+			backend.setHead(11)
+			backend.setHead(12)
+			expectState(t, updates, test.final, 3, false)
+			expectClosed(t, updates)
+		})
+	}
+}
+
+func TestTxTrackerReorg(t *testing.T) {
+	backend := newFakeTrackerBackend(newTrackedTx(t))
+	updates := startTracker(t, backend, 2, time.Minute)
+	expectState(t, updates, TxPending, 0, false)
+
+	backend.include(10, types.ReceiptStatusSuccessful)
+	expectState(t, updates, TxIncluded, 1, false)
+
+	backend.reorg(10)
+	expectState(t, updates, TxPending, 0, true)
+
+	backend.setHead(11)
+	backend.include(11, types.ReceiptStatusSuccessful)
+	expectState(t, updates, TxIncluded, 1, true)
+
+	backend.setHead(12)
+	expectState(t, updates, TxConfirmed, 2, true)
+	expectClosed(t, updates)
+}
+
+func TestTxTrackerDropped(t *testing.T) {
+	backend := newFakeTrackerBackend(newTrackedTx(t))
+	backend.known = false
+	updates := startTracker(t, backend, 1, 50*time.Millisecond)
+
+	start := time.Now()
+	expectState(t, updates, TxPending, 0, false)
+	expectState(t, updates, TxDropped, 0, false)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("dropped after %v, before the timeout", elapsed)
+	}
+	expectClosed(t, updates)
+}
+
+func TestTxTrackerReplaced(t *testing.T) {
+	backend := newFakeTrackerBackend(newTrackedTx(t))
+	updates := startTracker(t, backend, 1, time.Minute)
+	expectState(t, updates, TxPending, 0, false)
+
+	// another transaction took the nonce
+	backend.lock.Lock()
+	backend.known = false
+	backend.nonce = backend.tx.Nonce() + 1
+	backend.lock.Unlock()
+	expectState(t, updates, TxReplaced, 0, false)
+	expectClosed(t, updates)
+}
+
+func TestTxTrackerSetPollInterval(t *testing.T) {
+	tracker := NewTxTracker(newFakeTrackerBackend(newTrackedTx(t)), 1)
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if err := tracker.SetPollInterval(interval); err == nil {
+			t.Fatalf("SetPollInterval accepted %v", interval)
+		}
+	}
+	if tracker.pollInterval != DefaultTxPollInterval {
+		t.Fatalf("poll interval %v, want %v",
+			tracker.pollInterval, DefaultTxPollInterval)
+	}
+}
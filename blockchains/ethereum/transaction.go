@@ -0,0 +1,300 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultBaseFeeMultiplier is the multiplier applied to the latest base fee
+// when computing the fee cap of a dynamic-fee transaction. A multiplier of 2
+// keeps the transaction executable through six consecutive full blocks.
+const DefaultBaseFeeMultiplier = 2.0
+
+// ReplacementFeeBump is the minimum increase, in percent, of the fees of a
+// transaction replacing a pending one with the same nonce. Nodes reject
+// replacements with a lower increase.
+const ReplacementFeeBump = 10
+
+// DefaultGasLimitMargin is the safety margin, in percent, added on top of the
+// estimated gas limit of a transaction.
+const DefaultGasLimitMargin = 20
+
+var (
+	// ErrDynamicFeeUnsupported is returned when a dynamic-fee transaction is
+	// requested on a chain whose blocks do not carry a base fee.
+	ErrDynamicFeeUnsupported = errors.New(
+		"chain does not support dynamic fee transactions")
+	// ErrInsufficientFunds is returned when the balance of an account cannot
+	// cover the value and the maximum gas cost of a transaction.
+	ErrInsufficientFunds = errors.New("insufficient funds for gas * price + value")
+)
+
+// txFees holds the fee fields of a transaction. For legacy and access-list
+// transactions only gasPrice is set, for dynamic-fee transactions only
+// gasTipCap and gasFeeCap are set.
+type txFees struct {
+	gasPrice  *big.Int
+	gasTipCap *big.Int
+	gasFeeCap *big.Int
+}
+
+// suggestFees queries the client for the fees of a transaction of the given
+// type.
+func suggestFees(
+	ctx context.Context,
+	client Backend,
+	txType uint8,
+	head *types.Header,
+	baseFeeMultiplier float64,
+) (txFees, error) {
+	switch txType {
+	case types.LegacyTxType, types.AccessListTxType:
+		gasPrice, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			return txFees{}, err
+		}
+		return txFees{gasPrice: gasPrice}, nil
+
+	case types.DynamicFeeTxType:
+		if head.BaseFee == nil {
+			return txFees{}, ErrDynamicFeeUnsupported
+		}
+
+		gasTipCap, err := client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return txFees{}, err
+		}
+
+		return txFees{
+			gasTipCap: gasTipCap,
+			gasFeeCap: new(big.Int).Add(
+				gasTipCap, mulBigFloat(head.BaseFee, baseFeeMultiplier)),
+		}, nil
+
+	default:
+		return txFees{}, fmt.Errorf("unsupported transaction type %d", txType)
+	}
+}
+
+// replacementFees returns the fees of a transaction replacing the pending
+// transaction. Every fee is bumped by at least ReplacementFeeBump percent, and
+// raised to the currently suggested fee if that is higher.
+func replacementFees(
+	ctx context.Context,
+	client Backend,
+	tx *types.Transaction,
+	head *types.Header,
+	baseFeeMultiplier float64,
+) (txFees, error) {
+	suggested, err := suggestFees(ctx, client, tx.Type(), head, baseFeeMultiplier)
+	if err != nil {
+		return txFees{}, err
+	}
+
+	if tx.Type() != types.DynamicFeeTxType {
+		return txFees{
+			gasPrice: maxBig(bumpFee(tx.GasPrice()), suggested.gasPrice),
+		}, nil
+	}
+
+	fees := txFees{
+		gasTipCap: maxBig(bumpFee(tx.GasTipCap()), suggested.gasTipCap),
+		gasFeeCap: maxBig(bumpFee(tx.GasFeeCap()), suggested.gasFeeCap),
+	}
+	fees.gasFeeCap = maxBig(fees.gasFeeCap, fees.gasTipCap)
+	return fees, nil
+}
+
+// bumpFee returns the fee increased by ReplacementFeeBump percent, rounding
+// up.
+func bumpFee(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+ReplacementFeeBump))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+// maxBig returns the larger of x and y.
+func maxBig(x *big.Int, y *big.Int) *big.Int {
+	if x.Cmp(y) < 0 {
+		return y
+	}
+	return x
+}
+
+// estimateGas estimates the gas limit of the call and adds the margin, in
+// percent, without exceeding the gas limit of the block.
+func estimateGas(
+	ctx context.Context,
+	client ethereum.GasEstimator,
+	msg ethereum.CallMsg,
+	head *types.Header,
+	margin uint64,
+) (uint64, error) {
+	gasLimit, err := client.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, err
+	}
+
+	gasLimit += gasLimit * margin / 100
+	if head.GasLimit > 0 && gasLimit > head.GasLimit {
+		gasLimit = head.GasLimit
+	}
+	return gasLimit, nil
+}
+
+// maxCost returns the maximum amount of wei a transaction with the value, gas
+// limit and fees can cost.
+func maxCost(value *big.Int, gasLimit uint64, fees txFees) *big.Int {
+	price := fees.gasPrice
+	if price == nil {
+		price = fees.gasFeeCap
+	}
+
+	cost := new(big.Int).Mul(price, new(big.Int).SetUint64(gasLimit))
+	return cost.Add(cost, value)
+}
+
+// checkFunds returns ErrInsufficientFunds when the balance cannot cover the
+// maximum cost of the transaction.
+func checkFunds(
+	balance *big.Int,
+	value *big.Int,
+	gasLimit uint64,
+	fees txFees,
+) error {
+	cost := maxCost(value, gasLimit, fees)
+	if balance.Cmp(cost) < 0 {
+		return fmt.Errorf(
+			"%w: balance %v, cost %v", ErrInsufficientFunds, balance, cost)
+	}
+	return nil
+}
+
+// newTransaction builds an unsigned transaction of the given type.
+func newTransaction(
+	txType uint8,
+	chainID *big.Int,
+	nonce uint64,
+	toAddress common.Address,
+	value *big.Int,
+	gasLimit uint64,
+	fees txFees,
+	data []byte,
+) (*types.Transaction, error) {
+	switch txType {
+	case types.LegacyTxType:
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: fees.gasPrice,
+			Gas:      gasLimit,
+			To:       &toAddress,
+			Value:    value,
+			Data:     data,
+		}), nil
+
+	case types.AccessListTxType:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:  chainID,
+			Nonce:    nonce,
+			GasPrice: fees.gasPrice,
+			Gas:      gasLimit,
+			To:       &toAddress,
+			Value:    value,
+			Data:     data,
+		}), nil
+
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			GasTipCap: fees.gasTipCap,
+			GasFeeCap: fees.gasFeeCap,
+			Gas:       gasLimit,
+			To:        &toAddress,
+			Value:     value,
+			Data:      data,
+		}), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", txType)
+	}
+}
+
+// newReplacement builds an unsigned transaction of the same type and with the
+// same nonce as the pending transaction. The access list is ignored for legacy
+// transactions.
+func newReplacement(
+	tx *types.Transaction,
+	chainID *big.Int,
+	toAddress *common.Address,
+	value *big.Int,
+	gasLimit uint64,
+	fees txFees,
+	data []byte,
+	accessList types.AccessList,
+) (*types.Transaction, error) {
+	switch tx.Type() {
+	case types.LegacyTxType:
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: fees.gasPrice,
+			Gas:      gasLimit,
+			To:       toAddress,
+			Value:    value,
+			Data:     data,
+		}), nil
+
+	case types.AccessListTxType:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    chainID,
+			Nonce:      tx.Nonce(),
+			GasPrice:   fees.gasPrice,
+			Gas:        gasLimit,
+			To:         toAddress,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}), nil
+
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    chainID,
+			Nonce:      tx.Nonce(),
+			GasTipCap:  fees.gasTipCap,
+			GasFeeCap:  fees.gasFeeCap,
+			Gas:        gasLimit,
+			To:         toAddress,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", tx.Type())
+	}
+}
+
+// validTxType returns whether the transaction type can be built by the wallet.
+func validTxType(txType uint8) bool {
+	switch txType {
+	case types.LegacyTxType, types.AccessListTxType, types.DynamicFeeTxType:
+		return true
+	}
+	return false
+}
+
+// mulBigFloat multiplies x with the floating point factor f, rounding down.
+func mulBigFloat(x *big.Int, f float64) *big.Int {
+	product := new(big.Float).SetInt(x)
+	product.Mul(product, big.NewFloat(f))
+
+	result := new(big.Int)
+	product.Int(result)
+	return result
+}
@@ -0,0 +1,258 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// fakeBackend is a Backend answering with fixed chain state.
+type fakeBackend struct {
+	chainID   *big.Int
+	head      *types.Header
+	gasPrice  *big.Int
+	gasTipCap *big.Int
+	gas       uint64
+	balance   *big.Int
+	nonce     uint64
+
+	// estimated is the last call passed to EstimateGas.
+	estimated ethereum.CallMsg
+}
+
+// newFakeBackend returns a backend of a London chain with a base fee of 1
+// gwei, suggesting a gas price of 2 gwei and a tip of 1 gwei.
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		chainID: big.NewInt(1),
+		head: &types.Header{
+			Number:   big.NewInt(1),
+			GasLimit: 30000000,
+			BaseFee:  big.NewInt(gwei),
+		},
+		gasPrice:  big.NewInt(2 * gwei),
+		gasTipCap: big.NewInt(gwei),
+		gas:       21000,
+		balance:   big.NewInt(1e18),
+	}
+}
+
+func (b *fakeBackend) ChainID(ctx context.Context) (*big.Int, error) {
+	return b.chainID, nil
+}
+
+func (b *fakeBackend) HeaderByNumber(
+	ctx context.Context,
+	number *big.Int,
+) (*types.Header, error) {
+	return b.head, nil
+}
+
+func (b *fakeBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return b.gasPrice, nil
+}
+
+func (b *fakeBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return b.gasTipCap, nil
+}
+
+func (b *fakeBackend) EstimateGas(
+	ctx context.Context,
+	msg ethereum.CallMsg,
+) (uint64, error) {
+	b.estimated = msg
+	return b.gas, nil
+}
+
+func (b *fakeBackend) PendingBalanceAt(
+	ctx context.Context,
+	account common.Address,
+) (*big.Int, error) {
+	return b.balance, nil
+}
+
+func (b *fakeBackend) PendingStorageAt(
+	ctx context.Context,
+	account common.Address,
+	key common.Hash,
+) ([]byte, error) {
+	return nil, nil
+}
+
+func (b *fakeBackend) PendingCodeAt(
+	ctx context.Context,
+	account common.Address,
+) ([]byte, error) {
+	return nil, nil
+}
+
+func (b *fakeBackend) PendingNonceAt(
+	ctx context.Context,
+	account common.Address,
+) (uint64, error) {
+	return b.nonce, nil
+}
+
+func (b *fakeBackend) PendingTransactionCount(
+	ctx context.Context,
+) (uint, error) {
+	return 0, nil
+}
+
+func TestSuggestFees(t *testing.T) {
+	tests := []struct {
+		name       string
+		txType     uint8
+		baseFee    *big.Int
+		multiplier float64
+		gasPrice   *big.Int
+		gasTipCap  *big.Int
+		gasFeeCap  *big.Int
+		err        error
+	}{
+		{
+			name:       "legacy",
+			txType:     types.LegacyTxType,
+			baseFee:    big.NewInt(gwei),
+			multiplier: 2,
+			gasPrice:   big.NewInt(2 * gwei),
+		},
+		{
+			name:       "access list",
+			txType:     types.AccessListTxType,
+			baseFee:    big.NewInt(gwei),
+			multiplier: 2,
+			gasPrice:   big.NewInt(2 * gwei),
+		},
+		{
+			name:       "dynamic fee",
+			txType:     types.DynamicFeeTxType,
+			baseFee:    big.NewInt(gwei),
+			multiplier: 2,
+			gasTipCap:  big.NewInt(gwei),
+			gasFeeCap:  big.NewInt(3 * gwei),
+		},
+		{
+			name:       "fractional multiplier",
+			txType:     types.DynamicFeeTxType,
+			baseFee:    big.NewInt(10 * gwei),
+			multiplier: 1.25,
+			gasTipCap:  big.NewInt(gwei),
+			gasFeeCap:  big.NewInt(13.5 * gwei),
+		},
+		{
+			name:       "no base fee",
+			txType:     types.DynamicFeeTxType,
+			multiplier: 2,
+			err:        ErrDynamicFeeUnsupported,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeBackend()
+			client.head.BaseFee = test.baseFee
+
+			fees, err := suggestFees(context.Background(), client, test.txType,
+				client.head, test.multiplier)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("suggestFees returned %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, fee := range []struct {
+				name      string
+				got, want *big.Int
+			}{
+				{"gas price", fees.gasPrice, test.gasPrice},
+				{"tip", fees.gasTipCap, test.gasTipCap},
+				{"fee cap", fees.gasFeeCap, test.gasFeeCap},
+			} {
+				if (fee.got == nil) != (fee.want == nil) ||
+					fee.got != nil && fee.got.Cmp(fee.want) != 0 {
+					t.Fatalf("%s %v, want %v", fee.name, fee.got, fee.want)
+				}
+			}
+		})
+	}
+
+	client := newFakeBackend()
+	if _, err := suggestFees(context.Background(), client, types.BlobTxType,
+		client.head, 2); err == nil {
+		t.Fatal("suggestFees succeeded for a blob transaction")
+	}
+}
+
+func TestEstimateGas(t *testing.T) {
+	tests := []struct {
+		name      string
+		gas       uint64
+		margin    uint64
+		blockGas  uint64
+		wantLimit uint64
+	}{
+		{"no margin", 21000, 0, 30000000, 21000},
+		{"margin", 21000, 20, 30000000, 25200},
+		{"capped at the block gas limit", 28000000, 20, 30000000, 30000000},
+		{"no block gas limit", 28000000, 20, 0, 33600000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeBackend()
+			client.gas = test.gas
+			client.head.GasLimit = test.blockGas
+			msg := ethereum.CallMsg{Value: big.NewInt(1)}
+
+			gasLimit, err := estimateGas(
+				context.Background(), client, msg, client.head, test.margin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gasLimit != test.wantLimit {
+				t.Fatalf("gas limit %d, want %d", gasLimit, test.wantLimit)
+			}
+			if client.estimated.Value.Cmp(msg.Value) != 0 {
+				t.Fatal("estimated call differs from the message")
+			}
+		})
+	}
+}
+
+func TestCheckFunds(t *testing.T) {
+	legacy := txFees{gasPrice: big.NewInt(2 * gwei)}
+	dynamic := txFees{
+		gasTipCap: big.NewInt(gwei),
+		gasFeeCap: big.NewInt(3 * gwei),
+	}
+
+	tests := []struct {
+		name    string
+		balance *big.Int
+		fees    txFees
+		err     error
+	}{
+		{"legacy exact balance", big.NewInt(21000*2*gwei + 100), legacy, nil},
+		{"legacy short balance", big.NewInt(21000*2*gwei + 99), legacy,
+			ErrInsufficientFunds},
+		{"dynamic exact balance", big.NewInt(21000*3*gwei + 100), dynamic, nil},
+		{"dynamic short balance", big.NewInt(21000*3*gwei + 99), dynamic,
+			ErrInsufficientFunds},
+		{"empty balance", new(big.Int), dynamic, ErrInsufficientFunds},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkFunds(test.balance, big.NewInt(100), 21000, test.fees)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("checkFunds returned %v, want %v", err, test.err)
+			}
+		})
+	}
+}
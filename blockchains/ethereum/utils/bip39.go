@@ -1,17 +1,22 @@
 package utils
 
 import (
-	"strings"
-
-	"github.com/tyler-smith/go-bip39/wordlists"
+	"github.com/solsticewallet/solstice-core/bip39"
 )
 
-func Bip39SuggestWords(v string) []string {
-	var res []string
-	for _, word := range wordlists.English {
-		if strings.HasPrefix(word, v) {
-			res = append(res, word)
-		}
-	}
-	return res
+// Bip39SuggestWords returns the BIP-39 words starting with v in the language,
+// English if none is given. Accented input is NFKD normalized before matching.
+func Bip39SuggestWords(v string, langOpt ...bip39.Language) []string {
+	return bip39.SuggestWords(v, langOpt...)
+}
+
+// Bip39NearestWords returns up to count BIP-39 words nearest to v in the
+// language, English if none is given: the words Bip39SuggestWords returns for
+// v first, then the others by edit distance.
+func Bip39NearestWords(
+	v string,
+	count int,
+	langOpt ...bip39.Language,
+) []string {
+	return bip39.NearestWords(v, count, langOpt...)
 }
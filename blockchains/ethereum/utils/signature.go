@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+var (
+	// ErrInvalidSignature is returned when a signature is malformed and no
+	// signer can be recovered from it.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrSignerMismatch is returned when a valid signature was made by another
+	// address than the expected one.
+	ErrSignerMismatch = errors.New("signer mismatch")
+)
+
+// RecoverTextSigner returns the address that signed the text following EIP-191
+// (personal_sign), as produced by SignText. The V of the signature can be
+// either 0/1 or 27/28.
+func RecoverTextSigner(text []byte, sig []byte) (common.Address, error) {
+	return recoverSigner(accounts.TextHash(text), sig)
+}
+
+// VerifyText checks that the text was signed by the address following EIP-191
+// (personal_sign). It returns an error wrapping ErrInvalidSignature when the
+// signature is malformed and ErrSignerMismatch when it was made by another
+// address.
+func VerifyText(text []byte, sig []byte, address common.Address) error {
+	return verifySigner(accounts.TextHash(text), sig, address)
+}
+
+// RecoverTypedDataSigner returns the address that signed the EIP-712 typed
+// data. The V of the signature can be either 0/1 or 27/28.
+func RecoverTypedDataSigner(
+	typedData apitypes.TypedData,
+	sig []byte,
+) (common.Address, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return recoverSigner(hash, sig)
+}
+
+// VerifyTypedData checks that the EIP-712 typed data was signed by the
+// address. It returns an error wrapping ErrInvalidSignature when the signature
+// is malformed and ErrSignerMismatch when it was made by another address.
+func VerifyTypedData(
+	typedData apitypes.TypedData,
+	sig []byte,
+	address common.Address,
+) error {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return err
+	}
+	return verifySigner(hash, sig, address)
+}
+
+// verifySigner checks that the hash was signed by the address.
+func verifySigner(hash []byte, sig []byte, address common.Address) error {
+	signer, err := recoverSigner(hash, sig)
+	if err != nil {
+		return err
+	}
+	if signer != address {
+		return fmt.Errorf(
+			"%w: expected %s, got %s",
+			ErrSignerMismatch, address.Hex(), signer.Hex())
+	}
+	return nil
+}
+
+// recoverSigner returns the address that signed the hash, accepting a V of
+// either 0/1 or 27/28.
+func recoverSigner(hash []byte, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf(
+			"%w: length %d, expected %d",
+			ErrInvalidSignature, len(sig), crypto.SignatureLength)
+	}
+
+	sig = append([]byte{}, sig...)
+	switch v := sig[crypto.RecoveryIDOffset]; v {
+	case 0, 1:
+	case 27, 28:
+		sig[crypto.RecoveryIDOffset] -= 27
+	default:
+		return common.Address{}, fmt.Errorf(
+			"%w: recovery id %d", ErrInvalidSignature, v)
+	}
+
+	publicKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
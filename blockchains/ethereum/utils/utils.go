@@ -4,9 +4,10 @@ import (
 	"crypto/rand"
 	"errors"
 	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/tyler-smith/go-bip39"
+	"github.com/solsticewallet/solstice-core/bip39"
 )
 
 // ParseDerivationPath parses the derivation path in string format into
@@ -28,18 +29,26 @@ func MustParseDerivationPath(path string) accounts.DerivationPath {
 // NewMnemonic returns a randomly generated BIP-39 mnemonic using 128-256 bits
 // of entropy.
 // bitSize has to be a multiple 32 and be within the inclusive range of
-// {128, 256}
-func NewMnemonic(bits int) (string, error) {
+// {128, 256}. The wordlist is English unless a language is given.
+func NewMnemonic(bits int, langOpt ...bip39.Language) (string, error) {
 	entropy, err := bip39.NewEntropy(bits)
 	if err != nil {
 		return "", err
 	}
-	return bip39.NewMnemonic(entropy)
+	return NewMnemonicFromEntropy(entropy, langOpt...)
 }
 
-// NewMnemonicFromEntropy returns a BIP-39 menomonic from entropy.
-func NewMnemonicFromEntropy(entropy []byte) (string, error) {
-	return bip39.NewMnemonic(entropy)
+// NewMnemonicFromEntropy returns a BIP-39 menomonic from entropy in the
+// language, English if none is given.
+func NewMnemonicFromEntropy(
+	entropy []byte,
+	langOpt ...bip39.Language,
+) (string, error) {
+	lang := bip39.English
+	if len(langOpt) > 0 {
+		lang = langOpt[0]
+	}
+	return bip39.NewMnemonic(entropy, lang)
 }
 
 // NewEntropy returns a randomly generated entropy.
@@ -54,7 +63,8 @@ func NewSeed() ([]byte, error) {
 	return b, err
 }
 
-// NewSeedFromMnemonic returns a BIP-39 seed based on a BIP-39 mnemonic.
+// NewSeedFromMnemonic returns a BIP-39 seed based on a BIP-39 mnemonic in any
+// of the supported languages, seeded as its wordlist spells it.
 func NewSeedFromMnemonic(mnemonic string, passOpt ...string) ([]byte, error) {
 	if mnemonic == "" {
 		return nil, errors.New("mnemonic is required")
@@ -119,3 +129,35 @@ func Eth2Wei(eth *big.Float) *big.Int {
 	fwei.Int(result)
 	return result
 }
+
+// Units2Decimal converts an amount in the smallest units of a token with the
+// given number of decimals to its decimal representation.
+func Units2Decimal(amount *big.Int, decimals uint8) *big.Float {
+	if amount == nil {
+		return nil
+	}
+	value := new(big.Float).SetPrec(256).SetInt(amount)
+	return value.Quo(value, decimalsFactor(decimals))
+}
+
+// Decimal2Units converts a decimal amount of a token with the given number of
+// decimals to its smallest units, rounding any excess precision.
+func Decimal2Units(amount *big.Float, decimals uint8) *big.Int {
+	if amount == nil {
+		return nil
+	}
+	// Going through the decimal text representation avoids binary rounding
+	// errors, e.g. 1.1 becoming 1099999 units of a 6 decimals token.
+	text := strings.Replace(amount.Text('f', int(decimals)), ".", "", 1)
+	result, ok := new(big.Int).SetString(text, 10)
+	if !ok {
+		return nil
+	}
+	return result
+}
+
+// decimalsFactor returns 10^decimals.
+func decimalsFactor(decimals uint8) *big.Float {
+	factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	return new(big.Float).SetPrec(256).SetInt(factor)
+}
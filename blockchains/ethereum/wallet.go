@@ -3,21 +3,31 @@ package ethereum
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/solsticewallet/solstice-core/blockchains/ethereum/hdwallet"
 )
 
+// ErrKeystoreUnsupported is returned when the wallet implementation cannot be
+// persisted in a keystore file.
+var ErrKeystoreUnsupported = errors.New("wallet does not support keystores")
+
 type WalletImp interface {
 	accounts.Wallet
 
 	SignHash(accounts.Account, []byte) ([]byte, error)
 	SignHashWithPassphrase(accounts.Account, string, []byte) ([]byte, error)
+	SignTypedData(accounts.Account, apitypes.TypedData) ([]byte, error)
+	SignTypedDataWithPassphrase(accounts.Account, string, apitypes.TypedData) ([]byte, error)
 
 	Unpin(accounts.Account) error
+	Lock()
 
 	PrivateKey(accounts.Account) (*ecdsa.PrivateKey, error)
 	PrivateKeyBytes(accounts.Account) ([]byte, error)
@@ -32,14 +42,56 @@ type WalletImp interface {
 	AddressHex(accounts.Account) (string, error)
 
 	Path(accounts.Account) (string, error)
+
+	ExtendedPublicKey(accounts.DerivationPath) (string, error)
+}
+
+// keystoreImp is implemented by wallet implementations whose secrets can be
+// persisted in encrypted keystore files.
+type keystoreImp interface {
+	Save(string, string, ...hdwallet.KDF) error
+	ChangePassphrase(string, string) error
+
+	ExportKeystoreV3(accounts.Account, string) ([]byte, error)
+	ImportKeystoreV3([]byte, string) (accounts.Account, error)
+}
+
+// Backend is the part of an Ethereum client the wallet needs to build
+// transactions. It is implemented by *ethclient.Client as well as by the
+// simulated backend, and can be wrapped to add caching or failover.
+type Backend interface {
+	ethereum.ChainIDReader
+	ethereum.GasPricer
+	ethereum.GasPricer1559
+	ethereum.GasEstimator
+	ethereum.PendingStateReader
+
+	HeaderByNumber(context.Context, *big.Int) (*types.Header, error)
 }
 
 type Wallet interface {
 	WalletImp
 
-	AccountBalance(context.Context, *ethclient.Client, accounts.Account, *big.Int) (*big.Int, error)
-	AccountBalanceEth(context.Context, *ethclient.Client, accounts.Account, *big.Int) (*big.Float, error)
-	PendingAccountBalance(context.Context, *ethclient.Client, accounts.Account) (*big.Int, error)
-	PendingAccountBallanceEth(context.Context, *ethclient.Client, accounts.Account) (*big.Float, error)
-	CreateTransaction(context.Context, *ethclient.Client, accounts.Account, common.Address, *big.Int, uint64) (*types.Transaction, error)
+	AccountBalance(context.Context, ethereum.ChainStateReader, accounts.Account, *big.Int) (*big.Int, error)
+	AccountBalanceEth(context.Context, ethereum.ChainStateReader, accounts.Account, *big.Int) (*big.Float, error)
+	PendingAccountBalance(context.Context, ethereum.PendingStateReader, accounts.Account) (*big.Int, error)
+	PendingAccountBallanceEth(context.Context, ethereum.PendingStateReader, accounts.Account) (*big.Float, error)
+	CreateTransaction(context.Context, Backend, accounts.Account, common.Address, *big.Int, uint64) (*types.Transaction, error)
+	CreateTransactionWithData(context.Context, Backend, accounts.Account, common.Address, *big.Int, []byte, uint64) (*types.Transaction, error)
+	SpeedUpTransaction(context.Context, Backend, accounts.Account, *types.Transaction) (*types.Transaction, error)
+	CancelTransaction(context.Context, Backend, accounts.Account, *types.Transaction) (*types.Transaction, error)
+
+	SetTxType(*big.Int, uint8) error
+	TxType(*big.Int) (uint8, bool, error)
+	SetBaseFeeMultiplier(float64) error
+	SetGasLimitMargin(uint64)
+
+	NonceManager() *NonceManager
+	SetNonceManager(*NonceManager)
+
+	Save(string, string, ...hdwallet.KDF) error
+	ChangePassphrase(string, string) error
+
+	ExportKeystoreV3(accounts.Account, string) ([]byte, error)
+	ImportKeystoreV3([]byte, string) (accounts.Account, error)
 }
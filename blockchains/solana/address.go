@@ -0,0 +1,46 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+)
+
+// AddressLength is the length of a Solana address, an ed25519 public key.
+const AddressLength = 32
+
+// Address is a Solana account address.
+type Address [AddressLength]byte
+
+// ParseAddress parses a base58 encoded address.
+func ParseAddress(s string) (Address, error) {
+	var address Address
+	decoded := base58.Decode(s)
+	if len(decoded) != AddressLength {
+		return address, fmt.Errorf("invalid solana address %q", s)
+	}
+	copy(address[:], decoded)
+	return address, nil
+}
+
+// AddressFromPublicKey returns the address of the ed25519 public key.
+func AddressFromPublicKey(publicKey ed25519.PublicKey) (Address, error) {
+	var address Address
+	if len(publicKey) != ed25519.PublicKeySize {
+		return address, fmt.Errorf(
+			"invalid ed25519 public key length %d", len(publicKey))
+	}
+	copy(address[:], publicKey)
+	return address, nil
+}
+
+// String returns the base58 encoding of the address.
+func (a Address) String() string {
+	return base58.Encode(a[:])
+}
+
+// PublicKey returns the ed25519 public key of the address.
+func (a Address) PublicKey() ed25519.PublicKey {
+	return append(ed25519.PublicKey{}, a[:]...)
+}
@@ -0,0 +1,117 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+)
+
+// versionPrefix marks the first byte of a versioned message.
+const versionPrefix = 0x80
+
+var (
+	// ErrInvalidTransaction is returned for malformed serialized transactions.
+	ErrInvalidTransaction = errors.New("invalid transaction")
+	// ErrNotSigner is returned when signing a transaction the account is not
+	// a required signer of.
+	ErrNotSigner = errors.New("account is not a signer of the transaction")
+)
+
+// transaction is the part of a serialized transaction needed to sign it: a
+// compact array of signatures followed by the message they sign.
+type transaction struct {
+	// signaturesStart is the offset of the first signature.
+	signaturesStart int
+	numSignatures   int
+	message         []byte
+	// signers are the account keys required to sign the message, in the
+	// order of their signatures.
+	signers []Address
+}
+
+// parseTransaction parses a serialized legacy or version 0 transaction.
+func parseTransaction(tx []byte) (*transaction, error) {
+	numSignatures, n, err := decodeCompactU16(tx)
+	if err != nil {
+		return nil, err
+	}
+	messageStart := n + numSignatures*ed25519.SignatureSize
+	if len(tx) < messageStart {
+		return nil, fmt.Errorf("%w: truncated signatures", ErrInvalidTransaction)
+	}
+
+	parsed := &transaction{
+		signaturesStart: n,
+		numSignatures:   numSignatures,
+		message:         tx[messageStart:],
+	}
+
+	header := parsed.message
+	if len(header) > 0 && header[0]&versionPrefix != 0 {
+		if version := header[0] &^ versionPrefix; version != 0 {
+			return nil, fmt.Errorf(
+				"%w: unsupported message version %d",
+				ErrInvalidTransaction, version)
+		}
+		header = header[1:]
+	}
+	// the header counts the required signatures, then the read-only signed
+	// and unsigned accounts
+	if len(header) < 3 {
+		return nil, fmt.Errorf("%w: truncated message", ErrInvalidTransaction)
+	}
+	numRequired := int(header[0])
+	if numRequired != numSignatures {
+		return nil, fmt.Errorf(
+			"%w: %d signatures for %d required signers",
+			ErrInvalidTransaction, numSignatures, numRequired)
+	}
+
+	numKeys, n, err := decodeCompactU16(header[3:])
+	if err != nil {
+		return nil, err
+	}
+	keys := header[3+n:]
+	if numKeys < numRequired || len(keys) < numKeys*AddressLength {
+		return nil, fmt.Errorf("%w: truncated account keys", ErrInvalidTransaction)
+	}
+	for i := 0; i < numRequired; i++ {
+		var signer Address
+		copy(signer[:], keys[i*AddressLength:])
+		parsed.signers = append(parsed.signers, signer)
+	}
+	return parsed, nil
+}
+
+// signerIndex returns the index of the signature of the address.
+func (t *transaction) signerIndex(address Address) (int, error) {
+	for i, signer := range t.signers {
+		if signer == address {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrNotSigner, address)
+}
+
+// signatureOffset returns the offset of the signature at the index in the
+// serialized transaction.
+func (t *transaction) signatureOffset(index int) int {
+	return t.signaturesStart + index*ed25519.SignatureSize
+}
+
+// decodeCompactU16 decodes a compact-u16 length prefix, returning the value
+// and the number of bytes it takes.
+func decodeCompactU16(b []byte) (int, int, error) {
+	value := 0
+	for i := 0; i < 3; i++ {
+		if i >= len(b) {
+			return 0, 0, fmt.Errorf(
+				"%w: truncated length", ErrInvalidTransaction)
+		}
+		value |= int(b[i]&0x7f) << (7 * i)
+		if b[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: invalid length", ErrInvalidTransaction)
+}
@@ -0,0 +1,274 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"sync"
+
+	"github.com/solsticewallet/solstice-core/bip39"
+	"github.com/solsticewallet/solstice-core/slip10"
+)
+
+// CoinType is the SLIP-44 coin type of Solana.
+const CoinType = 501
+
+var (
+	// ErrUnknownAccount is returned for accounts that are not pinned to the
+	// wallet.
+	ErrUnknownAccount = errors.New("unknown account")
+	// ErrWalletLocked is returned when deriving or signing with a wallet
+	// whose secrets were wiped by Lock.
+	ErrWalletLocked = errors.New("wallet is locked")
+)
+
+// Account is an address pinned to the wallet.
+type Account struct {
+	Address Address
+}
+
+// Wallet is a Solana HD wallet deriving ed25519 keys from a BIP-39 seed
+// following SLIP-10.
+type Wallet struct {
+	mnemonic  string
+	masterKey *slip10.Key
+	seed      []byte
+	paths     map[Address][]uint32
+	accounts  []Account
+	stateLock sync.RWMutex
+}
+
+// newWallet creates a new Wallet using the provided seed.
+func newWallet(seed []byte) (*Wallet, error) {
+	masterKey, err := slip10.NewMasterKey(seed, slip10.Ed25519)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Wallet{
+		masterKey: masterKey,
+		seed:      seed,
+		paths:     map[Address][]uint32{},
+		accounts:  []Account{},
+	}, nil
+}
+
+// NewFromMnemonic returns a new wallet from a BIP-39 mnemonic.
+func NewFromMnemonic(mnemonic string, passOpt ...string) (*Wallet, error) {
+	if mnemonic == "" {
+		return nil, errors.New("mnemonic is required")
+	}
+
+	var password string
+	if len(passOpt) > 0 {
+		password = passOpt[0]
+	}
+
+	canonical, err := bip39.CanonicalMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	seed := bip39.NewSeed(canonical, password)
+
+	wallet, err := newWallet(seed)
+	if err != nil {
+		return nil, err
+	}
+	wallet.mnemonic = canonical
+
+	return wallet, nil
+}
+
+// NewFromSeed returns a new wallet from a BIP-39 seed.
+func NewFromSeed(seed []byte) (*Wallet, error) {
+	if len(seed) == 0 {
+		return nil, errors.New("seed is required")
+	}
+	return newWallet(seed)
+}
+
+// DefaultPath returns the path of the account at the index as derived by
+// Phantom, Solflare and the Solana CLI: m/44'/501'/index'/0'.
+func DefaultPath(index uint32) []uint32 {
+	return []uint32{
+		44 + slip10.HardenedKeyStart,
+		CoinType + slip10.HardenedKeyStart,
+		index + slip10.HardenedKeyStart,
+		0 + slip10.HardenedKeyStart,
+	}
+}
+
+// Lock wipes the mnemonic, the seed and the master key from memory. The
+// pinned accounts can still be listed, but no longer derived or signed for.
+func (w *Wallet) Lock() {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	zeroBytes(w.seed)
+	if w.masterKey != nil {
+		w.masterKey.Zero()
+	}
+	w.mnemonic = ""
+	w.seed = nil
+	w.masterKey = nil
+}
+
+// Accounts returns the accounts pinned to the wallet.
+func (w *Wallet) Accounts() []Account {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	cpy := make([]Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains returns whether the account is pinned to the wallet.
+func (w *Wallet) Contains(account Account) bool {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	_, ok := w.paths[account.Address]
+	return ok
+}
+
+// Derive derives the account at the path and, if pin is set, pins it to the
+// wallet. Every index of the path must be hardened.
+func (w *Wallet) Derive(path []uint32, pin bool) (Account, error) {
+	key, err := func() (*slip10.Key, error) {
+		w.stateLock.RLock()
+		defer w.stateLock.RUnlock()
+
+		if w.masterKey == nil {
+			return nil, ErrWalletLocked
+		}
+		return w.masterKey.DerivePath(path)
+	}()
+	if err != nil {
+		return Account{}, err
+	}
+	defer key.Zero()
+
+	address, err := AddressFromPublicKey(key.Ed25519PublicKey())
+	if err != nil {
+		return Account{}, err
+	}
+	account := Account{Address: address}
+
+	if !pin {
+		return account, nil
+	}
+
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	if _, ok := w.paths[address]; !ok {
+		w.accounts = append(w.accounts, account)
+		w.paths[address] = append([]uint32{}, path...)
+	}
+	return account, nil
+}
+
+// Unpin removes the account from the pinned accounts of the wallet.
+func (w *Wallet) Unpin(account Account) error {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	if _, ok := w.paths[account.Address]; !ok {
+		return ErrUnknownAccount
+	}
+	delete(w.paths, account.Address)
+
+	for i, acct := range w.accounts {
+		if acct.Address == account.Address {
+			w.accounts = append(w.accounts[:i], w.accounts[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Path returns the derivation path of the account.
+func (w *Wallet) Path(account Account) (string, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return "", ErrUnknownAccount
+	}
+	return slip10.FormatPath(path), nil
+}
+
+// PrivateKey returns the ed25519 private key of the account, whose 64 bytes
+// are the keypair format of the Solana CLI.
+func (w *Wallet) PrivateKey(account Account) (ed25519.PrivateKey, error) {
+	w.stateLock.RLock()
+	defer w.stateLock.RUnlock()
+
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, ErrUnknownAccount
+	}
+	if w.masterKey == nil {
+		return nil, ErrWalletLocked
+	}
+
+	key, err := w.masterKey.DerivePath(path)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zero()
+
+	return key.Ed25519PrivateKey(), nil
+}
+
+// PublicKey returns the ed25519 public key of the account.
+func (w *Wallet) PublicKey(account Account) (ed25519.PublicKey, error) {
+	if !w.Contains(account) {
+		return nil, ErrUnknownAccount
+	}
+	return account.Address.PublicKey(), nil
+}
+
+// SignMessage signs the message with the key of the account, as the
+// signMessage method of Solana wallet adapters does.
+func (w *Wallet) SignMessage(account Account, message []byte) ([]byte, error) {
+	privateKey, err := w.PrivateKey(account)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(privateKey)
+
+	return ed25519.Sign(privateKey, message), nil
+}
+
+// SignTransaction signs the serialized legacy or versioned transaction with the
+// key of the account and returns the transaction with the signature in the slot
+// of the account. Signatures of other signers are kept.
+func (w *Wallet) SignTransaction(account Account, tx []byte) ([]byte, error) {
+	parsed, err := parseTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	slot, err := parsed.signerIndex(account.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := w.SignMessage(account, parsed.message)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := append([]byte{}, tx...)
+	copy(signed[parsed.signatureOffset(slot):], signature)
+	return signed, nil
+}
+
+// zeroBytes overwrites the bytes with zeros.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
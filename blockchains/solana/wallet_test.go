@@ -0,0 +1,143 @@
+package solana
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon " +
+	"abandon abandon abandon abandon abandon about"
+
+func newTestAccount(t *testing.T) (*Wallet, Account) {
+	w, err := NewFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := w.Derive(DefaultPath(0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, account
+}
+
+func TestDeriveAddress(t *testing.T) {
+	w, account := newTestAccount(t)
+
+	// the first account of Phantom and Solflare for the mnemonic
+	const want = "HAgk14JpMQLgt6rVgv7cBQFJWFto5Dqxi472uT3DKpqk"
+	if account.Address.String() != want {
+		t.Fatalf("address %s, want %s", account.Address, want)
+	}
+	parsed, err := ParseAddress(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != account.Address {
+		t.Fatalf("parsed address %s, want %s", parsed, account.Address)
+	}
+
+	path, err := w.Path(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "m/44'/501'/0'/0'" {
+		t.Fatalf("path %s, want m/44'/501'/0'/0'", path)
+	}
+}
+
+func TestSignMessage(t *testing.T) {
+	w, account := newTestAccount(t)
+
+	message := []byte("hello solana")
+	signature, err := w.SignMessage(account, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(account.Address.PublicKey(), message, signature) {
+		t.Fatal("invalid signature")
+	}
+
+	other, err := w.Derive(DefaultPath(1), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.SignMessage(other, message); !errors.Is(err, ErrUnknownAccount) {
+		t.Fatalf("SignMessage returned %v, want %v", err, ErrUnknownAccount)
+	}
+}
+
+// testTransaction returns a legacy transaction with two empty signature slots
+// for the signers, whose message lists a program account after them.
+func testTransaction(signers ...Address) []byte {
+	message := []byte{byte(len(signers)), 0, 1, byte(len(signers) + 1)}
+	for _, signer := range signers {
+		message = append(message, signer[:]...)
+	}
+	message = append(message, make([]byte, AddressLength)...) // program
+	message = append(message, make([]byte, 32)...)            // blockhash
+	message = append(message, 0)                              // instructions
+
+	tx := []byte{byte(len(signers))}
+	tx = append(tx, make([]byte, len(signers)*ed25519.SignatureSize)...)
+	return append(tx, message...)
+}
+
+func TestSignTransaction(t *testing.T) {
+	w, account := newTestAccount(t)
+	feePayer := Address{1}
+
+	tx := testTransaction(feePayer, account.Address)
+	signed, err := w.SignTransaction(account, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signed) != len(tx) {
+		t.Fatalf("signed transaction of %d bytes, want %d", len(signed), len(tx))
+	}
+
+	message := tx[1+2*ed25519.SignatureSize:]
+	if !bytes.Equal(signed[1:1+ed25519.SignatureSize],
+		make([]byte, ed25519.SignatureSize)) {
+		t.Fatal("signature slot of the fee payer was written")
+	}
+	signature := signed[1+ed25519.SignatureSize : 1+2*ed25519.SignatureSize]
+	if !ed25519.Verify(account.Address.PublicKey(), message, signature) {
+		t.Fatal("invalid signature in the slot of the account")
+	}
+
+	_, err = w.SignTransaction(account, testTransaction(feePayer))
+	if !errors.Is(err, ErrNotSigner) {
+		t.Fatalf("SignTransaction returned %v, want %v", err, ErrNotSigner)
+	}
+	_, err = w.SignTransaction(account, tx[:len(tx)-40])
+	if !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("SignTransaction returned %v, want %v",
+			err, ErrInvalidTransaction)
+	}
+}
+
+func TestLock(t *testing.T) {
+	w, account := newTestAccount(t)
+	seed := w.seed
+
+	w.Lock()
+	if !bytes.Equal(seed, make([]byte, len(seed))) {
+		t.Fatal("seed was not zeroed")
+	}
+	if _, err := w.SignMessage(account, []byte("hello")); !errors.Is(
+		err, ErrWalletLocked) {
+		t.Fatalf("SignMessage returned %v, want %v", err, ErrWalletLocked)
+	}
+	if _, err := w.Derive(DefaultPath(1), false); !errors.Is(
+		err, ErrWalletLocked) {
+		t.Fatalf("Derive returned %v, want %v", err, ErrWalletLocked)
+	}
+
+	// pinned accounts are still listed
+	if accounts := w.Accounts(); len(accounts) != 1 ||
+		accounts[0] != account {
+		t.Fatalf("accounts %v, want %v", accounts, account)
+	}
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/solsticewallet/solstice-core/blockchains/ethereum"
 	"github.com/solsticewallet/solstice-core/blockchains/ethereum/utils"
 )
@@ -40,7 +39,25 @@ const (
 )
 
 func main() {
-	client, err := ethclient.Dial("http://127.0.0.1:7545")
+	registry := ethereum.NewRegistry()
+	err := registry.Add(&ethereum.Chain{
+		ID:            1337,
+		Key:           "ganache",
+		Name:          "Ganache",
+		Currency:      ethereum.Currency{Symbol: "ETH", Decimals: 18},
+		RPC:           []string{"http://127.0.0.1:7545"},
+		Confirmations: 1,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	chain, err := registry.ChainByKey("ganache")
+	if err != nil {
+		panic(err)
+	}
+
+	client, err := chain.Dial(context.Background())
 	if err != nil {
 		panic(err)
 	}
@@ -87,26 +104,32 @@ func main() {
 		account,
 		common.HexToAddress(addr1),
 		utils.Eth2Wei(big.NewFloat(1.0)),
-		uint64(21000),
+		0, // estimate the gas limit
 	)
 	if err != nil {
 		panic(err)
 	}
 
-	chainId, err := client.ChainID(context.Background())
+	tx, err = wallet.SignTx(account, tx, chain.BigID())
 	if err != nil {
 		panic(err)
 	}
 
-	tx, err = wallet.SignTx(account, tx, chainId)
+	err = client.SendTransaction(context.Background(), tx)
 	if err != nil {
 		panic(err)
 	}
 
-	err = client.SendTransaction(context.Background(), tx)
+	fmt.Println("Transaction send", chain.TxURL(tx.Hash()))
+
+	updates, err := ethereum.NewTxTracker(client, chain.Confirmations).Track(
+		context.Background(),
+		tx,
+	)
 	if err != nil {
 		panic(err)
 	}
-
-	fmt.Println("Transaction send")
+	for status := range updates {
+		fmt.Println("Transaction", status.State)
+	}
 }
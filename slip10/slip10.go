@@ -0,0 +1,324 @@
+// Package slip10 implements SLIP-10, the universal private key derivation from
+// a master seed, for the ed25519 and NIST P-256 curves.
+package slip10
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// HardenedKeyStart is the index of the first hardened child key.
+const HardenedKeyStart uint32 = 0x80000000
+
+var (
+	// ErrHardenedOnly is returned when deriving a normal child key on a curve
+	// that only supports hardened derivation, like ed25519.
+	ErrHardenedOnly = errors.New("curve only supports hardened derivation")
+	// ErrInvalidSeed is returned for seeds shorter than 128 or longer than
+	// 512 bits.
+	ErrInvalidSeed = errors.New("seed must be between 16 and 64 bytes")
+)
+
+// Curve is an elliptic curve keys are derived for.
+type Curve int
+
+const (
+	// Ed25519 derives ed25519 keys, hardened only.
+	Ed25519 Curve = iota
+	// P256 derives NIST P-256 (secp256r1) keys.
+	P256
+)
+
+// String returns the name of the curve.
+func (c Curve) String() string {
+	switch c {
+	case Ed25519:
+		return "ed25519"
+	case P256:
+		return "nist256p1"
+	}
+	return fmt.Sprintf("unknown(%d)", int(c))
+}
+
+// seedKey returns the HMAC key the master key of the curve is derived with.
+func (c Curve) seedKey() ([]byte, error) {
+	switch c {
+	case Ed25519:
+		return []byte("ed25519 seed"), nil
+	case P256:
+		return []byte("Nist256p1 seed"), nil
+	}
+	return nil, fmt.Errorf("unsupported curve %v", c)
+}
+
+// Key is an extended private key of a curve.
+type Key struct {
+	curve     Curve
+	key       []byte
+	chainCode []byte
+	depth     uint8
+	index     uint32
+}
+
+// NewMasterKey returns the master key of the curve for the seed.
+func NewMasterKey(seed []byte, curve Curve) (*Key, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, ErrInvalidSeed
+	}
+	seedKey, err := curve.seedKey()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := hmacSHA512(seedKey, seed)
+	// Weierstrass curves retry with the output until it is a valid key.
+	for curve != Ed25519 && !validScalar(sum[:32]) {
+		sum = hmacSHA512(seedKey, sum)
+	}
+
+	return &Key{
+		curve:     curve,
+		key:       sum[:32],
+		chainCode: sum[32:],
+	}, nil
+}
+
+// DeriveForPath returns the key of the curve at the path for the seed.
+func DeriveForPath(seed []byte, curve Curve, path []uint32) (*Key, error) {
+	master, err := NewMasterKey(seed, curve)
+	if err != nil {
+		return nil, err
+	}
+	defer master.Zero()
+
+	return master.DerivePath(path)
+}
+
+// Curve returns the curve of the key.
+func (k *Key) Curve() Curve {
+	return k.curve
+}
+
+// Depth returns the number of derivations from the master key.
+func (k *Key) Depth() uint8 {
+	return k.depth
+}
+
+// Index returns the child index the key was derived at.
+func (k *Key) Index() uint32 {
+	return k.index
+}
+
+// Derive returns the child key at the index. Indexes from HardenedKeyStart are
+// hardened.
+func (k *Key) Derive(index uint32) (*Key, error) {
+	hardened := index >= HardenedKeyStart
+	if !hardened && k.curve == Ed25519 {
+		return nil, ErrHardenedOnly
+	}
+
+	data := make([]byte, 0, 37)
+	if hardened {
+		data = append(data, 0x00)
+		data = append(data, k.key...)
+	} else {
+		publicKey, err := k.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, publicKey...)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	for {
+		sum := hmacSHA512(k.chainCode, data)
+		if k.curve == Ed25519 {
+			return k.child(sum[:32], sum[32:], index), nil
+		}
+
+		// k_i = IL + k_par (mod n), retried with 0x01 || IR || index if IL
+		// is not below n or the child key is zero
+		if validScalar(sum[:32]) {
+			n := elliptic.P256().Params().N
+			childKey := new(big.Int).SetBytes(sum[:32])
+			childKey.Add(childKey, new(big.Int).SetBytes(k.key))
+			childKey.Mod(childKey, n)
+			if childKey.Sign() != 0 {
+				return k.child(childKey.FillBytes(make([]byte, 32)),
+					sum[32:], index), nil
+			}
+		}
+		data = append([]byte{0x01}, sum[32:]...)
+		data = binary.BigEndian.AppendUint32(data, index)
+	}
+}
+
+// DerivePath derives the key at the path relative to the key.
+func (k *Key) DerivePath(path []uint32) (*Key, error) {
+	key := k
+	for _, index := range path {
+		child, err := key.Derive(index)
+		if key != k {
+			key.Zero()
+		}
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	if key == k {
+		return nil, errors.New("empty derivation path")
+	}
+	return key, nil
+}
+
+// PrivateKey returns a copy of the 32-byte private key, the seed of an ed25519
+// key or the scalar of a P-256 key.
+func (k *Key) PrivateKey() []byte {
+	return append([]byte{}, k.key...)
+}
+
+// ChainCode returns a copy of the chain code of the key.
+func (k *Key) ChainCode() []byte {
+	return append([]byte{}, k.chainCode...)
+}
+
+// PublicKey returns the public key as serialized by SLIP-10: 0x00 followed by
+// the 32-byte ed25519 public key, or the compressed P-256 point.
+func (k *Key) PublicKey() ([]byte, error) {
+	switch k.curve {
+	case Ed25519:
+		return append([]byte{0x00}, k.Ed25519PublicKey()...), nil
+	case P256:
+		privateKey, err := k.ECDSAPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		return elliptic.MarshalCompressed(
+			privateKey.Curve, privateKey.X, privateKey.Y), nil
+	}
+	return nil, fmt.Errorf("unsupported curve %v", k.curve)
+}
+
+// Ed25519PrivateKey returns the ed25519 private key of an ed25519 key.
+func (k *Key) Ed25519PrivateKey() ed25519.PrivateKey {
+	if k.curve != Ed25519 {
+		return nil
+	}
+	return ed25519.NewKeyFromSeed(k.key)
+}
+
+// Ed25519PublicKey returns the ed25519 public key of an ed25519 key.
+func (k *Key) Ed25519PublicKey() ed25519.PublicKey {
+	privateKey := k.Ed25519PrivateKey()
+	if privateKey == nil {
+		return nil
+	}
+	defer zeroBytes(privateKey)
+
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	return append(ed25519.PublicKey{}, publicKey...)
+}
+
+// ECDSAPrivateKey returns the ECDSA private key of a P-256 key.
+func (k *Key) ECDSAPrivateKey() (*ecdsa.PrivateKey, error) {
+	if k.curve != P256 {
+		return nil, fmt.Errorf("%v keys are not ecdsa keys", k.curve)
+	}
+
+	curve := elliptic.P256()
+	privateKey := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(k.key)}
+	privateKey.Curve = curve
+	privateKey.X, privateKey.Y = curve.ScalarBaseMult(k.key)
+	return privateKey, nil
+}
+
+// Zero overwrites the private key and chain code with zeros.
+func (k *Key) Zero() {
+	zeroBytes(k.key)
+	zeroBytes(k.chainCode)
+}
+
+// child returns the child key with the key and chain code.
+func (k *Key) child(key []byte, chainCode []byte, index uint32) *Key {
+	return &Key{
+		curve:     k.curve,
+		key:       append([]byte{}, key...),
+		chainCode: append([]byte{}, chainCode...),
+		depth:     k.depth + 1,
+		index:     index,
+	}
+}
+
+// ParsePath parses a derivation path like m/44'/501'/0'/0'. Hardened indexes
+// are marked with ' or h.
+func ParsePath(path string) ([]uint32, error) {
+	elems := strings.Split(strings.TrimSpace(path), "/")
+	if len(elems) == 0 || elems[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", path)
+	}
+
+	parsed := make([]uint32, 0, len(elems)-1)
+	for _, elem := range elems[1:] {
+		hardened := strings.HasSuffix(elem, "'") || strings.HasSuffix(elem, "h")
+		if hardened {
+			elem = elem[:len(elem)-1]
+		}
+
+		index, err := strconv.ParseUint(elem, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid derivation path element %q", elem)
+		}
+		if hardened {
+			index += uint64(HardenedKeyStart)
+		}
+		parsed = append(parsed, uint32(index))
+	}
+	return parsed, nil
+}
+
+// FormatPath formats the derivation path in the m/44'/501'/0'/0' format.
+func FormatPath(path []uint32) string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, index := range path {
+		b.WriteString("/")
+		if index >= HardenedKeyStart {
+			b.WriteString(strconv.FormatUint(
+				uint64(index-HardenedKeyStart), 10) + "'")
+		} else {
+			b.WriteString(strconv.FormatUint(uint64(index), 10))
+		}
+	}
+	return b.String()
+}
+
+// validScalar returns whether the big-endian number is a valid P-256 private
+// key, non-zero and below the order of the curve.
+func validScalar(b []byte) bool {
+	scalar := new(big.Int).SetBytes(b)
+	return scalar.Sign() != 0 && scalar.Cmp(elliptic.P256().Params().N) < 0
+}
+
+// hmacSHA512 returns the HMAC-SHA512 of the data.
+func hmacSHA512(key []byte, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// zeroBytes overwrites the bytes with zeros.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,266 @@
+package slip39
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// secretIndex and digestIndex are the x coordinates of the shared secret
+	// and of its digest.
+	secretIndex = 255
+	digestIndex = 254
+
+	digestLength = 4
+
+	// baseIterationCount is the number of PBKDF2 iterations of the cipher
+	// for an iteration exponent of zero, spread over its rounds.
+	baseIterationCount = 10000
+	roundCount         = 4
+)
+
+// ErrInvalidDigest is returned when the shares do not recover a secret
+// matching its digest, e.g. when shares of different secrets are combined.
+var ErrInvalidDigest = errors.New("invalid digest of the shared secret")
+
+// expTable and logTable are the exponents and logarithms of GF(256) with the
+// Rijndael polynomial x^8 + x^4 + x^3 + x + 1, for the generator x + 1.
+var expTable, logTable = func() ([255]byte, [256]byte) {
+	var exp [255]byte
+	var log [256]byte
+
+	poly := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(poly)
+		log[poly] = byte(i)
+
+		// multiply by x + 1
+		poly = (poly << 1) ^ poly
+		if poly&0x100 != 0 {
+			poly ^= 0x11b
+		}
+	}
+	return exp, log
+}()
+
+// rawShare is a point of a polynomial over GF(256) per byte of the secret.
+type rawShare struct {
+	x    byte
+	data []byte
+}
+
+// splitSecret splits the secret into count shares, any threshold of which
+// recover it.
+func splitSecret(threshold int, count int, secret []byte) ([]rawShare, error) {
+	if threshold < 1 || threshold > count {
+		return nil, errors.New("threshold must be between 1 and the share count")
+	}
+	if count > maxShareCount {
+		return nil, errors.New("share count must not exceed 16")
+	}
+
+	if threshold == 1 {
+		shares := make([]rawShare, count)
+		for i := range shares {
+			shares[i] = rawShare{x: byte(i), data: append([]byte{}, secret...)}
+		}
+		return shares, nil
+	}
+
+	// threshold - 2 shares are random, the polynomial through them, the
+	// digest and the secret gives the others
+	shares := make([]rawShare, 0, count)
+	for i := 0; i < threshold-2; i++ {
+		data, err := randomBytes(len(secret))
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, rawShare{x: byte(i), data: data})
+	}
+
+	randomPart, err := randomBytes(len(secret) - digestLength)
+	if err != nil {
+		return nil, err
+	}
+	digest := append(secretDigest(randomPart, secret), randomPart...)
+
+	base := append(append([]rawShare{}, shares...),
+		rawShare{x: digestIndex, data: digest},
+		rawShare{x: secretIndex, data: secret},
+	)
+	for i := threshold - 2; i < count; i++ {
+		data, err := interpolate(base, byte(i))
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, rawShare{x: byte(i), data: data})
+	}
+	return shares, nil
+}
+
+// recoverSecret recovers the secret from at least threshold shares and checks
+// its digest.
+func recoverSecret(threshold int, shares []rawShare) ([]byte, error) {
+	if threshold == 1 {
+		return append([]byte{}, shares[0].data...), nil
+	}
+
+	secret, err := interpolate(shares, secretIndex)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := interpolate(shares, digestIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := secretDigest(digest[digestLength:], secret)
+	if subtle.ConstantTimeCompare(digest[:digestLength], expected) != 1 {
+		return nil, ErrInvalidDigest
+	}
+	return secret, nil
+}
+
+// interpolate evaluates the polynomial through the shares at x with Lagrange
+// interpolation.
+func interpolate(shares []rawShare, x byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares to interpolate")
+	}
+	length := len(shares[0].data)
+	for i, share := range shares {
+		if len(share.data) != length {
+			return nil, errors.New("shares must have the same length")
+		}
+		if share.x == x {
+			return append([]byte{}, share.data...), nil
+		}
+		for _, other := range shares[:i] {
+			if other.x == share.x {
+				return nil, errors.New("shares must have unique indexes")
+			}
+		}
+	}
+
+	// logProd is the logarithm of the product of (x_i - x) over all shares
+	logProd := 0
+	for _, share := range shares {
+		logProd += int(logTable[share.x^x])
+	}
+
+	result := make([]byte, length)
+	for _, share := range shares {
+		// the logarithm of the Lagrange basis polynomial of the share at x
+		logBasis := logProd - int(logTable[share.x^x])
+		for _, other := range shares {
+			if other.x != share.x {
+				logBasis -= int(logTable[share.x^other.x])
+			}
+		}
+		logBasis = ((logBasis % 255) + 255) % 255
+
+		for i, y := range share.data {
+			if y != 0 {
+				result[i] ^= expTable[(int(logTable[y])+logBasis)%255]
+			}
+		}
+	}
+	return result, nil
+}
+
+// secretDigest returns the digest of the secret keyed by the random part of
+// the digest share.
+func secretDigest(randomPart []byte, secret []byte) []byte {
+	mac := hmac.New(sha256.New, randomPart)
+	mac.Write(secret)
+	return mac.Sum(nil)[:digestLength]
+}
+
+// encrypt encrypts the master secret with the passphrase using the 4-round
+// Feistel cipher of SLIP-39.
+func encrypt(
+	masterSecret []byte,
+	passphrase []byte,
+	iterationExponent int,
+	identifier uint16,
+	extendable bool,
+) []byte {
+	half := len(masterSecret) / 2
+	l := append([]byte{}, masterSecret[:half]...)
+	r := append([]byte{}, masterSecret[half:]...)
+	salt := cipherSalt(identifier, extendable)
+
+	for i := 0; i < roundCount; i++ {
+		f := roundFunction(i, passphrase, iterationExponent, salt, r)
+		l, r = r, xorBytes(l, f)
+	}
+	return append(r, l...)
+}
+
+// decrypt decrypts the encrypted master secret with the passphrase.
+func decrypt(
+	encrypted []byte,
+	passphrase []byte,
+	iterationExponent int,
+	identifier uint16,
+	extendable bool,
+) []byte {
+	half := len(encrypted) / 2
+	l := append([]byte{}, encrypted[:half]...)
+	r := append([]byte{}, encrypted[half:]...)
+	salt := cipherSalt(identifier, extendable)
+
+	for i := roundCount - 1; i >= 0; i-- {
+		f := roundFunction(i, passphrase, iterationExponent, salt, r)
+		l, r = r, xorBytes(l, f)
+	}
+	return append(r, l...)
+}
+
+// roundFunction is the round function of the Feistel cipher.
+func roundFunction(
+	round int,
+	passphrase []byte,
+	iterationExponent int,
+	salt []byte,
+	r []byte,
+) []byte {
+	password := append([]byte{byte(round)}, passphrase...)
+	iterations := (baseIterationCount << iterationExponent) / roundCount
+	return pbkdf2.Key(password, append(append([]byte{}, salt...), r...),
+		iterations, len(r), sha256.New)
+}
+
+// cipherSalt returns the salt of the cipher. Shares of extendable backups do
+// not salt with the identifier, so new groups with a new identifier can be
+// added later.
+func cipherSalt(identifier uint16, extendable bool) []byte {
+	if extendable {
+		return nil
+	}
+	return append([]byte(customizationString),
+		byte(identifier>>8), byte(identifier))
+}
+
+// xorBytes returns a xor b.
+func xorBytes(a []byte, b []byte) []byte {
+	result := make([]byte, len(a))
+	for i := range a {
+		result[i] = a[i] ^ b[i]
+	}
+	return result
+}
+
+// randomBytes returns n random bytes.
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,456 @@
+// Package slip39 implements SLIP-39, the Shamir's secret sharing backup of a
+// master secret as mnemonic shares, with group and member thresholds.
+package slip39
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// DefaultIterationExponent sets 20000 PBKDF2 iterations for the cipher
+	// protecting the master secret with the passphrase.
+	DefaultIterationExponent = 1
+
+	radixBits = 10
+	radixSize = 1 << radixBits
+
+	idLengthBits           = 15
+	iterationExpBits       = 4
+	checksumLengthWords    = 3
+	metadataLengthWords    = 7
+	minStrengthBits        = 128
+	minMnemonicLengthWords = metadataLengthWords +
+		(minStrengthBits+radixBits-1)/radixBits
+	maxShareCount = 16
+
+	customizationString           = "shamir"
+	customizationStringExtendable = "shamir_extendable"
+)
+
+var (
+	// ErrInvalidMnemonic is returned for malformed shares.
+	ErrInvalidMnemonic = errors.New("invalid mnemonic share")
+	// ErrInvalidChecksum is returned for shares with a wrong checksum.
+	ErrInvalidChecksum = errors.New("invalid mnemonic share checksum")
+	// ErrInsufficientShares is returned when the shares do not meet the
+	// group or member thresholds.
+	ErrInsufficientShares = errors.New("insufficient mnemonic shares")
+	// ErrTooManyShares is returned when the shares exceed the group or member
+	// thresholds.
+	ErrTooManyShares = errors.New("too many mnemonic shares")
+	// ErrIncompatibleShares is returned for shares of different backups.
+	ErrIncompatibleShares = errors.New("shares belong to different backups")
+)
+
+// wordIndex maps the four letter prefixes of the words to their index.
+var wordIndex = func() map[string]int {
+	index := make(map[string]int, radixSize)
+	for i, word := range wordlist {
+		index[word[:4]] = i
+	}
+	return index
+}()
+
+// Group is the member threshold and count of a group of shares.
+type Group struct {
+	MemberThreshold int
+	MemberCount     int
+}
+
+// Share is a decoded mnemonic share.
+type Share struct {
+	// Identifier is the random identifier common to the shares of a backup.
+	Identifier uint16
+	// Extendable is set for backups that can be extended with new groups.
+	Extendable        bool
+	IterationExponent int
+	GroupIndex        int
+	GroupThreshold    int
+	GroupCount        int
+	MemberIndex       int
+	MemberThreshold   int
+	Value             []byte
+}
+
+// GenerateMnemonics splits the master secret, 16 to 32 bytes of even length
+// like a BIP-32 seed, into groups of mnemonic shares. The master secret is
+// recovered from the member threshold of shares of each of the group threshold
+// of groups, and the passphrase. The shares are extendable and their cipher
+// uses DefaultIterationExponent unless an iteration exponent is given.
+func GenerateMnemonics(
+	groupThreshold int,
+	groups []Group,
+	masterSecret []byte,
+	passphrase string,
+	iterExpOpt ...int,
+) ([][]string, error) {
+	iterationExponent := DefaultIterationExponent
+	if len(iterExpOpt) > 0 {
+		iterationExponent = iterExpOpt[0]
+	}
+
+	switch {
+	case len(masterSecret)*8 < minStrengthBits || len(masterSecret)%2 != 0:
+		return nil, errors.New(
+			"master secret must be at least 16 bytes of even length")
+	case iterationExponent < 0 || iterationExponent >= 1<<iterationExpBits:
+		return nil, errors.New("iteration exponent must be between 0 and 15")
+	case groupThreshold < 1 || groupThreshold > len(groups):
+		return nil, errors.New(
+			"group threshold must be between 1 and the group count")
+	}
+	for _, group := range groups {
+		if group.MemberThreshold == 1 && group.MemberCount > 1 {
+			return nil, errors.New(
+				"groups of multiple shares with a threshold of 1 are not " +
+					"allowed, use 1-of-1 groups instead")
+		}
+	}
+	if err := validatePassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	random, err := randomBytes(2)
+	if err != nil {
+		return nil, err
+	}
+	identifier := binary.BigEndian.Uint16(random) & (1<<idLengthBits - 1)
+
+	encrypted := encrypt(
+		masterSecret, []byte(passphrase), iterationExponent, identifier, true)
+
+	groupShares, err := splitSecret(groupThreshold, len(groups), encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	mnemonics := make([][]string, len(groups))
+	for i, groupShare := range groupShares {
+		group := groups[i]
+		memberShares, err := splitSecret(
+			group.MemberThreshold, group.MemberCount, groupShare.data)
+		if err != nil {
+			return nil, fmt.Errorf("group %d: %w", i+1, err)
+		}
+
+		for _, memberShare := range memberShares {
+			share := &Share{
+				Identifier:        identifier,
+				Extendable:        true,
+				IterationExponent: iterationExponent,
+				GroupIndex:        i,
+				GroupThreshold:    groupThreshold,
+				GroupCount:        len(groups),
+				MemberIndex:       int(memberShare.x),
+				MemberThreshold:   group.MemberThreshold,
+				Value:             memberShare.data,
+			}
+			mnemonics[i] = append(mnemonics[i], share.Mnemonic())
+		}
+	}
+	return mnemonics, nil
+}
+
+// Combine recovers the master secret from the mnemonic shares and the
+// passphrase. The master secret can be used as the seed of an HD wallet.
+// As in the reference implementation, the shares must be of exactly the group
+// threshold of groups and of exactly the member threshold of each group;
+// repeated shares count once. A wrong passphrase recovers a different master
+// secret, as SLIP-39 has no way to tell them apart.
+func Combine(mnemonics []string, passphrase string) ([]byte, error) {
+	if len(mnemonics) == 0 {
+		return nil, ErrInsufficientShares
+	}
+	if err := validatePassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	var first *Share
+	groups := map[int][]*Share{}
+	var groupOrder []int
+	for _, mnemonic := range mnemonics {
+		share, err := ParseShare(mnemonic)
+		if err != nil {
+			return nil, err
+		}
+
+		if first == nil {
+			first = share
+		} else if share.Identifier != first.Identifier ||
+			share.Extendable != first.Extendable ||
+			share.IterationExponent != first.IterationExponent ||
+			share.GroupThreshold != first.GroupThreshold ||
+			share.GroupCount != first.GroupCount {
+			return nil, ErrIncompatibleShares
+		}
+
+		members := groups[share.GroupIndex]
+		for _, member := range members {
+			if member.MemberThreshold != share.MemberThreshold {
+				return nil, fmt.Errorf(
+					"%w: member thresholds of group %d differ",
+					ErrIncompatibleShares, share.GroupIndex+1)
+			}
+			if member.MemberIndex == share.MemberIndex {
+				if string(member.Value) != string(share.Value) {
+					return nil, fmt.Errorf(
+						"%w: conflicting shares of member %d of group %d",
+						ErrIncompatibleShares, share.MemberIndex+1,
+						share.GroupIndex+1)
+				}
+				share = nil
+				break
+			}
+		}
+		if share == nil {
+			continue
+		}
+		if len(members) == 0 {
+			groupOrder = append(groupOrder, share.GroupIndex)
+		}
+		groups[share.GroupIndex] = append(members, share)
+	}
+
+	switch {
+	case len(groups) < first.GroupThreshold:
+		return nil, fmt.Errorf("%w: %d of %d groups",
+			ErrInsufficientShares, len(groups), first.GroupThreshold)
+	case len(groups) > first.GroupThreshold:
+		return nil, fmt.Errorf("%w: %d groups for a threshold of %d",
+			ErrTooManyShares, len(groups), first.GroupThreshold)
+	}
+
+	groupShares := make([]rawShare, 0, len(groups))
+	for _, groupIndex := range groupOrder {
+		members := groups[groupIndex]
+		threshold := members[0].MemberThreshold
+		switch {
+		case len(members) < threshold:
+			return nil, fmt.Errorf("%w: %d of %d shares of group %d",
+				ErrInsufficientShares, len(members), threshold, groupIndex+1)
+		case len(members) > threshold:
+			return nil, fmt.Errorf(
+				"%w: %d shares of group %d for a threshold of %d",
+				ErrTooManyShares, len(members), groupIndex+1, threshold)
+		}
+
+		memberShares := make([]rawShare, threshold)
+		for i, member := range members {
+			memberShares[i] = rawShare{
+				x:    byte(member.MemberIndex),
+				data: member.Value,
+			}
+		}
+		groupSecret, err := recoverSecret(threshold, memberShares)
+		if err != nil {
+			return nil, fmt.Errorf("group %d: %w", groupIndex+1, err)
+		}
+		groupShares = append(groupShares, rawShare{
+			x:    byte(groupIndex),
+			data: groupSecret,
+		})
+	}
+
+	encrypted, err := recoverSecret(first.GroupThreshold, groupShares)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(encrypted, []byte(passphrase), first.IterationExponent,
+		first.Identifier, first.Extendable), nil
+}
+
+// ParseShare decodes a mnemonic share and verifies its checksum. Words may be
+// abbreviated to their first four letters.
+func ParseShare(mnemonic string) (*Share, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < minMnemonicLengthWords {
+		return nil, fmt.Errorf(
+			"%w: must have at least %d words",
+			ErrInvalidMnemonic, minMnemonicLengthWords)
+	}
+
+	indices := make([]int, len(words))
+	for i, word := range words {
+		index, ok := lookupWord(word)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown word %q", ErrInvalidMnemonic, word)
+		}
+		indices[i] = index
+	}
+
+	// the extendable flag sits right after the identifier
+	extendable := (indices[1]>>iterationExpBits)&1 == 1
+	if !verifyChecksum(indices, customization(extendable)) {
+		return nil, ErrInvalidChecksum
+	}
+
+	paddingBits := (radixBits * (len(words) - metadataLengthWords)) % 16
+	if paddingBits > 8 {
+		return nil, fmt.Errorf("%w: invalid length", ErrInvalidMnemonic)
+	}
+
+	idExp := indicesToInt(indices[:2]).Uint64()
+	params := indicesToInt(indices[2:4]).Uint64()
+	share := &Share{
+		Identifier:        uint16(idExp >> (iterationExpBits + 1)),
+		Extendable:        extendable,
+		IterationExponent: int(idExp & (1<<iterationExpBits - 1)),
+		GroupIndex:        int(params >> 16),
+		GroupThreshold:    int(params>>12&0xf) + 1,
+		GroupCount:        int(params>>8&0xf) + 1,
+		MemberIndex:       int(params >> 4 & 0xf),
+		MemberThreshold:   int(params&0xf) + 1,
+	}
+	if share.GroupCount < share.GroupThreshold {
+		return nil, fmt.Errorf(
+			"%w: group threshold exceeds the group count", ErrInvalidMnemonic)
+	}
+
+	valueIndices := indices[4 : len(indices)-checksumLengthWords]
+	valueBytes := (radixBits*len(valueIndices) - paddingBits) / 8
+	value := indicesToInt(valueIndices)
+	if value.BitLen() > valueBytes*8 {
+		return nil, fmt.Errorf("%w: invalid padding", ErrInvalidMnemonic)
+	}
+	share.Value = value.FillBytes(make([]byte, valueBytes))
+	return share, nil
+}
+
+// Mnemonic encodes the share as words of the SLIP-39 wordlist.
+func (s *Share) Mnemonic() string {
+	idExp := uint64(s.Identifier) << (iterationExpBits + 1)
+	if s.Extendable {
+		idExp |= 1 << iterationExpBits
+	}
+	idExp |= uint64(s.IterationExponent)
+
+	params := uint64(s.GroupIndex)<<16 |
+		uint64(s.GroupThreshold-1)<<12 |
+		uint64(s.GroupCount-1)<<8 |
+		uint64(s.MemberIndex)<<4 |
+		uint64(s.MemberThreshold-1)
+
+	valueWords := (len(s.Value)*8 + radixBits - 1) / radixBits
+
+	indices := intToIndices(new(big.Int).SetUint64(idExp), 2)
+	indices = append(indices, intToIndices(new(big.Int).SetUint64(params), 2)...)
+	indices = append(indices,
+		intToIndices(new(big.Int).SetBytes(s.Value), valueWords)...)
+	indices = append(indices,
+		createChecksum(indices, customization(s.Extendable))...)
+
+	words := make([]string, len(indices))
+	for i, index := range indices {
+		words[i] = wordlist[index]
+	}
+	return strings.Join(words, " ")
+}
+
+// lookupWord returns the index of the word, which may be abbreviated to its
+// first four letters.
+func lookupWord(word string) (int, bool) {
+	if len(word) < 4 {
+		return 0, false
+	}
+	index, ok := wordIndex[word[:4]]
+	if !ok || !strings.HasPrefix(wordlist[index], word) {
+		return 0, false
+	}
+	return index, true
+}
+
+// validatePassphrase checks that the passphrase is printable ASCII, as
+// SLIP-39 requires.
+func validatePassphrase(passphrase string) error {
+	for _, c := range []byte(passphrase) {
+		if c < 32 || c > 126 {
+			return errors.New("passphrase must be printable ascii")
+		}
+	}
+	return nil
+}
+
+// customization returns the customization string of the checksum.
+func customization(extendable bool) string {
+	if extendable {
+		return customizationStringExtendable
+	}
+	return customizationString
+}
+
+// indicesToInt returns the big-endian number of the 10-bit word indices.
+func indicesToInt(indices []int) *big.Int {
+	value := new(big.Int)
+	for _, index := range indices {
+		value.Lsh(value, radixBits)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+	return value
+}
+
+// intToIndices returns the number as count 10-bit word indices.
+func intToIndices(value *big.Int, count int) []int {
+	indices := make([]int, count)
+	v := new(big.Int).Set(value)
+	mask := big.NewInt(radixSize - 1)
+	for i := count - 1; i >= 0; i-- {
+		indices[i] = int(new(big.Int).And(v, mask).Int64())
+		v.Rsh(v, radixBits)
+	}
+	return indices
+}
+
+// rs1024Generator is the generator of the RS1024 checksum.
+var rs1024Generator = [10]uint32{
+	0xe0e040, 0x1c1c080, 0x3838100, 0x7070200, 0xe0e0009,
+	0x1c0c2412, 0x38086c24, 0x3090fc48, 0x21b1f890, 0x3f3f120,
+}
+
+// rs1024Polymod returns the RS1024 checksum polynomial of the values.
+func rs1024Polymod(values []int) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 20
+		chk = (chk&0xfffff)<<10 ^ uint32(v)
+		for i := 0; i < 10; i++ {
+			if (b>>i)&1 == 1 {
+				chk ^= rs1024Generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// createChecksum returns the three checksum words of the data.
+func createChecksum(data []int, customization string) []int {
+	values := customizationValues(customization)
+	values = append(values, data...)
+	values = append(values, 0, 0, 0)
+	polymod := rs1024Polymod(values) ^ 1
+
+	checksum := make([]int, checksumLengthWords)
+	for i := range checksum {
+		checksum[i] = int(polymod>>(radixBits*(2-i))) & (radixSize - 1)
+	}
+	return checksum
+}
+
+// verifyChecksum returns whether the checksum of the words is valid.
+func verifyChecksum(data []int, customization string) bool {
+	values := append(customizationValues(customization), data...)
+	return rs1024Polymod(values) == 1
+}
+
+// customizationValues returns the bytes of the customization string as values
+// of the checksum.
+func customizationValues(customization string) []int {
+	values := make([]int, len(customization))
+	for i := range customization {
+		values[i] = int(customization[i])
+	}
+	return values
+}
@@ -0,0 +1,353 @@
+package slip39
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// shares of the multi-group backup of the vectors: a 3-of-5 group, a 2-of-3
+// group and a 1-of-1 group, two of which recover the secret
+const (
+	eraserThreshold1 = "eraser senior ceramic snake clay various huge numb " +
+		"argue hesitate auction category timber browser greatest hanger " +
+		"petition script leaf pickup"
+	eraserThreshold2 = "eraser senior ceramic shaft dynamic become junior " +
+		"wrist silver peasant force math alto coal amazing segment yelp " +
+		"velvet image paces"
+	eraserThreshold3 = "eraser senior ceramic round column hawk trust auction " +
+		"smug shame alive greatest sheriff living perfect corner chest sled " +
+		"fumes adequate"
+	eraserPair1 = "eraser senior decision shadow artist work morning estate " +
+		"greatest pipeline plan ting petition forget hormone flexible general " +
+		"goat admit surface"
+	eraserPair2 = "eraser senior decision roster beard treat identify grumpy " +
+		"salt index fake aviation theater cubic bike cause research dragon " +
+		"emphasis counter"
+	eraserSingle = "eraser senior beard romp adorn nuclear spill corner " +
+		"cradle style ancient family general leader ambition exchange unusual " +
+		"garlic promise voice"
+)
+
+// vectors are test vectors of the SLIP-39 reference implementation, with the
+// passphrase "TREZOR". Invalid vectors have no secret and the error Combine
+// returns.
+var vectors = []struct {
+	description string
+	mnemonics   []string
+	secret      string
+	err         error
+}{
+	{
+		"valid mnemonic without sharing (128 bits)",
+		[]string{
+			"duckling enlarge academic academic agency result length " +
+				"solution fridge kidney coal piece deal husband erode duke " +
+				"ajar critical decision keyboard",
+		},
+		"bb54aac4b89dc868ba37d9cc21b2cece", nil,
+	},
+	{
+		"mnemonic with invalid checksum (128 bits)",
+		[]string{
+			"duckling enlarge academic academic agency result length " +
+				"solution fridge kidney coal piece deal husband erode duke " +
+				"ajar critical decision kidney",
+		},
+		"", ErrInvalidChecksum,
+	},
+	{
+		"mnemonic with invalid padding (128 bits)",
+		[]string{
+			"duckling enlarge academic academic email result length " +
+				"solution fridge kidney coal piece deal husband erode duke " +
+				"ajar music cargo fitness",
+		},
+		"", ErrInvalidMnemonic,
+	},
+	{
+		"basic sharing 2-of-3 (128 bits)",
+		[]string{
+			"shadow pistol academic always adequate wildlife fancy gross " +
+				"oasis cylinder mustang wrist rescue view short owner flip " +
+				"making coding armed",
+			"shadow pistol academic acid actress prayer class unknown " +
+				"daughter sweater depict flip twice unkind craft early " +
+				"superior advocate guest smoking",
+		},
+		"b43ceb7e57a0ea8766221624d01b0864", nil,
+	},
+	{
+		"basic sharing 2-of-3 with one share (128 bits)",
+		[]string{
+			"shadow pistol academic always adequate wildlife fancy gross " +
+				"oasis cylinder mustang wrist rescue view short owner flip " +
+				"making coding armed",
+		},
+		"", ErrInsufficientShares,
+	},
+	{
+		"mnemonics with different identifiers (128 bits)",
+		[]string{
+			"adequate smoking academic acid debut wine petition glen cluster " +
+				"slow rhyme slow simple epidemic rumor junk tracks treat " +
+				"olympic tolerate",
+			"adequate stay academic agency agency formal party ting frequent " +
+				"learn upstairs remember smear leaf damage anatomy ladle " +
+				"market hush corner",
+		},
+		"", ErrIncompatibleShares,
+	},
+	{
+		"mnemonics with different iteration exponents (128 bits)",
+		[]string{
+			"peasant leaves academic acid desert exact olympic math alive " +
+				"axle trial tackle drug deny decent smear dominant desert " +
+				"bucket remind",
+			"peasant leader academic agency cultural blessing percent " +
+				"network envelope medal junk primary human pumps jacket " +
+				"fragment payroll ticket evoke voice",
+		},
+		"", ErrIncompatibleShares,
+	},
+	{
+		"mnemonics with mismatching group thresholds (128 bits)",
+		[]string{
+			"liberty category beard echo animal fawn temple briefing math " +
+				"username various wolf aviation fancy visual holy thunder " +
+				"yelp helpful payment",
+			"liberty category beard email beyond should fancy romp founder " +
+				"easel pink holy hairy romp loyalty material victim owner " +
+				"toxic custody",
+			"liberty category academic easy being hazard crush diminish oral " +
+				"lizard reaction cluster force dilemma deploy force club " +
+				"veteran expect photo",
+		},
+		"", ErrIncompatibleShares,
+	},
+	{
+		"mnemonics with mismatching group counts (128 bits)",
+		[]string{
+			"average senior academic leaf broken teacher expect surface hour " +
+				"capture obesity desire negative dynamic dominant pistol " +
+				"mineral mailman iris aide",
+			"average senior academic agency curious pants blimp spew clothes " +
+				"slice script dress wrap firm shaft regular slavery negative " +
+				"theater roster",
+		},
+		"", ErrIncompatibleShares,
+	},
+	{
+		"mnemonics with greater group threshold than group counts (128 bits)",
+		[]string{
+			"music husband acrobat acid artist finance center either " +
+				"graduate swimming object bike medical clothes station aspect " +
+				"spider maiden bulb welcome",
+			"music husband acrobat agency advance hunting bike corner " +
+				"density careful material civil evil tactics remind hawk " +
+				"discuss hobo voice rainbow",
+			"music husband beard academic black tricycle clock mayor " +
+				"estimate level photo episode exclude ecology papa source " +
+				"amazing salt verify divorce",
+		},
+		"", ErrInvalidMnemonic,
+	},
+	{
+		"mnemonics giving an invalid digest (128 bits)",
+		[]string{
+			"guilt walnut academic acid deliver remove equip listen vampire " +
+				"tactics nylon rhythm failure husband fatigue alive blind " +
+				"enemy teaspoon rebound",
+			"guilt walnut academic agency brave hamster hobo declare herd " +
+				"taste alpha slim criminal mild arcade formal romp branch " +
+				"pink ambition",
+		},
+		"", ErrInvalidDigest,
+	},
+	{
+		"insufficient number of groups (128 bits, case 1)",
+		[]string{eraserSingle},
+		"", ErrInsufficientShares,
+	},
+	{
+		"insufficient number of groups (128 bits, case 2)",
+		[]string{eraserThreshold1, eraserThreshold2, eraserThreshold3},
+		"", ErrInsufficientShares,
+	},
+	{
+		"threshold number of groups, but insufficient number of members in " +
+			"one group (128 bits)",
+		[]string{eraserPair1, eraserSingle},
+		"", ErrInsufficientShares,
+	},
+	{
+		"threshold number of groups and members in each group (128 bits, " +
+			"case 1)",
+		[]string{eraserThreshold1, eraserThreshold2, eraserThreshold3,
+			eraserPair1, eraserPair2},
+		"7c3397a292a5941682d7a4ae2d898d11", nil,
+	},
+	{
+		"threshold number of groups and members in each group (128 bits, " +
+			"case 2)",
+		[]string{eraserPair2, eraserSingle, eraserPair1},
+		"7c3397a292a5941682d7a4ae2d898d11", nil,
+	},
+	{
+		"threshold number of groups and members in each group (128 bits, " +
+			"case 3)",
+		[]string{eraserThreshold3, eraserSingle, eraserThreshold1,
+			eraserThreshold2},
+		"7c3397a292a5941682d7a4ae2d898d11", nil,
+	},
+	{
+		"valid mnemonic without sharing (256 bits)",
+		[]string{
+			"theory painting academic academic armed sweater year military " +
+				"elder discuss acne wildlife boring employer fused large " +
+				"satoshi bundle carbon diagnose anatomy hamster leaves tracks " +
+				"paces beyond phantom capital marvel lips brave detect luck",
+		},
+		"989baf9dcaad5b10ca33dfd8cc75e42477025dce88ae83e75a230086a0e00e92",
+		nil,
+	},
+	{
+		"basic sharing 2-of-3 (256 bits)",
+		[]string{
+			"humidity disease academic always aluminum jewelry energy woman " +
+				"receiver strategy amuse duckling lying evidence network " +
+				"walnut tactics forget hairy rebound impulse brother survive " +
+				"clothes stadium mailman rival ocean reward venture always " +
+				"armed unwrap",
+			"humidity disease academic agency actress jacket gross physics " +
+				"cylinder solution fake mortgage benefit public busy prepare " +
+				"sharp friar change work slow purchase ruler again tricycle " +
+				"involve viral wireless mixture anatomy desert cargo upgrade",
+		},
+		"c938b319067687e990e05e0da0ecce1278f75ff58d9853f19dcaeed5de104aae",
+		nil,
+	},
+	{
+		"valid extendable mnemonic without sharing (128 bits)",
+		[]string{
+			"testify swimming academic academic column loyalty smear " +
+				"include exotic bedroom exotic wrist lobe cover grief golden " +
+				"smart junior estimate learn",
+		},
+		"1679b4516e0ee5954351d288a838f45e", nil,
+	},
+	{
+		"extendable basic sharing 2-of-3 (128 bits)",
+		[]string{
+			"enemy favorite academic acid cowboy phrase havoc level response " +
+				"walnut budget painting inside trash adjust froth kitchen " +
+				"learn tidy punish",
+			"enemy favorite academic always academic sniff script carpet " +
+				"romp kind promise scatter center unfair training emphasis " +
+				"evening belong fake enforce",
+		},
+		"48b1a4b80b8c209ad42c33672bdaa428", nil,
+	},
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		t.Run(v.description, func(t *testing.T) {
+			secret, err := Combine(v.mnemonics, "TREZOR")
+			if v.err != nil {
+				if !errors.Is(err, v.err) {
+					t.Fatalf("Combine returned %v, want %v", err, v.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(secret); got != v.secret {
+				t.Fatalf("secret %s, want %s", got, v.secret)
+			}
+
+			for _, mnemonic := range v.mnemonics {
+				share, err := ParseShare(mnemonic)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if share.Mnemonic() != mnemonic {
+					t.Fatalf("encoded share %q, want %q",
+						share.Mnemonic(), mnemonic)
+				}
+			}
+		})
+	}
+}
+
+func TestCombineThresholds(t *testing.T) {
+	secret, err := hex.DecodeString("bb54aac4b89dc868ba37d9cc21b2cece")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := GenerateMnemonics(2, []Group{
+		{MemberThreshold: 2, MemberCount: 3},
+		{MemberThreshold: 1, MemberCount: 1},
+		{MemberThreshold: 2, MemberCount: 2},
+	}, secret, "TREZOR", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		mnemonics []string
+		err       error
+	}{
+		{
+			"member and group thresholds",
+			[]string{groups[0][2], groups[1][0], groups[0][0]},
+			nil,
+		},
+		{
+			"repeated share",
+			[]string{groups[1][0], groups[2][1], groups[2][0], groups[1][0]},
+			nil,
+		},
+		{
+			"shares beyond the member threshold",
+			[]string{groups[0][0], groups[0][1], groups[0][2], groups[1][0]},
+			ErrTooManyShares,
+		},
+		{
+			"groups beyond the group threshold",
+			[]string{groups[0][0], groups[0][1], groups[1][0],
+				groups[2][0], groups[2][1]},
+			ErrTooManyShares,
+		},
+		{
+			"incomplete group",
+			[]string{groups[0][0], groups[1][0], groups[2][0]},
+			ErrTooManyShares,
+		},
+		{
+			"incomplete group at the group threshold",
+			[]string{groups[0][0], groups[1][0]},
+			ErrInsufficientShares,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recovered, err := Combine(test.mnemonics, "TREZOR")
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("Combine returned %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(recovered, secret) {
+				t.Fatalf("recovered %x, want %x", recovered, secret)
+			}
+		})
+	}
+}